@@ -0,0 +1,4 @@
+ALTER TABLE sessions ADD COLUMN user_agent TEXT;
+ALTER TABLE sessions ADD COLUMN ip_address TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
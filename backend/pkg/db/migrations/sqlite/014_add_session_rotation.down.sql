@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_sessions_previous_token;
+
+ALTER TABLE sessions DROP COLUMN previous_token;
+ALTER TABLE sessions DROP COLUMN token_issued_at;
+ALTER TABLE sessions DROP COLUMN last_seen_at;
@@ -0,0 +1,7 @@
+ALTER TABLE sessions ADD COLUMN last_seen_at TIMESTAMP;
+ALTER TABLE sessions ADD COLUMN token_issued_at TIMESTAMP;
+ALTER TABLE sessions ADD COLUMN previous_token TEXT;
+
+UPDATE sessions SET last_seen_at = created_at, token_issued_at = created_at;
+
+CREATE INDEX IF NOT EXISTS idx_sessions_previous_token ON sessions(previous_token);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_email_verifications_user_id;
+DROP TABLE IF EXISTS email_verifications;
+
+ALTER TABLE users DROP COLUMN email_verified_at;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS pending_logins;
+DROP INDEX IF EXISTS idx_totp_recovery_codes_user_id;
+DROP TABLE IF EXISTS totp_recovery_codes;
+DROP TABLE IF EXISTS user_totp;
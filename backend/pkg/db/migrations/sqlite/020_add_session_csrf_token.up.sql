@@ -0,0 +1,4 @@
+ALTER TABLE sessions ADD COLUMN csrf_token TEXT;
+
+-- Give existing sessions a token so they keep working
+UPDATE sessions SET csrf_token = lower(hex(randomblob(32))) WHERE csrf_token IS NULL;
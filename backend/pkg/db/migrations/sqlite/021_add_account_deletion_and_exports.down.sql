@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_data_exports_status;
+DROP INDEX IF EXISTS idx_data_exports_user_id;
+DROP TABLE IF EXISTS data_exports;
+DROP TABLE IF EXISTS pending_file_cleanups;
+ALTER TABLE users DROP COLUMN deletion_scheduled_at;
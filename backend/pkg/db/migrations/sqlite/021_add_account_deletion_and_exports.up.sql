@@ -0,0 +1,24 @@
+-- When set, the account is deleted once this time has passed unless the user logs in again
+ALTER TABLE users ADD COLUMN deletion_scheduled_at TIMESTAMP;
+
+-- Users whose uploaded files still need removing after their account was deleted.
+-- There is deliberately no foreign key: the user row is gone by the time this is processed.
+CREATE TABLE IF NOT EXISTS pending_file_cleanups (
+    user_id INTEGER PRIMARY KEY,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS data_exports (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending', -- 'pending', 'processing', 'ready', 'failed'
+    file_name TEXT,
+    error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP,
+    expires_at TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_data_exports_user_id ON data_exports(user_id);
+CREATE INDEX IF NOT EXISTS idx_data_exports_status ON data_exports(status);
@@ -0,0 +1,27 @@
+-- Accounts at external OpenID providers that can be used to sign in as a user
+CREATE TABLE IF NOT EXISTS user_identities (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    issuer TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    email TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_login_at TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE(issuer, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
+
+-- In-flight OpenID logins, looked up by the state parameter when the provider redirects back
+CREATE TABLE IF NOT EXISTS oidc_login_states (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    state_hash TEXT UNIQUE NOT NULL,
+    code_verifier TEXT NOT NULL,
+    nonce TEXT NOT NULL,
+    redirect_to TEXT,
+    link_user_id INTEGER, -- set when a signed-in user is linking a new identity
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (link_user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,4 @@
+-- Site-wide role: 'user', 'moderator' or 'admin'
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'moderator', 'admin'));
+
+CREATE INDEX IF NOT EXISTS idx_users_role ON users(role);
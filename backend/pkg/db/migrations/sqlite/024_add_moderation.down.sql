@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_moderation_log_target_user_id;
+DROP INDEX IF EXISTS idx_moderation_log_created_at;
+DROP TABLE IF EXISTS moderation_log;
+ALTER TABLE users DROP COLUMN moderation_reason;
+ALTER TABLE users DROP COLUMN banned_at;
+ALTER TABLE users DROP COLUMN suspended_until;
@@ -0,0 +1,22 @@
+-- A suspended user can't sign in until suspended_until; a banned user can't sign in at all
+ALTER TABLE users ADD COLUMN suspended_until TIMESTAMP;
+ALTER TABLE users ADD COLUMN banned_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN moderation_reason TEXT;
+
+-- Every action taken through the moderation console.
+-- Targets are recorded by ID without foreign keys so entries outlive what they describe.
+CREATE TABLE IF NOT EXISTS moderation_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    moderator_id INTEGER,
+    action TEXT NOT NULL,
+    target_type TEXT NOT NULL, -- 'user', 'post', 'comment', 'group_post', 'group'
+    target_id INTEGER NOT NULL,
+    target_user_id INTEGER, -- the user affected, e.g. the author of deleted content
+    reason TEXT,
+    metadata TEXT, -- JSON snapshot of what was acted on
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (moderator_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_moderation_log_created_at ON moderation_log(created_at);
+CREATE INDEX IF NOT EXISTS idx_moderation_log_target_user_id ON moderation_log(target_user_id);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_reports_target;
+DROP INDEX IF EXISTS idx_reports_status_created_at;
+DROP INDEX IF EXISTS idx_reports_open_reporter_target;
+DROP TABLE IF EXISTS reports;
+ALTER TABLE messages DROP COLUMN hidden_at;
+ALTER TABLE group_posts DROP COLUMN hidden_at;
+ALTER TABLE comments DROP COLUMN hidden_at;
+ALTER TABLE posts DROP COLUMN hidden_at;
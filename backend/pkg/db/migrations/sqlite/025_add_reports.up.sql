@@ -0,0 +1,31 @@
+-- Content a moderator has hidden stays in place for its author but is left out of everyone else's views
+ALTER TABLE posts ADD COLUMN hidden_at TIMESTAMP;
+ALTER TABLE comments ADD COLUMN hidden_at TIMESTAMP;
+ALTER TABLE group_posts ADD COLUMN hidden_at TIMESTAMP;
+ALTER TABLE messages ADD COLUMN hidden_at TIMESTAMP;
+
+-- Reports users file against content or profiles, worked through by moderators.
+-- Targets are recorded by ID without foreign keys so a report outlives removed content.
+CREATE TABLE IF NOT EXISTS reports (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    reporter_id INTEGER NOT NULL,
+    target_type TEXT NOT NULL CHECK (target_type IN ('post', 'comment', 'group_post', 'message', 'user')),
+    target_id INTEGER NOT NULL,
+    target_user_id INTEGER, -- the author of the content, or the reported user
+    reason TEXT NOT NULL,
+    details TEXT,
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'actioned', 'dismissed')),
+    resolution TEXT, -- 'hide', 'remove', 'acknowledge' or 'dismiss'
+    resolution_note TEXT,
+    resolved_by INTEGER,
+    resolved_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (reporter_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (resolved_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+-- One open report per reporter and target
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reports_open_reporter_target
+    ON reports(reporter_id, target_type, target_id) WHERE status = 'open';
+CREATE INDEX IF NOT EXISTS idx_reports_status_created_at ON reports(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_reports_target ON reports(target_type, target_id);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_group_post_comments_parent_id;
+DROP INDEX IF EXISTS idx_group_post_comments_group_post_id;
+DROP TABLE IF EXISTS group_post_comment_reactions;
+DROP TABLE IF EXISTS group_post_comments;
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_group_post_comment_revisions_comment_id;
+DROP INDEX IF EXISTS idx_comment_revisions_comment_id;
+DROP INDEX IF EXISTS idx_post_revisions_post_id;
+DROP TABLE IF EXISTS group_post_comment_revisions;
+DROP TABLE IF EXISTS comment_revisions;
+DROP TABLE IF EXISTS post_revisions;
+ALTER TABLE group_post_comments DROP COLUMN revision_count;
+ALTER TABLE comments DROP COLUMN revision_count;
+ALTER TABLE posts DROP COLUMN revision_count;
@@ -0,0 +1,39 @@
+-- Earlier versions of edited posts and comments. Each row is a version as it stood
+-- before an edit replaced it; the current version stays on the content itself.
+ALTER TABLE posts ADD COLUMN revision_count INTEGER DEFAULT 0;
+ALTER TABLE comments ADD COLUMN revision_count INTEGER DEFAULT 0;
+ALTER TABLE group_post_comments ADD COLUMN revision_count INTEGER DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS post_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    privacy TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL, -- when this version was written
+    replaced_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS comment_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    comment_id INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    image_url TEXT,
+    created_at TIMESTAMP NOT NULL,
+    replaced_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS group_post_comment_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    comment_id INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    image_url TEXT,
+    created_at TIMESTAMP NOT NULL,
+    replaced_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (comment_id) REFERENCES group_post_comments(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_revisions_post_id ON post_revisions(post_id);
+CREATE INDEX IF NOT EXISTS idx_comment_revisions_comment_id ON comment_revisions(comment_id);
+CREATE INDEX IF NOT EXISTS idx_group_post_comment_revisions_comment_id ON group_post_comment_revisions(comment_id);
@@ -0,0 +1,6 @@
+-- Posts can be saved as drafts or scheduled for later. Only published posts are shown
+-- to anyone but their author; the scheduler publishes scheduled posts once publish_at passes.
+ALTER TABLE posts ADD COLUMN status TEXT NOT NULL DEFAULT 'published' CHECK (status IN ('draft', 'scheduled', 'published'));
+ALTER TABLE posts ADD COLUMN publish_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_posts_status_publish_at ON posts(status, publish_at);
@@ -0,0 +1,8 @@
+DROP TRIGGER IF EXISTS group_post_comments_delete_tags;
+DROP TRIGGER IF EXISTS group_posts_delete_tags;
+DROP TRIGGER IF EXISTS comments_delete_tags;
+DROP TRIGGER IF EXISTS posts_delete_tags;
+DROP INDEX IF EXISTS idx_content_mentions_user_id;
+DROP INDEX IF EXISTS idx_content_hashtags_tag;
+DROP TABLE IF EXISTS content_mentions;
+DROP TABLE IF EXISTS content_hashtags;
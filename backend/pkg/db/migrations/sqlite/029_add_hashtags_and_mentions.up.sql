@@ -0,0 +1,43 @@
+-- Hashtags and @mentions found in posts, comments, group posts and group post comments,
+-- kept in step with the content's text whenever it is created or edited.
+CREATE TABLE IF NOT EXISTS content_hashtags (
+    content_type TEXT NOT NULL CHECK (content_type IN ('post', 'comment', 'group_post', 'group_post_comment')),
+    content_id INTEGER NOT NULL,
+    tag TEXT NOT NULL, -- lowercase, without the leading #
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (content_type, content_id, tag)
+);
+
+CREATE TABLE IF NOT EXISTS content_mentions (
+    content_type TEXT NOT NULL CHECK (content_type IN ('post', 'comment', 'group_post', 'group_post_comment')),
+    content_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    notified_at TIMESTAMP, -- set once the user could see the content and was notified
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (content_type, content_id, user_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_content_hashtags_tag ON content_hashtags(tag, content_type);
+CREATE INDEX IF NOT EXISTS idx_content_mentions_user_id ON content_mentions(user_id);
+
+-- The content tables can't be referenced by a single foreign key, so clean up after them
+CREATE TRIGGER IF NOT EXISTS posts_delete_tags AFTER DELETE ON posts BEGIN
+    DELETE FROM content_hashtags WHERE content_type = 'post' AND content_id = OLD.id;
+    DELETE FROM content_mentions WHERE content_type = 'post' AND content_id = OLD.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS comments_delete_tags AFTER DELETE ON comments BEGIN
+    DELETE FROM content_hashtags WHERE content_type = 'comment' AND content_id = OLD.id;
+    DELETE FROM content_mentions WHERE content_type = 'comment' AND content_id = OLD.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS group_posts_delete_tags AFTER DELETE ON group_posts BEGIN
+    DELETE FROM content_hashtags WHERE content_type = 'group_post' AND content_id = OLD.id;
+    DELETE FROM content_mentions WHERE content_type = 'group_post' AND content_id = OLD.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS group_post_comments_delete_tags AFTER DELETE ON group_post_comments BEGIN
+    DELETE FROM content_hashtags WHERE content_type = 'group_post_comment' AND content_id = OLD.id;
+    DELETE FROM content_mentions WHERE content_type = 'group_post_comment' AND content_id = OLD.id;
+END;
@@ -0,0 +1,114 @@
+-- Full-text search indexes for posts, comments, group posts, people and groups.
+-- Each index is an external-content FTS5 table over its base table, kept in sync by triggers,
+-- so only the index is stored and rows are read back from the base table.
+-- Requires SQLite built with FTS5 (the sqlite_fts5 build tag for go-sqlite3).
+
+CREATE VIRTUAL TABLE IF NOT EXISTS posts_fts USING fts5(
+    content,
+    content='posts', content_rowid='id',
+    tokenize='unicode61 remove_diacritics 2', prefix='2 3'
+);
+
+CREATE TRIGGER IF NOT EXISTS posts_fts_insert AFTER INSERT ON posts BEGIN
+    INSERT INTO posts_fts (rowid, content) VALUES (new.id, new.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS posts_fts_delete AFTER DELETE ON posts BEGIN
+    INSERT INTO posts_fts (posts_fts, rowid, content) VALUES ('delete', old.id, old.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS posts_fts_update AFTER UPDATE OF content ON posts BEGIN
+    INSERT INTO posts_fts (posts_fts, rowid, content) VALUES ('delete', old.id, old.content);
+    INSERT INTO posts_fts (rowid, content) VALUES (new.id, new.content);
+END;
+
+-- Index existing rows
+INSERT INTO posts_fts (posts_fts) VALUES ('rebuild');
+
+CREATE VIRTUAL TABLE IF NOT EXISTS comments_fts USING fts5(
+    content,
+    content='comments', content_rowid='id',
+    tokenize='unicode61 remove_diacritics 2', prefix='2 3'
+);
+
+CREATE TRIGGER IF NOT EXISTS comments_fts_insert AFTER INSERT ON comments BEGIN
+    INSERT INTO comments_fts (rowid, content) VALUES (new.id, new.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS comments_fts_delete AFTER DELETE ON comments BEGIN
+    INSERT INTO comments_fts (comments_fts, rowid, content) VALUES ('delete', old.id, old.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS comments_fts_update AFTER UPDATE OF content ON comments BEGIN
+    INSERT INTO comments_fts (comments_fts, rowid, content) VALUES ('delete', old.id, old.content);
+    INSERT INTO comments_fts (rowid, content) VALUES (new.id, new.content);
+END;
+
+-- Index existing rows
+INSERT INTO comments_fts (comments_fts) VALUES ('rebuild');
+
+CREATE VIRTUAL TABLE IF NOT EXISTS group_posts_fts USING fts5(
+    content,
+    content='group_posts', content_rowid='id',
+    tokenize='unicode61 remove_diacritics 2', prefix='2 3'
+);
+
+CREATE TRIGGER IF NOT EXISTS group_posts_fts_insert AFTER INSERT ON group_posts BEGIN
+    INSERT INTO group_posts_fts (rowid, content) VALUES (new.id, new.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS group_posts_fts_delete AFTER DELETE ON group_posts BEGIN
+    INSERT INTO group_posts_fts (group_posts_fts, rowid, content) VALUES ('delete', old.id, old.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS group_posts_fts_update AFTER UPDATE OF content ON group_posts BEGIN
+    INSERT INTO group_posts_fts (group_posts_fts, rowid, content) VALUES ('delete', old.id, old.content);
+    INSERT INTO group_posts_fts (rowid, content) VALUES (new.id, new.content);
+END;
+
+-- Index existing rows
+INSERT INTO group_posts_fts (group_posts_fts) VALUES ('rebuild');
+
+CREATE VIRTUAL TABLE IF NOT EXISTS users_fts USING fts5(
+    first_name, last_name, nickname, about_me,
+    content='users', content_rowid='id',
+    tokenize='unicode61 remove_diacritics 2', prefix='2 3'
+);
+
+CREATE TRIGGER IF NOT EXISTS users_fts_insert AFTER INSERT ON users BEGIN
+    INSERT INTO users_fts (rowid, first_name, last_name, nickname, about_me) VALUES (new.id, new.first_name, new.last_name, new.nickname, new.about_me);
+END;
+
+CREATE TRIGGER IF NOT EXISTS users_fts_delete AFTER DELETE ON users BEGIN
+    INSERT INTO users_fts (users_fts, rowid, first_name, last_name, nickname, about_me) VALUES ('delete', old.id, old.first_name, old.last_name, old.nickname, old.about_me);
+END;
+
+CREATE TRIGGER IF NOT EXISTS users_fts_update AFTER UPDATE OF first_name, last_name, nickname, about_me ON users BEGIN
+    INSERT INTO users_fts (users_fts, rowid, first_name, last_name, nickname, about_me) VALUES ('delete', old.id, old.first_name, old.last_name, old.nickname, old.about_me);
+    INSERT INTO users_fts (rowid, first_name, last_name, nickname, about_me) VALUES (new.id, new.first_name, new.last_name, new.nickname, new.about_me);
+END;
+
+-- Index existing rows
+INSERT INTO users_fts (users_fts) VALUES ('rebuild');
+
+CREATE VIRTUAL TABLE IF NOT EXISTS groups_fts USING fts5(
+    title, description,
+    content='groups', content_rowid='id',
+    tokenize='unicode61 remove_diacritics 2', prefix='2 3'
+);
+
+CREATE TRIGGER IF NOT EXISTS groups_fts_insert AFTER INSERT ON groups BEGIN
+    INSERT INTO groups_fts (rowid, title, description) VALUES (new.id, new.title, new.description);
+END;
+
+CREATE TRIGGER IF NOT EXISTS groups_fts_delete AFTER DELETE ON groups BEGIN
+    INSERT INTO groups_fts (groups_fts, rowid, title, description) VALUES ('delete', old.id, old.title, old.description);
+END;
+
+CREATE TRIGGER IF NOT EXISTS groups_fts_update AFTER UPDATE OF title, description ON groups BEGIN
+    INSERT INTO groups_fts (groups_fts, rowid, title, description) VALUES ('delete', old.id, old.title, old.description);
+    INSERT INTO groups_fts (rowid, title, description) VALUES (new.id, new.title, new.description);
+END;
+
+-- Index existing rows
+INSERT INTO groups_fts (groups_fts) VALUES ('rebuild');
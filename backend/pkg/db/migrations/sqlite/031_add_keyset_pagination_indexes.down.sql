@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_comments_post_user_created_at;
+DROP INDEX IF EXISTS idx_saved_posts_user_created_at;
+DROP INDEX IF EXISTS idx_post_reactions_user_created_at;
+DROP INDEX IF EXISTS idx_notifications_user_created_at_id;
+DROP INDEX IF EXISTS idx_posts_created_at_id;
@@ -0,0 +1,6 @@
+-- Indexes matching the (created_at, id) order that feeds and lists are paged by
+CREATE INDEX IF NOT EXISTS idx_posts_created_at_id ON posts(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_notifications_user_created_at_id ON notifications(user_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_post_reactions_user_created_at ON post_reactions(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_saved_posts_user_created_at ON saved_posts(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_comments_post_user_created_at ON comments(post_id, user_id, created_at);
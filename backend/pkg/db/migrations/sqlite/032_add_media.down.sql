@@ -0,0 +1,8 @@
+DROP TRIGGER IF EXISTS messages_delete_media;
+DROP TRIGGER IF EXISTS group_post_comments_delete_media;
+DROP TRIGGER IF EXISTS group_posts_delete_media;
+DROP TRIGGER IF EXISTS comments_delete_media;
+DROP TRIGGER IF EXISTS posts_delete_media;
+DROP INDEX IF EXISTS idx_media_user_id;
+DROP INDEX IF EXISTS idx_media_target;
+DROP TABLE IF EXISTS media;
@@ -0,0 +1,87 @@
+-- Uploaded images and the content they are attached to. An upload is recorded when the file is
+-- stored and stays unattached until its uploader attaches it to a post, group post, comment,
+-- group post comment or message.
+CREATE TABLE IF NOT EXISTS media (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL, -- the uploader
+    url TEXT NOT NULL,
+    mime_type TEXT NOT NULL DEFAULT '',
+    width INTEGER,  -- in pixels, when known
+    height INTEGER,
+    alt_text TEXT NOT NULL DEFAULT '',
+    target_type TEXT CHECK (target_type IN ('post', 'comment', 'group_post', 'group_post_comment', 'message')),
+    target_id INTEGER,
+    position INTEGER NOT NULL DEFAULT 0, -- order among the target's attachments, from 0
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CHECK ((target_type IS NULL) = (target_id IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_media_target ON media(target_type, target_id, position);
+CREATE INDEX IF NOT EXISTS idx_media_user_id ON media(user_id);
+
+-- Move the single images already stored on content into attachments
+INSERT INTO media (user_id, url, mime_type, target_type, target_id, position, created_at)
+SELECT user_id, image_url,
+    CASE
+        WHEN lower(image_url) LIKE '%.png' THEN 'image/png'
+        WHEN lower(image_url) LIKE '%.gif' THEN 'image/gif'
+        WHEN lower(image_url) LIKE '%.jpg' OR lower(image_url) LIKE '%.jpeg' THEN 'image/jpeg'
+        ELSE ''
+    END,
+    'post', id, 0, created_at
+FROM posts WHERE image_url IS NOT NULL AND image_url != '';
+
+INSERT INTO media (user_id, url, mime_type, target_type, target_id, position, created_at)
+SELECT user_id, image_url,
+    CASE
+        WHEN lower(image_url) LIKE '%.png' THEN 'image/png'
+        WHEN lower(image_url) LIKE '%.gif' THEN 'image/gif'
+        WHEN lower(image_url) LIKE '%.jpg' OR lower(image_url) LIKE '%.jpeg' THEN 'image/jpeg'
+        ELSE ''
+    END,
+    'comment', id, 0, created_at
+FROM comments WHERE image_url IS NOT NULL AND image_url != '';
+
+INSERT INTO media (user_id, url, mime_type, target_type, target_id, position, created_at)
+SELECT user_id, image_url,
+    CASE
+        WHEN lower(image_url) LIKE '%.png' THEN 'image/png'
+        WHEN lower(image_url) LIKE '%.gif' THEN 'image/gif'
+        WHEN lower(image_url) LIKE '%.jpg' OR lower(image_url) LIKE '%.jpeg' THEN 'image/jpeg'
+        ELSE ''
+    END,
+    'group_post', id, 0, created_at
+FROM group_posts WHERE image_url IS NOT NULL AND image_url != '';
+
+INSERT INTO media (user_id, url, mime_type, target_type, target_id, position, created_at)
+SELECT user_id, image_url,
+    CASE
+        WHEN lower(image_url) LIKE '%.png' THEN 'image/png'
+        WHEN lower(image_url) LIKE '%.gif' THEN 'image/gif'
+        WHEN lower(image_url) LIKE '%.jpg' OR lower(image_url) LIKE '%.jpeg' THEN 'image/jpeg'
+        ELSE ''
+    END,
+    'group_post_comment', id, 0, created_at
+FROM group_post_comments WHERE image_url IS NOT NULL AND image_url != '';
+
+-- The content tables can't be referenced by a single foreign key, so clean up after them
+CREATE TRIGGER IF NOT EXISTS posts_delete_media AFTER DELETE ON posts BEGIN
+    DELETE FROM media WHERE target_type = 'post' AND target_id = OLD.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS comments_delete_media AFTER DELETE ON comments BEGIN
+    DELETE FROM media WHERE target_type = 'comment' AND target_id = OLD.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS group_posts_delete_media AFTER DELETE ON group_posts BEGIN
+    DELETE FROM media WHERE target_type = 'group_post' AND target_id = OLD.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS group_post_comments_delete_media AFTER DELETE ON group_post_comments BEGIN
+    DELETE FROM media WHERE target_type = 'group_post_comment' AND target_id = OLD.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS messages_delete_media AFTER DELETE ON messages BEGIN
+    DELETE FROM media WHERE target_type = 'message' AND target_id = OLD.id;
+END;
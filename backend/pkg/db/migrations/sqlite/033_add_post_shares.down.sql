@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS posts_delete_share;
+DROP TRIGGER IF EXISTS posts_publish_share;
+DROP TRIGGER IF EXISTS posts_insert_share;
+DROP INDEX IF EXISTS idx_posts_shared_post_id;
+ALTER TABLE posts DROP COLUMN share_count;
+ALTER TABLE posts DROP COLUMN shared_post_id;
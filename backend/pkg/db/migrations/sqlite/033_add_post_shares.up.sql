@@ -0,0 +1,23 @@
+-- Reposts and quote posts. A share is a post pointing at the post it shares; a repost has no
+-- text or attachments of its own, a quote post does. shared_post_id isn't a foreign key, so a
+-- share outlives the post it shares and can show that it was deleted.
+ALTER TABLE posts ADD COLUMN shared_post_id INTEGER;
+ALTER TABLE posts ADD COLUMN share_count INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_posts_shared_post_id ON posts(shared_post_id, user_id);
+
+-- share_count counts published shares
+CREATE TRIGGER IF NOT EXISTS posts_insert_share AFTER INSERT ON posts
+WHEN NEW.shared_post_id IS NOT NULL AND NEW.status = 'published' BEGIN
+    UPDATE posts SET share_count = share_count + 1 WHERE id = NEW.shared_post_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS posts_publish_share AFTER UPDATE OF status ON posts
+WHEN NEW.shared_post_id IS NOT NULL AND NEW.status = 'published' AND OLD.status != 'published' BEGIN
+    UPDATE posts SET share_count = share_count + 1 WHERE id = NEW.shared_post_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS posts_delete_share AFTER DELETE ON posts
+WHEN OLD.shared_post_id IS NOT NULL AND OLD.status = 'published' BEGIN
+    UPDATE posts SET share_count = MAX(share_count - 1, 0) WHERE id = OLD.shared_post_id;
+END;
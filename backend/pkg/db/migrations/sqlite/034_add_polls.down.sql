@@ -0,0 +1,8 @@
+DROP TRIGGER IF EXISTS group_posts_delete_poll;
+DROP TRIGGER IF EXISTS posts_delete_poll;
+DROP INDEX IF EXISTS idx_poll_votes_user_id;
+DROP INDEX IF EXISTS idx_poll_votes_poll_user;
+DROP INDEX IF EXISTS idx_poll_options_poll_id;
+DROP TABLE IF EXISTS poll_votes;
+DROP TABLE IF EXISTS poll_options;
+DROP TABLE IF EXISTS polls;
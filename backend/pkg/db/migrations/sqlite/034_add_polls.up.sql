@@ -0,0 +1,43 @@
+-- Polls attached to posts and group posts, one per post
+CREATE TABLE IF NOT EXISTS polls (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_type TEXT NOT NULL CHECK (target_type IN ('post', 'group_post')),
+    target_id INTEGER NOT NULL,
+    multiple_choice BOOLEAN NOT NULL DEFAULT 0,
+    anonymous BOOLEAN NOT NULL DEFAULT 0, -- whether voters are hidden from other users
+    closes_at TIMESTAMP, -- in UTC; polls without one stay open
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (target_type, target_id)
+);
+
+CREATE TABLE IF NOT EXISTS poll_options (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    poll_id INTEGER NOT NULL,
+    text TEXT NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0, -- order among the poll's options, from 0
+    FOREIGN KEY (poll_id) REFERENCES polls(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS poll_votes (
+    poll_id INTEGER NOT NULL,
+    option_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (option_id, user_id),
+    FOREIGN KEY (poll_id) REFERENCES polls(id) ON DELETE CASCADE,
+    FOREIGN KEY (option_id) REFERENCES poll_options(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_poll_options_poll_id ON poll_options(poll_id, position);
+CREATE INDEX IF NOT EXISTS idx_poll_votes_poll_user ON poll_votes(poll_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_poll_votes_user_id ON poll_votes(user_id);
+
+-- Posts and group posts can't be referenced by a single foreign key, so clean up after them
+CREATE TRIGGER IF NOT EXISTS posts_delete_poll AFTER DELETE ON posts BEGIN
+    DELETE FROM polls WHERE target_type = 'post' AND target_id = OLD.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS group_posts_delete_poll AFTER DELETE ON group_posts BEGIN
+    DELETE FROM polls WHERE target_type = 'group_post' AND target_id = OLD.id;
+END;
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"github.com/hezronokwach/soshi/pkg/middleware"
+	"github.com/hezronokwach/soshi/pkg/models"
+	"github.com/hezronokwach/soshi/pkg/utils"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type AccountHandler struct {
+	db *sql.DB
+}
+
+func NewAccountHandler(db *sql.DB) *AccountHandler {
+	return &AccountHandler{db: db}
+}
+
+// DeleteAccount schedules the current user's account for deletion after a grace period.
+// The user is signed out everywhere; logging in again before the deadline cancels the deletion.
+func (h *AccountHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse request body
+	var req struct {
+		Password string `json:"password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// Check password
+	authenticated, err := models.AuthenticateUser(h.db, user.Email, req.Password)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Authentication error")
+		return
+	}
+	if authenticated == nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid password")
+		return
+	}
+
+	deleteAt, err := models.ScheduleAccountDeletion(h.db, user.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to schedule account deletion")
+		return
+	}
+
+	// The session was revoked along with all others
+	utils.ClearSessionCookie(w)
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"message":               "Account scheduled for deletion. Log in again before the deletion date to cancel.",
+		"deletion_scheduled_at": deleteAt,
+	})
+}
+
+// RequestDataExport queues a ZIP export of the current user's data
+func (h *AccountHandler) RequestDataExport(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	export, err := models.CreateDataExport(h.db, user.ID)
+	if err != nil {
+		if err == models.ErrDataExportInProgress {
+			utils.RespondWithError(w, http.StatusConflict, err.Error())
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to request data export")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusAccepted, export)
+}
+
+// GetDataExports lists the current user's data exports
+func (h *AccountHandler) GetDataExports(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	exports, err := models.GetUserDataExports(h.db, user.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve data exports")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, exports)
+}
+
+// DownloadDataExport sends a finished data export archive
+func (h *AccountHandler) DownloadDataExport(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get export ID from URL
+	exportId, err := strconv.Atoi(chi.URLParam(r, "exportID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid export ID")
+		return
+	}
+
+	export, err := models.GetUserDataExport(h.db, exportId, user.ID)
+	if err != nil {
+		if err == models.ErrDataExportNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, "Data export not found")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve data export")
+		return
+	}
+	if export.Status != models.DataExportReady {
+		utils.RespondWithError(w, http.StatusConflict, "Data export is not ready")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="soshi-data-export.zip"`)
+	http.ServeFile(w, r, filepath.Join(models.DataExportDir(), export.FileName))
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/hezronokwach/soshi/pkg/middleware"
 	"github.com/hezronokwach/soshi/pkg/models"
 	"github.com/hezronokwach/soshi/pkg/utils"
 )
@@ -23,7 +24,7 @@ func NewActivityHandler(db *sql.DB) *ActivityHandler {
 // GetUserActivities retrieves user's activity with filtering options
 func (h *ActivityHandler) GetUserActivities(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -97,7 +98,7 @@ func (h *ActivityHandler) GetUserActivities(w http.ResponseWriter, r *http.Reque
 // GetUserPosts retrieves all posts by a user
 func (h *ActivityHandler) GetUserPosts(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -130,7 +131,7 @@ func (h *ActivityHandler) GetUserPosts(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get user posts
-	posts, err := models.GetUserPosts(h.db, targetUserID, page, limit)
+	posts, err := models.GetUserPosts(h.db, targetUserID, user.ID, page, limit)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve posts")
 		return
@@ -147,7 +148,7 @@ func (h *ActivityHandler) GetUserPosts(w http.ResponseWriter, r *http.Request) {
 // HideActivity hides an activity from user's activity feed
 func (h *ActivityHandler) HideActivity(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -177,7 +178,7 @@ func (h *ActivityHandler) HideActivity(w http.ResponseWriter, r *http.Request) {
 // UnhideActivity unhides an activity in user's activity feed
 func (h *ActivityHandler) UnhideActivity(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -207,7 +208,7 @@ func (h *ActivityHandler) UnhideActivity(w http.ResponseWriter, r *http.Request)
 // GetActivitySettings retrieves user's activity display settings
 func (h *ActivityHandler) GetActivitySettings(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -225,7 +226,7 @@ func (h *ActivityHandler) GetActivitySettings(w http.ResponseWriter, r *http.Req
 // UpdateActivitySettings updates user's activity display settings
 func (h *ActivityHandler) UpdateActivitySettings(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -286,7 +287,7 @@ func (h *ActivityHandler) filterActivitiesByPrivacy(activities []models.Activity
 		shouldShow := false
 
 		switch activity.ActivityType {
-		case "post_created":
+		case "post_created", "post_shared":
 			shouldShow = settings.ShowPosts
 		case "comment_created":
 			shouldShow = settings.ShowComments
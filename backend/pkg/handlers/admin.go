@@ -0,0 +1,486 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hezronokwach/soshi/pkg/middleware"
+	"github.com/hezronokwach/soshi/pkg/models"
+	"github.com/hezronokwach/soshi/pkg/utils"
+	"github.com/hezronokwach/soshi/pkg/websocket"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type AdminHandler struct {
+	db  *sql.DB
+	hub *websocket.Hub
+}
+
+func NewAdminHandler(db *sql.DB, hub *websocket.Hub) *AdminHandler {
+	return &AdminHandler{db: db, hub: hub}
+}
+
+// SetUserRole changes a user's site-wide role
+func (h *AdminHandler) SetUserRole(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get target user ID from URL
+	targetUserId, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	// Parse request body
+	var req struct {
+		Role models.Role `json:"role"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if !models.IsValidRole(req.Role) {
+		utils.RespondWithError(w, http.StatusBadRequest, models.ErrInvalidRole.Error())
+		return
+	}
+
+	// Keep admins from locking themselves out
+	if targetUserId == user.ID && req.Role != models.RoleAdmin {
+		utils.RespondWithError(w, http.StatusBadRequest, "You cannot remove your own admin role")
+		return
+	}
+
+	err = models.SetUserRole(h.db, targetUserId, req.Role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			utils.RespondWithError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update role")
+		return
+	}
+
+	// Get updated user
+	updatedUser, err := models.GetUserById(h.db, targetUserId)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve user")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, updatedUser)
+}
+
+// SuspendUser keeps a user from signing in for a number of hours and disconnects them
+func (h *AdminHandler) SuspendUser(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get target user ID from URL
+	targetUserId, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	// Parse request body
+	var req struct {
+		Hours  int    `json:"hours"`
+		Reason string `json:"reason"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if req.Hours < 1 {
+		utils.RespondWithError(w, http.StatusBadRequest, "Suspension must last at least one hour")
+		return
+	}
+
+	until := time.Now().Add(time.Duration(req.Hours) * time.Hour)
+	err = models.SuspendUser(h.db, user.ID, targetUserId, until, req.Reason)
+	h.respondToStandingChange(w, targetUserId, err)
+}
+
+// BanUser keeps a user from signing in indefinitely and disconnects them
+func (h *AdminHandler) BanUser(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get target user ID from URL
+	targetUserId, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	reason, ok := decodeModerationReason(w, r)
+	if !ok {
+		return
+	}
+
+	err = models.BanUser(h.db, user.ID, targetUserId, reason)
+	h.respondToStandingChange(w, targetUserId, err)
+}
+
+// ReinstateUser lifts a user's suspension or ban
+func (h *AdminHandler) ReinstateUser(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get target user ID from URL
+	targetUserId, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	reason, ok := decodeModerationReason(w, r)
+	if !ok {
+		return
+	}
+
+	err = models.ReinstateUser(h.db, user.ID, targetUserId, reason)
+	h.respondToStandingChange(w, targetUserId, err)
+}
+
+// respondToStandingChange finishes a suspend, ban or reinstate request by
+// dropping the user's live connections and returning their updated account
+func (h *AdminHandler) respondToStandingChange(w http.ResponseWriter, targetUserId int, err error) {
+	if err != nil {
+		switch err {
+		case models.ErrModerationTargetNotFound:
+			utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		case models.ErrCannotModerateUser:
+			utils.RespondWithError(w, http.StatusForbidden, err.Error())
+		default:
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update user")
+		}
+		return
+	}
+
+	// Get updated user
+	targetUser, err := models.GetUserById(h.db, targetUserId)
+	if err != nil || targetUser == nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve user")
+		return
+	}
+
+	// Their sessions are gone; close any open WebSocket connections too
+	if targetUser.IsSuspended() {
+		h.hub.DisconnectUser(targetUserId)
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, targetUser)
+}
+
+// GetUserActions shows a user's recent activity, including hidden items, and their moderation history
+func (h *AdminHandler) GetUserActions(w http.ResponseWriter, r *http.Request) {
+	// Get target user ID from URL
+	targetUserId, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	targetUser, err := models.GetUserById(h.db, targetUserId)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve user")
+		return
+	}
+	if targetUser == nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	// Parse query parameters
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+
+	activities, err := models.GetUserActivities(h.db, targetUserId, map[string]interface{}{"show_hidden": true}, 1, limit)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve activities")
+		return
+	}
+
+	history, err := models.GetModerationLog(h.db, map[string]interface{}{"target_user_id": targetUserId}, 1, limit)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve moderation history")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"user":               targetUser,
+		"activities":         activities,
+		"moderation_history": history,
+	})
+}
+
+// DeletePost removes any post
+func (h *AdminHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
+	h.forceDelete(w, r, models.ModTargetPost, "postID")
+}
+
+// DeleteComment removes any comment
+func (h *AdminHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	h.forceDelete(w, r, models.ModTargetComment, "commentID")
+}
+
+// DeleteGroupPost removes any group post
+func (h *AdminHandler) DeleteGroupPost(w http.ResponseWriter, r *http.Request) {
+	h.forceDelete(w, r, models.ModTargetGroupPost, "postID")
+}
+
+// DeleteGroup removes any group along with its posts, events and memberships
+func (h *AdminHandler) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	h.forceDelete(w, r, models.ModTargetGroup, "groupID")
+}
+
+// forceDelete deletes content named by a URL parameter and records it in the moderation log
+func (h *AdminHandler) forceDelete(w http.ResponseWriter, r *http.Request, targetType string, param string) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get target ID from URL
+	targetId, err := strconv.Atoi(chi.URLParam(r, param))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+
+	reason, ok := decodeModerationReason(w, r)
+	if !ok {
+		return
+	}
+
+	_, err = models.ForceDeleteContent(h.db, user.ID, targetType, targetId, reason)
+	if err != nil {
+		if err == models.ErrModerationTargetNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, "Content not found")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to delete content")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Content deleted successfully"})
+}
+
+// GetModerationLog lists moderation actions, newest first.
+// It can be filtered by moderator_id, target_user_id and action.
+func (h *AdminHandler) GetModerationLog(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	// Parse query parameters
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+
+	// Parse filters
+	filters := make(map[string]interface{})
+	if moderatorId, err := strconv.Atoi(query.Get("moderator_id")); err == nil {
+		filters["moderator_id"] = moderatorId
+	}
+	if targetUserId, err := strconv.Atoi(query.Get("target_user_id")); err == nil {
+		filters["target_user_id"] = targetUserId
+	}
+	if action := query.Get("action"); action != "" {
+		filters["action"] = action
+	}
+
+	entries, err := models.GetModerationLog(h.db, filters, page, limit)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve moderation log")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"entries": entries,
+		"page":    page,
+		"limit":   limit,
+	})
+}
+
+// GetReports lists the moderation queue. It shows open reports unless ?status= says otherwise,
+// and can be narrowed to one kind of content with ?target_type=.
+func (h *AdminHandler) GetReports(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	// Parse query parameters
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+
+	status := query.Get("status")
+	if status == "" {
+		status = models.ReportStatusOpen
+	}
+	if status != models.ReportStatusOpen && status != models.ReportStatusActioned && status != models.ReportStatusDismissed {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid status")
+		return
+	}
+
+	reports, err := models.GetReports(h.db, map[string]interface{}{
+		"status":      status,
+		"target_type": query.Get("target_type"),
+	}, page, limit)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve reports")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"reports": reports,
+		"page":    page,
+		"limit":   limit,
+	})
+}
+
+// GetReport shows a single report with the reported content
+func (h *AdminHandler) GetReport(w http.ResponseWriter, r *http.Request) {
+	// Get report ID from URL
+	reportId, err := strconv.Atoi(chi.URLParam(r, "reportID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid report ID")
+		return
+	}
+
+	report, err := models.GetReportById(h.db, reportId)
+	if err != nil {
+		if err == models.ErrReportNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve report")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, report)
+}
+
+// ResolveReport acts on a report: hide or remove the content, acknowledge it or dismiss it.
+// Every open report on the same content is closed and each reporter is notified.
+func (h *AdminHandler) ResolveReport(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get report ID from URL
+	reportId, err := strconv.Atoi(chi.URLParam(r, "reportID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid report ID")
+		return
+	}
+
+	// Parse request body
+	var req struct {
+		Resolution string `json:"resolution"`
+		Note       string `json:"note"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	resolved, err := models.ResolveReport(h.db, user.ID, reportId, req.Resolution, req.Note)
+	if err != nil {
+		switch err {
+		case models.ErrReportNotFound:
+			utils.RespondWithError(w, http.StatusNotFound, err.Error())
+		case models.ErrReportResolved:
+			utils.RespondWithError(w, http.StatusConflict, err.Error())
+		case models.ErrInvalidResolution:
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		case models.ErrModerationTargetNotFound:
+			utils.RespondWithError(w, http.StatusNotFound, "The reported content no longer exists; acknowledge or dismiss the report instead")
+		default:
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to resolve report")
+		}
+		return
+	}
+
+	// Let the reporters know the outcome
+	for _, report := range resolved {
+		_, _ = models.CreateNotification(h.db, report.ReporterID, "report_resolved", reportOutcomeMessage(report), report.ID)
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"resolved": resolved,
+	})
+}
+
+// reportOutcomeMessage describes a resolved report to the person who filed it
+func reportOutcomeMessage(report models.Report) string {
+	switch report.Resolution {
+	case models.ReportResolutionHide:
+		return "Thanks for your report. The content you reported has been hidden."
+	case models.ReportResolutionRemove:
+		return "Thanks for your report. The content you reported has been removed."
+	case models.ReportResolutionAcknowledge:
+		return "Thanks for your report. A moderator reviewed it and took action."
+	default:
+		return "Thanks for your report. A moderator reviewed it and found it doesn't break our rules."
+	}
+}
+
+// decodeModerationReason reads the optional {"reason": "..."} body of a moderation request.
+// It writes the error response and returns false if the body is malformed.
+func decodeModerationReason(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return "", false
+	}
+
+	return req.Reason, true
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hezronokwach/soshi/pkg/middleware"
+	"github.com/hezronokwach/soshi/pkg/models"
+	"github.com/hezronokwach/soshi/pkg/utils"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type APITokenHandler struct {
+	db *sql.DB
+}
+
+func NewAPITokenHandler(db *sql.DB) *APITokenHandler {
+	return &APITokenHandler{db: db}
+}
+
+// GetTokens lists the current user's personal access tokens
+func (h *APITokenHandler) GetTokens(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	tokens, err := models.GetUserAPITokens(h.db, user.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve tokens")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, tokens)
+}
+
+// CreateToken creates a personal access token for the current user
+func (h *APITokenHandler) CreateToken(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse request body
+	var req struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// Validate required fields
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Name is required")
+		return
+	}
+	if len(req.Scopes) == 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "At least one scope is required")
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !models.IsValidScope(scope) {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid scope: "+scope)
+			return
+		}
+	}
+	if req.ExpiresInDays < 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid expiry")
+		return
+	}
+
+	// Tokens without an expiry stay valid until revoked
+	var expiresAt *time.Time
+	if req.ExpiresInDays > 0 {
+		t := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		expiresAt = &t
+	}
+
+	token, plain, err := models.CreateAPIToken(h.db, user.ID, req.Name, req.Scopes, expiresAt)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create token")
+		return
+	}
+
+	// The plain-text token is only ever returned here
+	utils.RespondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"token":     plain,
+		"api_token": token,
+	})
+}
+
+// RevokeToken revokes one of the current user's personal access tokens
+func (h *APITokenHandler) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get token ID from URL
+	tokenId, err := strconv.Atoi(chi.URLParam(r, "tokenID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid token ID")
+		return
+	}
+
+	err = models.DeleteUserAPIToken(h.db, tokenId, user.ID)
+	if err != nil {
+		if err == models.ErrAPITokenNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, "Token not found")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to revoke token")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Token revoked successfully"})
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hezronokwach/soshi/pkg/middleware"
+	"github.com/hezronokwach/soshi/pkg/models"
+	"github.com/hezronokwach/soshi/pkg/utils"
+)
+
+// AudienceListHandler handles the audience lists users share private posts with
+type AudienceListHandler struct {
+	db *sql.DB
+}
+
+// NewAudienceListHandler creates a new AudienceListHandler
+func NewAudienceListHandler(db *sql.DB) *AudienceListHandler {
+	return &AudienceListHandler{db: db}
+}
+
+// GetLists retrieves the current user's audience lists
+func (h *AudienceListHandler) GetLists(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	lists, err := models.GetAudienceLists(h.db, user.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve audience lists")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, lists)
+}
+
+// CreateList creates an audience list for the current user
+func (h *AudienceListHandler) CreateList(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse request body
+	var req struct {
+		Name string `json:"name"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	list, err := models.CreateAudienceList(h.db, user.ID, req.Name)
+	if err != nil {
+		respondWithAudienceListError(w, err, "Failed to create audience list")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, list)
+}
+
+// GetList retrieves one of the current user's audience lists with its members
+func (h *AudienceListHandler) GetList(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get list ID from URL
+	listId, err := strconv.Atoi(chi.URLParam(r, "listID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid list ID")
+		return
+	}
+
+	list, err := models.GetAudienceList(h.db, listId, user.ID)
+	if err != nil {
+		respondWithAudienceListError(w, err, "Failed to retrieve audience list")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, list)
+}
+
+// RenameList renames one of the current user's audience lists
+func (h *AudienceListHandler) RenameList(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get list ID from URL
+	listId, err := strconv.Atoi(chi.URLParam(r, "listID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid list ID")
+		return
+	}
+
+	// Parse request body
+	var req struct {
+		Name string `json:"name"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := models.RenameAudienceList(h.db, listId, user.ID, req.Name); err != nil {
+		respondWithAudienceListError(w, err, "Failed to rename audience list")
+		return
+	}
+
+	list, err := models.GetAudienceList(h.db, listId, user.ID)
+	if err != nil {
+		respondWithAudienceListError(w, err, "Failed to retrieve audience list")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, list)
+}
+
+// DeleteList deletes one of the current user's audience lists
+func (h *AudienceListHandler) DeleteList(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get list ID from URL
+	listId, err := strconv.Atoi(chi.URLParam(r, "listID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid list ID")
+		return
+	}
+
+	if err := models.DeleteAudienceList(h.db, listId, user.ID); err != nil {
+		respondWithAudienceListError(w, err, "Failed to delete audience list")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Audience list deleted successfully"})
+}
+
+// AddMember adds a user to one of the current user's audience lists
+func (h *AudienceListHandler) AddMember(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get list ID from URL
+	listId, err := strconv.Atoi(chi.URLParam(r, "listID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid list ID")
+		return
+	}
+
+	// Parse request body
+	var req struct {
+		UserID int `json:"user_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := models.AddAudienceListMember(h.db, listId, user.ID, req.UserID); err != nil {
+		respondWithAudienceListError(w, err, "Failed to add member")
+		return
+	}
+
+	list, err := models.GetAudienceList(h.db, listId, user.ID)
+	if err != nil {
+		respondWithAudienceListError(w, err, "Failed to retrieve audience list")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, list)
+}
+
+// RemoveMember removes a user from one of the current user's audience lists
+func (h *AudienceListHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get list and member IDs from URL
+	listId, err := strconv.Atoi(chi.URLParam(r, "listID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid list ID")
+		return
+	}
+	memberId, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if err := models.RemoveAudienceListMember(h.db, listId, user.ID, memberId); err != nil {
+		respondWithAudienceListError(w, err, "Failed to remove member")
+		return
+	}
+
+	list, err := models.GetAudienceList(h.db, listId, user.ID)
+	if err != nil {
+		respondWithAudienceListError(w, err, "Failed to retrieve audience list")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, list)
+}
+
+// respondWithAudienceListError maps audience list errors to responses, and anything else to
+// an internal error with the given message
+func respondWithAudienceListError(w http.ResponseWriter, err error, message string) {
+	switch err {
+	case models.ErrAudienceListNotFound:
+		utils.RespondWithError(w, http.StatusNotFound, "Audience list not found")
+	case models.ErrAudienceListNameTaken:
+		utils.RespondWithError(w, http.StatusConflict, err.Error())
+	case models.ErrInvalidAudienceListName, models.ErrInvalidAudienceListMember:
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+	default:
+		utils.RespondWithError(w, http.StatusInternalServerError, message)
+	}
+}
@@ -3,21 +3,33 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/hezronokwach/soshi/pkg/mailer"
+	"github.com/hezronokwach/soshi/pkg/middleware"
 	"github.com/hezronokwach/soshi/pkg/models"
+	"github.com/hezronokwach/soshi/pkg/oidc"
 	"github.com/hezronokwach/soshi/pkg/utils"
+	"github.com/hezronokwach/soshi/pkg/validation"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 )
 
 type AuthHandler struct {
-	db *sql.DB
+	db         *sql.DB
+	mailer     mailer.Mailer
+	oidcClient *oidc.Client
 }
 
-func NewAuthHandler(db *sql.DB) *AuthHandler {
-	return &AuthHandler{db: db}
+func NewAuthHandler(db *sql.DB, mail mailer.Mailer, oidcClient *oidc.Client) *AuthHandler {
+	return &AuthHandler{db: db, mailer: mail, oidcClient: oidcClient}
 }
 
 // Register handles user registration
@@ -39,15 +51,9 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate required fields
-	if req.Email == "" || req.Password == "" || req.FirstName == "" || req.LastName == "" || req.DateOfBirth == "" {
-		utils.RespondWithError(w, http.StatusBadRequest, "Missing required fields")
-		return
-	}
-
 	// Create user
 	user := models.User{
-		Email:       req.Email,
+		Email:       strings.TrimSpace(req.Email),
 		Password:    req.Password,
 		FirstName:   req.FirstName,
 		LastName:    req.LastName,
@@ -57,12 +63,31 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		AboutMe:     req.AboutMe,
 	}
 
+	// Validate fields
+	v := validation.New()
+	v.Email("email", user.Email)
+	v.Password("password", user.Password, validation.PasswordPolicyFromEnv())
+	validateProfile(v, &user)
+	if !v.Valid() {
+		utils.RespondWithValidationErrors(w, v.Errors)
+		return
+	}
+
 	userId, err := models.CreateUser(h.db, user)
 	if err != nil {
+		if err == models.ErrEmailInUse {
+			utils.RespondWithValidationErrors(w, map[string]string{"email": err.Error()})
+			return
+		}
 		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	// Send verification email
+	if err := h.sendVerificationEmail(userId, user.Email, user.FirstName); err != nil {
+		log.Printf("Failed to create verification token for user %d: %v", userId, err)
+	}
+
 	utils.RespondWithJSON(w, http.StatusCreated, map[string]interface{}{
 		"message": "User registered successfully",
 		"user_id": userId,
@@ -82,6 +107,12 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Refuse attempts while the account or IP address is throttled
+	ipAddress := utils.ClientIP(r)
+	if !h.checkLoginThrottle(w, req.Email, ipAddress) {
+		return
+	}
+
 	// Authenticate user
 	user, err := models.AuthenticateUser(h.db, req.Email, req.Password)
 	if err != nil {
@@ -89,36 +120,79 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if user == nil {
+		if err := models.RecordLoginFailure(h.db, req.Email, ipAddress); err != nil {
+			log.Printf("Failed to record login failure: %v", err)
+		}
 		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid email or password")
 		return
 	}
 
+	// Suspended and banned users can't sign in
+	if user.IsSuspended() {
+		utils.RespondWithError(w, http.StatusForbidden, suspensionMessage(user))
+		return
+	}
+
+	// Enrolled users must pass a second factor before getting a session
+	twoFactorEnabled, err := models.IsTwoFactorEnabled(h.db, user.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Authentication error")
+		return
+	}
+	if twoFactorEnabled {
+		pendingToken, err := models.CreatePendingLogin(h.db, user.ID)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Authentication error")
+			return
+		}
+		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"two_factor_required": true,
+			"pending_token":       pendingToken,
+		})
+		return
+	}
+
 	// Create session
-	sessionToken := uuid.New().String()
-	err = models.CreateSession(h.db, user.ID, sessionToken)
+	session, err := h.startSession(w, r, user)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create session")
 		return
 	}
+	if err := models.ClearLoginFailures(h.db, user.Email); err != nil {
+		log.Printf("Failed to clear login failures: %v", err)
+	}
 
-	// Set session cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_token",
-		Value:    sessionToken,
-		Path:     "/",
-		Expires:  time.Now().Add(7 * 24 * time.Hour),
-		HttpOnly: true,
-		SameSite: http.SameSiteLaxMode,
-		Secure:   false,
-	})
+	respondWithSession(w, user, session)
+}
 
-	utils.RespondWithJSON(w, http.StatusOK, user)
+// checkLoginThrottle refuses a login attempt that comes too soon after earlier failures.
+// It writes a 429 response with Retry-After and returns false if the attempt must wait.
+func (h *AuthHandler) checkLoginThrottle(w http.ResponseWriter, email string, ipAddress string) bool {
+	throttle, err := models.CheckLoginThrottle(h.db, email, ipAddress)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Authentication error")
+		return false
+	}
+	if throttle.RetryAfter <= 0 {
+		return true
+	}
+
+	// Round up so clients never retry a moment too early
+	seconds := int((throttle.RetryAfter + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+
+	if throttle.Locked {
+		utils.RespondWithError(w, http.StatusTooManyRequests, "Too many failed login attempts, account temporarily locked")
+		return false
+	}
+	utils.RespondWithError(w, http.StatusTooManyRequests, "Too many failed login attempts, please wait before trying again")
+	return false
 }
 
 // Logout handles user logout
 func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	// Get session token from cookie
-	cookie, err := r.Cookie("session_token")
+	cookie, err := r.Cookie(utils.SessionCookieName)
 	if err != nil {
 		if err == http.ErrNoCookie {
 			utils.RespondWithError(w, http.StatusUnauthorized, "No session token provided")
@@ -137,15 +211,7 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Clear cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_token",
-		Value:    "",
-		Path:     "/",
-		Expires:  time.Now().Add(-time.Hour),
-		HttpOnly: true,
-		SameSite: http.SameSiteLaxMode,
-		Secure:   false,
-	})
+	utils.ClearSessionCookie(w)
 
 	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Logged out successfully"})
 }
@@ -153,7 +219,7 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 // GetSession retrieves the current user session
 func (h *AuthHandler) GetSession(w http.ResponseWriter, r *http.Request) {
 	// Get session token from cookie
-	cookie, err := r.Cookie("session_token")
+	cookie, err := r.Cookie(utils.SessionCookieName)
 	if err != nil {
 		if err == http.ErrNoCookie {
 			utils.RespondWithError(w, http.StatusUnauthorized, "No session token provided")
@@ -186,5 +252,321 @@ func (h *AuthHandler) GetSession(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	respondWithSession(w, user, session)
+}
+
+// GetSessions lists the current user's active sessions
+func (h *AuthHandler) GetSessions(w http.ResponseWriter, r *http.Request) {
+	// Get user and session from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	currentSession, _ := middleware.SessionFromContext(r.Context())
+
+	// Get sessions
+	sessions, err := models.GetUserSessions(h.db, user.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve sessions")
+		return
+	}
+
+	// Flag the session making this request
+	for i := range sessions {
+		sessions[i].Current = currentSession != nil && sessions[i].ID == currentSession.ID
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, sessions)
+}
+
+// RevokeSession revokes one of the current user's sessions
+func (h *AuthHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get session ID from URL
+	sessionId, err := strconv.Atoi(chi.URLParam(r, "sessionID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+
+	// Delete session
+	err = models.DeleteUserSession(h.db, sessionId, user.ID)
+	if err != nil {
+		if err == models.ErrSessionNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, "Session not found")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to revoke session")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Session revoked successfully"})
+}
+
+// RevokeOtherSessions revokes all of the current user's sessions except the current one
+func (h *AuthHandler) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	// Get user and session from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	currentSession, ok := middleware.SessionFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Delete other sessions
+	revoked, err := models.DeleteOtherSessions(h.db, user.ID, currentSession.Token)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to revoke sessions")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Other sessions revoked successfully",
+		"revoked": revoked,
+	})
+}
+
+// ForgotPassword emails a password reset link if the address belongs to an account
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	// Parse request body
+	var req struct {
+		Email string `json:"email"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if req.Email == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Email is required")
+		return
+	}
+
+	// Always give the same answer so the endpoint can't be used to discover accounts
+	response := map[string]string{"message": "If an account exists for this email, a reset link has been sent"}
+
+	user, err := models.GetUserByEmail(h.db, req.Email)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to process request")
+		return
+	}
+	if user == nil {
+		utils.RespondWithJSON(w, http.StatusOK, response)
+		return
+	}
+
+	// Create reset token
+	token, err := models.CreatePasswordResetToken(h.db, user.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to process request")
+		return
+	}
+
+	h.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: "Hi " + user.FirstName + ",\n\n" +
+			"Use the link below to choose a new password. It can only be used once.\n\n" +
+			appURL() + "/reset-password?token=" + token + "\n\n" +
+			"If you didn't ask to reset your password, you can ignore this email.",
+	})
+
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+// ResetPassword sets a new password using a reset token
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	// Parse request body
+	var req struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// Validate required fields
+	if req.Token == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Token is required")
+		return
+	}
+
+	v := validation.New()
+	v.Password("password", req.Password, validation.PasswordPolicyFromEnv())
+	if !v.Valid() {
+		utils.RespondWithValidationErrors(w, v.Errors)
+		return
+	}
+
+	// Reset password
+	_, err := models.ResetPassword(h.db, req.Token, req.Password)
+	if err != nil {
+		if err == models.ErrInvalidResetToken {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Password reset successfully"})
+}
+
+// VerifyEmail confirms an email address using a verification token
+func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	// Parse request body
+	var req struct {
+		Token string `json:"token"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if req.Token == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Token is required")
+		return
+	}
+
+	// Verify email
+	userId, err := models.VerifyEmail(h.db, req.Token)
+	if err != nil {
+		if err == models.ErrInvalidVerificationToken {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err == models.ErrEmailInUse {
+			utils.RespondWithError(w, http.StatusConflict, err.Error())
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to verify email")
+		return
+	}
+
+	// Get verified user
+	user, err := models.GetUserById(h.db, userId)
+	if err != nil || user == nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve user")
+		return
+	}
+
 	utils.RespondWithJSON(w, http.StatusOK, user)
 }
+
+// ResendVerification sends a new verification email to the current user
+func (h *AuthHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if user.EmailVerifiedAt != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Email is already verified")
+		return
+	}
+
+	if err := h.sendVerificationEmail(user.ID, user.Email, user.FirstName); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to send verification email")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Verification email sent"})
+}
+
+// startSession creates a session for a user and sets the session cookie.
+// Signing in also cancels any pending deletion of the account.
+func (h *AuthHandler) startSession(w http.ResponseWriter, r *http.Request, user *models.User) (*models.Session, error) {
+	sessionToken := uuid.New().String()
+	err := models.CreateSession(h.db, user.ID, sessionToken, r.UserAgent(), utils.ClientIP(r))
+	if err != nil {
+		return nil, err
+	}
+
+	if user.DeletionScheduledAt != nil {
+		if _, err := models.CancelAccountDeletion(h.db, user.ID); err != nil {
+			return nil, err
+		}
+		user.DeletionScheduledAt = nil
+	}
+
+	session, err := models.GetSessionByToken(h.db, sessionToken)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, errors.New("session not found after creation")
+	}
+	utils.SetSessionCookie(w, sessionToken, session.ExpiresAt)
+
+	return session, nil
+}
+
+// suspensionMessage explains to a suspended or banned user why they can't sign in
+func suspensionMessage(user *models.User) string {
+	if user.BannedAt != nil {
+		return "Your account has been banned"
+	}
+	return "Your account is suspended until " + user.SuspendedUntil.UTC().Format(time.RFC1123)
+}
+
+// respondWithSession writes the signed-in user along with the session's CSRF token,
+// which clients must send back in the X-CSRF-Token header on state-changing requests
+func respondWithSession(w http.ResponseWriter, user *models.User, session *models.Session) {
+	w.Header().Set(middleware.CSRFHeaderName, session.CSRFToken)
+	utils.RespondWithJSON(w, http.StatusOK, struct {
+		*models.User
+		CSRFToken string `json:"csrf_token"`
+	}{user, session.CSRFToken})
+}
+
+// sendVerificationEmail creates a verification token for an address and emails the link to it
+func (h *AuthHandler) sendVerificationEmail(userId int, email string, firstName string) error {
+	token, err := models.CreateEmailVerificationToken(h.db, userId, email)
+	if err != nil {
+		return err
+	}
+
+	h.sendMail(mailer.Message{
+		To:      email,
+		Subject: "Confirm your email address",
+		Body: "Hi " + firstName + ",\n\n" +
+			"Please confirm your email address by opening the link below.\n\n" +
+			appURL() + "/verify-email?token=" + token + "\n\n" +
+			"If you didn't create this account, you can ignore this email.",
+	})
+	return nil
+}
+
+// sendMail delivers an email in the background so slow mail servers don't hold up requests
+func (h *AuthHandler) sendMail(msg mailer.Message) {
+	go func() {
+		if err := h.mailer.Send(msg); err != nil {
+			log.Printf("Failed to send email to %s: %v", msg.To, err)
+		}
+	}()
+}
+
+// appURL returns the base URL of the frontend, used to build links in emails
+func appURL() string {
+	if url := os.Getenv("APP_URL"); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+	return "http://localhost:3000"
+}
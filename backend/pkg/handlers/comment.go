@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/hezronokwach/soshi/pkg/middleware"
 	"github.com/hezronokwach/soshi/pkg/models"
 	"github.com/hezronokwach/soshi/pkg/utils"
 
@@ -24,11 +25,11 @@ func NewCommentHandler(db *sql.DB) *CommentHandler {
 // GetPostComments retrieves comments for a post
 func (h *CommentHandler) GetPostComments(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	// user, ok := r.Context().Value("user").(*models.User)
-	// if !ok {
-	// 	utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
-	// 	return
-	// }
+	currentUser, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
 
 	// Get post ID from URL
 	postIdStr := chi.URLParam(r, "postID")
@@ -71,6 +72,7 @@ func (h *CommentHandler) GetPostComments(w http.ResponseWriter, r *http.Request)
 		"page":     page,
 		"limit":    limit,
 		"parentId": parentId,
+		"viewerId": currentUser.ID,
 	}
 
 	comments, err := models.GetPostComments(h.db, postId, options)
@@ -101,7 +103,7 @@ func (h *CommentHandler) GetPostComments(w http.ResponseWriter, r *http.Request)
 // CreateComment creates a new comment
 func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -117,9 +119,10 @@ func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 
 	// Parse request body
 	var req struct {
-		Content  string `json:"content"`
-		ImageURL string `json:"image_url"`
-		ParentID *int   `json:"parent_id"`
+		Content  string         `json:"content"`
+		ImageURL string         `json:"image_url"`
+		Media    []models.Media `json:"media"`
+		ParentID *int           `json:"parent_id"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -140,14 +143,22 @@ func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 		ParentID: req.ParentID,
 		Content:  req.Content,
 		ImageURL: req.ImageURL,
+		Media:    req.Media,
 	}
 
 	commentId, err := models.CreateComment(h.db, comment)
 	if err != nil {
+		if isAttachmentError(err) {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create comment")
 		return
 	}
 
+	// Notify mentioned users who can see it
+	_ = models.NotifyMentions(h.db, models.TagTargetComment, commentId)
+
 	// Get post owner for activity tracking
 	post, err := models.GetPostById(h.db, postId, user.ID)
 	if err == nil {
@@ -179,6 +190,13 @@ func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 
 // GetComment retrieves a comment by ID
 func (h *CommentHandler) GetComment(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
 	// Get comment ID from URL
 	commentIdStr := chi.URLParam(r, "commentID")
 	commentId, err := strconv.Atoi(commentIdStr)
@@ -193,7 +211,8 @@ func (h *CommentHandler) GetComment(w http.ResponseWriter, r *http.Request) {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve comment")
 		return
 	}
-	if comment == nil {
+	// Comments hidden by a moderator are only served to their author
+	if comment == nil || (comment.Hidden && !models.CanViewHiddenContent(user, comment.UserID)) {
 		utils.RespondWithError(w, http.StatusNotFound, "Comment not found")
 		return
 	}
@@ -201,10 +220,66 @@ func (h *CommentHandler) GetComment(w http.ResponseWriter, r *http.Request) {
 	utils.RespondWithJSON(w, http.StatusOK, comment)
 }
 
+// GetRevisions retrieves the earlier versions of a comment on a post the user can view
+func (h *CommentHandler) GetRevisions(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get comment ID from URL
+	commentIdStr := chi.URLParam(r, "commentID")
+	commentId, err := strconv.Atoi(commentIdStr)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid comment ID")
+		return
+	}
+
+	// Get comment
+	comment, err := models.GetCommentById(h.db, commentId)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve comment")
+		return
+	}
+	if comment == nil || (comment.Hidden && !models.CanViewHiddenContent(user, comment.UserID)) {
+		utils.RespondWithError(w, http.StatusNotFound, "Comment not found")
+		return
+	}
+
+	// The comment's history is visible to whoever can view its post
+	post, err := models.GetPostById(h.db, comment.PostID, user.ID)
+	if err != nil {
+		if err == models.ErrCannotViewPost {
+			utils.RespondWithError(w, http.StatusForbidden, "You are not allowed to view this comment")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get post")
+		return
+	}
+	if post == nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Comment not found")
+		return
+	}
+
+	// Get revisions
+	revisions, err := models.GetRevisions(h.db, models.RevisionTargetComment, commentId, user.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve revisions")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"current":   comment,
+		"revisions": revisions,
+	})
+}
+
 // UpdateComment updates a comment
 func (h *CommentHandler) UpdateComment(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -220,8 +295,9 @@ func (h *CommentHandler) UpdateComment(w http.ResponseWriter, r *http.Request) {
 
 	// Parse request body
 	var req struct {
-		Content  string `json:"content"`
-		ImageURL string `json:"image_url"`
+		Content  string         `json:"content"`
+		ImageURL string         `json:"image_url"`
+		Media    []models.Media `json:"media"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -239,14 +315,26 @@ func (h *CommentHandler) UpdateComment(w http.ResponseWriter, r *http.Request) {
 	updates := map[string]interface{}{
 		"content":   req.Content,
 		"image_url": req.ImageURL,
+		"media":     req.Media,
 	}
 
-	err = models.UpdateComment(h.db, commentId, updates, user.ID)
+	err = models.UpdateComment(h.db, commentId, updates, user)
 	if err != nil {
+		if err == models.ErrForbidden {
+			utils.RespondWithError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		if isAttachmentError(err) {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	// Notify mentioned users who can see it
+	_ = models.NotifyMentions(h.db, models.TagTargetComment, commentId)
+
 	// Get updated comment
 	updatedComment, err := models.GetCommentById(h.db, commentId)
 	if err != nil {
@@ -260,7 +348,7 @@ func (h *CommentHandler) UpdateComment(w http.ResponseWriter, r *http.Request) {
 // DeleteComment deletes a comment
 func (h *CommentHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -274,22 +362,13 @@ func (h *CommentHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get post owner ID (for authorization)
-	var postOwnerId int
-	err = h.db.QueryRow(`
-		SELECT p.user_id 
-		FROM comments c
-		JOIN posts p ON c.post_id = p.id
-		WHERE c.id = ?
-	`, commentId).Scan(&postOwnerId)
-	if err != nil && err != sql.ErrNoRows {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve post owner")
-		return
-	}
-
 	// Delete comment
-	err = models.DeleteComment(h.db, commentId, user.ID, postOwnerId)
+	err = models.DeleteComment(h.db, commentId, user)
 	if err != nil {
+		if err == models.ErrForbidden {
+			utils.RespondWithError(w, http.StatusForbidden, err.Error())
+			return
+		}
 		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -300,7 +379,7 @@ func (h *CommentHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
 // GetReactions gets reactions for a comment
 func (h *CommentHandler) GetReactions(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -327,7 +406,7 @@ func (h *CommentHandler) GetReactions(w http.ResponseWriter, r *http.Request) {
 // AddReaction adds a reaction to a comment
 func (h *CommentHandler) AddReaction(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/hezronokwach/soshi/pkg/mailer"
+	"github.com/hezronokwach/soshi/pkg/middleware"
+	"github.com/hezronokwach/soshi/pkg/models"
+	"github.com/hezronokwach/soshi/pkg/utils"
+	"github.com/hezronokwach/soshi/pkg/validation"
+)
+
+// ChangePassword sets a new password for the current user, signs out their other sessions
+// and revokes their personal access tokens
+func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	// Get user and session from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	currentSession, ok := middleware.SessionFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse request body
+	var req struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	v := validation.New()
+	v.Password("new_password", req.NewPassword, validation.PasswordPolicyFromEnv())
+	if !v.Valid() {
+		utils.RespondWithValidationErrors(w, v.Errors)
+		return
+	}
+
+	// Check current password
+	authenticated, err := models.AuthenticateUser(h.db, user.Email, req.CurrentPassword)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Authentication error")
+		return
+	}
+	if authenticated == nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Current password is incorrect")
+		return
+	}
+
+	if err := models.ChangePassword(h.db, user.ID, req.NewPassword, currentSession.Token); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to change password")
+		return
+	}
+
+	h.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Your password was changed",
+		Body: "Hi " + user.FirstName + ",\n\n" +
+			"The password for your account was just changed. Your other devices were signed out " +
+			"and your personal access tokens were revoked.\n\n" +
+			"If this wasn't you, reset your password right away:\n\n" +
+			appURL() + "/forgot-password",
+	})
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Password changed successfully"})
+}
+
+// ChangeEmail starts changing the current user's email address.
+// The change only takes effect once the new address is confirmed through the emailed link.
+func (h *AuthHandler) ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse request body
+	var req struct {
+		CurrentPassword string `json:"current_password"`
+		NewEmail        string `json:"new_email"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	req.NewEmail = strings.TrimSpace(req.NewEmail)
+	v := validation.New()
+	v.Email("new_email", req.NewEmail)
+	v.Check(!strings.EqualFold(req.NewEmail, user.Email), "new_email", "New email is the same as the current one")
+	if !v.Valid() {
+		utils.RespondWithValidationErrors(w, v.Errors)
+		return
+	}
+
+	// Check current password
+	authenticated, err := models.AuthenticateUser(h.db, user.Email, req.CurrentPassword)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Authentication error")
+		return
+	}
+	if authenticated == nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Current password is incorrect")
+		return
+	}
+
+	inUse, err := models.IsEmailInUse(h.db, req.NewEmail, user.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to change email")
+		return
+	}
+	if inUse {
+		utils.RespondWithError(w, http.StatusConflict, models.ErrEmailInUse.Error())
+		return
+	}
+
+	// Create verification token for the new address
+	token, err := models.CreateEmailVerificationToken(h.db, user.ID, req.NewEmail)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to change email")
+		return
+	}
+
+	h.sendMail(mailer.Message{
+		To:      req.NewEmail,
+		Subject: "Confirm your new email address",
+		Body: "Hi " + user.FirstName + ",\n\n" +
+			"Please confirm this is your new email address by opening the link below.\n" +
+			"Your account will keep using " + user.Email + " until you do.\n\n" +
+			appURL() + "/verify-email?token=" + token + "\n\n" +
+			"If you didn't ask for this change, you can ignore this email.",
+	})
+
+	// Let the current address know, in case the account has been taken over
+	h.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Email change requested",
+		Body: "Hi " + user.FirstName + ",\n\n" +
+			"Someone asked to change the email address on your account to " + req.NewEmail + ".\n" +
+			"Nothing changes until the new address is confirmed.\n\n" +
+			"If this wasn't you, change your password right away.",
+	})
+
+	utils.RespondWithJSON(w, http.StatusAccepted, map[string]string{
+		"message":       "Check your new email address to confirm the change",
+		"pending_email": req.NewEmail,
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/hezronokwach/soshi/pkg/middleware"
 	"github.com/hezronokwach/soshi/pkg/models"
 	"github.com/hezronokwach/soshi/pkg/utils"
 
@@ -36,7 +37,7 @@ func (h *GroupHandler) GetGroups(w http.ResponseWriter, r *http.Request) {
 // CreateGroup creates a new group
 func (h *GroupHandler) CreateGroup(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -132,7 +133,7 @@ func (h *GroupHandler) GetGroup(w http.ResponseWriter, r *http.Request) {
 // UpdateGroup updates a group
 func (h *GroupHandler) UpdateGroup(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -156,7 +157,7 @@ func (h *GroupHandler) UpdateGroup(w http.ResponseWriter, r *http.Request) {
 		utils.RespondWithError(w, http.StatusNotFound, "Group not found")
 		return
 	}
-	if group.CreatorID != user.ID {
+	if !models.CanManageGroup(user, group.CreatorID) {
 		utils.RespondWithError(w, http.StatusForbidden, "Only the group creator can update the group")
 		return
 	}
@@ -222,7 +223,7 @@ func (h *GroupHandler) UpdateGroup(w http.ResponseWriter, r *http.Request) {
 // DeleteGroup deletes a group
 func (h *GroupHandler) DeleteGroup(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -246,7 +247,7 @@ func (h *GroupHandler) DeleteGroup(w http.ResponseWriter, r *http.Request) {
 		utils.RespondWithError(w, http.StatusNotFound, "Group not found")
 		return
 	}
-	if group.CreatorID != user.ID {
+	if !models.CanManageGroup(user, group.CreatorID) {
 		utils.RespondWithError(w, http.StatusForbidden, "Only the group creator can delete the group")
 		return
 	}
@@ -264,7 +265,7 @@ func (h *GroupHandler) DeleteGroup(w http.ResponseWriter, r *http.Request) {
 // JoinGroup handles a user joining a group
 func (h *GroupHandler) JoinGroup(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -315,7 +316,7 @@ func (h *GroupHandler) JoinGroup(w http.ResponseWriter, r *http.Request) {
 // LeaveGroup handles a user leaving a group
 func (h *GroupHandler) LeaveGroup(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -342,7 +343,7 @@ func (h *GroupHandler) LeaveGroup(w http.ResponseWriter, r *http.Request) {
 // UpdateMember updates a member's status in a group
 func (h *GroupHandler) UpdateMember(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -409,11 +410,11 @@ func (h *GroupHandler) UpdateMember(w http.ResponseWriter, r *http.Request) {
 	// Handle based on whether it's a join request or invitation response
 	if invitedBy == nil {
 		// It's a join request, only group creator can respond
-		if group.CreatorID != user.ID {
+		if !models.CanManageGroup(user, group.CreatorID) {
 			utils.RespondWithError(w, http.StatusForbidden, "Only the group creator can respond to join requests")
 			return
 		}
-		err = models.RespondToGroupRequest(h.db, groupId, memberId, req.Status, user.ID)
+		err = models.RespondToGroupRequest(h.db, groupId, memberId, req.Status, user)
 	} else if *invitedBy != 0 && memberId == user.ID {
 		// It's an invitation response from the invited user
 		err = models.RespondToGroupInvitation(h.db, groupId, user.ID, req.Status)
@@ -444,7 +445,7 @@ func (h *GroupHandler) UpdateMember(w http.ResponseWriter, r *http.Request) {
 // RemoveMember removes a member from a group
 func (h *GroupHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -475,7 +476,7 @@ func (h *GroupHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
 		utils.RespondWithError(w, http.StatusNotFound, "Group not found")
 		return
 	}
-	if group.CreatorID != user.ID {
+	if !models.CanManageGroup(user, group.CreatorID) {
 		utils.RespondWithError(w, http.StatusForbidden, "Only the group creator can remove members")
 		return
 	}
@@ -496,7 +497,7 @@ func (h *GroupHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
 // GetPosts retrieves posts in a group
 func (h *GroupHandler) GetPosts(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -539,7 +540,7 @@ func (h *GroupHandler) GetPosts(w http.ResponseWriter, r *http.Request) {
 // CreatePost creates a new post in a group
 func (h *GroupHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -555,8 +556,10 @@ func (h *GroupHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 
 	// Parse request body
 	var req struct {
-		Content  string `json:"content"`
-		ImageURL string `json:"image_url"`
+		Content  string         `json:"content"`
+		ImageURL string         `json:"image_url"`
+		Media    []models.Media `json:"media"`
+		Poll     *models.Poll   `json:"poll"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -565,25 +568,32 @@ func (h *GroupHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate required fields - either content or image is required
-	if req.Content == "" && req.ImageURL == "" {
+	if req.Content == "" && req.ImageURL == "" && len(req.Media) == 0 {
 		utils.RespondWithError(w, http.StatusBadRequest, "Content or image is required")
 		return
 	}
 
 	// Create post
-	postId, err := models.CreateGroupPost(h.db, groupId, user.ID, req.Content, req.ImageURL)
+	postId, err := models.CreateGroupPost(h.db, groupId, user.ID, req.Content, req.ImageURL, req.Media, req.Poll)
 	if err != nil {
+		if isAttachmentError(err) || isPollError(err) {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	// Notify mentioned users who can see it
+	_ = models.NotifyMentions(h.db, models.TagTargetGroupPost, postId)
+
 	utils.RespondWithJSON(w, http.StatusCreated, map[string]int{"id": postId})
 }
 
 // GetEvents retrieves events in a group
 func (h *GroupHandler) GetEvents(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -610,7 +620,7 @@ func (h *GroupHandler) GetEvents(w http.ResponseWriter, r *http.Request) {
 // CreateEvent creates a new event in a group
 func (h *GroupHandler) CreateEvent(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -678,7 +688,7 @@ func (h *GroupHandler) CreateEvent(w http.ResponseWriter, r *http.Request) {
 // RespondToEvent handles a user responding to an event
 func (h *GroupHandler) RespondToEvent(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -721,7 +731,7 @@ func (h *GroupHandler) RespondToEvent(w http.ResponseWriter, r *http.Request) {
 // AddGroupPostReaction handles adding/updating reactions to group posts
 func (h *GroupHandler) AddGroupPostReaction(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "User not authenticated")
 		return
@@ -764,7 +774,7 @@ func (h *GroupHandler) AddGroupPostReaction(w http.ResponseWriter, r *http.Reque
 // GetGroupPostReactions handles getting reactions for a group post
 func (h *GroupHandler) GetGroupPostReactions(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "User not authenticated")
 		return
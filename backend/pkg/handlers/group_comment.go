@@ -8,6 +8,7 @@ import (
 	"strconv"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/hezronokwach/soshi/pkg/middleware"
 	"github.com/hezronokwach/soshi/pkg/models"
 	"github.com/hezronokwach/soshi/pkg/utils"
 )
@@ -23,7 +24,7 @@ func NewGroupCommentHandler(db *sql.DB) *GroupCommentHandler {
 // GetGroupPostComments retrieves comments for a group post
 func (h *GroupCommentHandler) GetGroupPostComments(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -83,7 +84,7 @@ func (h *GroupCommentHandler) GetGroupPostComments(w http.ResponseWriter, r *htt
 // CreateGroupPostComment creates a new comment on a group post
 func (h *GroupCommentHandler) CreateGroupPostComment(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -99,9 +100,10 @@ func (h *GroupCommentHandler) CreateGroupPostComment(w http.ResponseWriter, r *h
 
 	// Parse request body
 	var req struct {
-		Content  string `json:"content"`
-		ImageURL string `json:"image_url"`
-		ParentID *int   `json:"parent_id"`
+		Content  string         `json:"content"`
+		ImageURL string         `json:"image_url"`
+		Media    []models.Media `json:"media"`
+		ParentID *int           `json:"parent_id"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -110,7 +112,7 @@ func (h *GroupCommentHandler) CreateGroupPostComment(w http.ResponseWriter, r *h
 	}
 
 	// Validate required fields - either content or image is required
-	if req.Content == "" && req.ImageURL == "" {
+	if req.Content == "" && req.ImageURL == "" && len(req.Media) == 0 {
 		utils.RespondWithError(w, http.StatusBadRequest, "Content or image is required")
 		return
 	}
@@ -122,14 +124,22 @@ func (h *GroupCommentHandler) CreateGroupPostComment(w http.ResponseWriter, r *h
 		ParentID:    req.ParentID,
 		Content:     req.Content,
 		ImageURL:    req.ImageURL,
+		Media:       req.Media,
 	}
 
 	commentId, err := models.CreateGroupPostComment(h.db, comment)
 	if err != nil {
+		if isAttachmentError(err) {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	// Notify mentioned users who can see it
+	_ = models.NotifyMentions(h.db, models.TagTargetGroupPostComment, commentId)
+
 	// Get created comment
 	createdComment, err := models.GetGroupPostCommentById(h.db, commentId)
 	if err != nil {
@@ -147,7 +157,7 @@ func (h *GroupCommentHandler) CreateGroupPostComment(w http.ResponseWriter, r *h
 // GetGroupPostComment retrieves a specific group post comment
 func (h *GroupCommentHandler) GetGroupPostComment(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -193,10 +203,64 @@ func (h *GroupCommentHandler) GetGroupPostComment(w http.ResponseWriter, r *http
 	utils.RespondWithJSON(w, http.StatusOK, comment)
 }
 
+// GetGroupPostCommentRevisions retrieves the earlier versions of a group post comment
+func (h *GroupCommentHandler) GetGroupPostCommentRevisions(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get comment ID from URL
+	commentIdStr := chi.URLParam(r, "commentID")
+	commentId, err := strconv.Atoi(commentIdStr)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid comment ID")
+		return
+	}
+
+	comment, err := models.GetGroupPostCommentById(h.db, commentId)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve comment")
+		return
+	}
+
+	if comment == nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Comment not found")
+		return
+	}
+
+	// Verify user has access to this comment (must be group member)
+	var groupId int
+	err = h.db.QueryRow("SELECT group_id FROM group_posts WHERE id = ?", comment.GroupPostID).Scan(&groupId)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to verify access")
+		return
+	}
+
+	isMember, err := models.IsGroupMember(h.db, groupId, user.ID)
+	if err != nil || !isMember {
+		utils.RespondWithError(w, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	revisions, err := models.GetRevisions(h.db, models.RevisionTargetGroupPostComment, commentId, user.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve revisions")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"current":   comment,
+		"revisions": revisions,
+	})
+}
+
 // UpdateGroupPostComment updates a group post comment
 func (h *GroupCommentHandler) UpdateGroupPostComment(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -212,8 +276,9 @@ func (h *GroupCommentHandler) UpdateGroupPostComment(w http.ResponseWriter, r *h
 
 	// Parse request body
 	var req struct {
-		Content  string `json:"content"`
-		ImageURL string `json:"image_url"`
+		Content  string         `json:"content"`
+		ImageURL string         `json:"image_url"`
+		Media    []models.Media `json:"media"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -228,12 +293,23 @@ func (h *GroupCommentHandler) UpdateGroupPostComment(w http.ResponseWriter, r *h
 	}
 
 	// Update comment
-	err = models.UpdateGroupPostComment(h.db, commentId, user.ID, req.Content, req.ImageURL)
+	err = models.UpdateGroupPostComment(h.db, commentId, user, req.Content, req.ImageURL, req.Media)
 	if err != nil {
+		if err == models.ErrForbidden {
+			utils.RespondWithError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		if isAttachmentError(err) {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	// Notify mentioned users who can see it
+	_ = models.NotifyMentions(h.db, models.TagTargetGroupPostComment, commentId)
+
 	// Get updated comment
 	updatedComment, err := models.GetGroupPostCommentById(h.db, commentId)
 	if err != nil {
@@ -247,7 +323,7 @@ func (h *GroupCommentHandler) UpdateGroupPostComment(w http.ResponseWriter, r *h
 // DeleteGroupPostComment deletes a group post comment
 func (h *GroupCommentHandler) DeleteGroupPostComment(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -262,8 +338,12 @@ func (h *GroupCommentHandler) DeleteGroupPostComment(w http.ResponseWriter, r *h
 	}
 
 	// Delete comment
-	err = models.DeleteGroupPostComment(h.db, commentId, user.ID)
+	err = models.DeleteGroupPostComment(h.db, commentId, user)
 	if err != nil {
+		if err == models.ErrForbidden {
+			utils.RespondWithError(w, http.StatusForbidden, err.Error())
+			return
+		}
 		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -274,7 +354,7 @@ func (h *GroupCommentHandler) DeleteGroupPostComment(w http.ResponseWriter, r *h
 // AddGroupPostCommentReaction adds or updates a reaction to a group post comment
 func (h *GroupCommentHandler) AddGroupPostCommentReaction(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -317,7 +397,7 @@ func (h *GroupCommentHandler) AddGroupPostCommentReaction(w http.ResponseWriter,
 // GetGroupPostCommentReactions retrieves reactions for a group post comment
 func (h *GroupCommentHandler) GetGroupPostCommentReactions(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -8,6 +8,7 @@ import (
 	"strconv"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/hezronokwach/soshi/pkg/middleware"
 	"github.com/hezronokwach/soshi/pkg/models"
 	"github.com/hezronokwach/soshi/pkg/utils"
 	"github.com/hezronokwach/soshi/pkg/websocket"
@@ -25,7 +26,7 @@ func NewMessageHandler(db *sql.DB, hub *websocket.Hub) *MessageHandler {
 // SendPrivateMessage handles sending a private message
 func (h *MessageHandler) SendPrivateMessage(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -41,21 +42,26 @@ func (h *MessageHandler) SendPrivateMessage(w http.ResponseWriter, r *http.Reque
 
 	// Parse request body
 	var req struct {
-		Content string `json:"content"`
+		Content string         `json:"content"`
+		Media   []models.Media `json:"media"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
-	if req.Content == "" {
+	if req.Content == "" && len(req.Media) == 0 {
 		utils.RespondWithError(w, http.StatusBadRequest, "Message content cannot be empty")
 		return
 	}
 
 	// Create message
-	message, err := models.CreatePrivateMessage(h.db, user.ID, recipientID, req.Content)
+	message, err := models.CreatePrivateMessage(h.db, user.ID, recipientID, req.Content, req.Media)
 	if err != nil {
+		if isAttachmentError(err) {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create message")
 		return
 	}
@@ -69,7 +75,7 @@ func (h *MessageHandler) SendPrivateMessage(w http.ResponseWriter, r *http.Reque
 // GetPrivateMessages retrieves messages between current user and another user
 func (h *MessageHandler) GetPrivateMessages(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -111,7 +117,7 @@ func (h *MessageHandler) GetPrivateMessages(w http.ResponseWriter, r *http.Reque
 // GetConversations retrieves all conversations for the current user
 func (h *MessageHandler) GetConversations(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -135,7 +141,7 @@ func (h *MessageHandler) GetConversations(w http.ResponseWriter, r *http.Request
 // GetUnreadCount returns the count of unread messages for the current user
 func (h *MessageHandler) GetUnreadCount(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -158,7 +164,7 @@ func (h *MessageHandler) GetUnreadCount(w http.ResponseWriter, r *http.Request)
 // MarkMessagesAsRead marks messages as read
 func (h *MessageHandler) MarkMessagesAsRead(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -188,7 +194,7 @@ func (h *MessageHandler) MarkMessagesAsRead(w http.ResponseWriter, r *http.Reque
 // GetUnreadMessageCount gets the total unread message count for the current user
 func (h *MessageHandler) GetUnreadMessageCount(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -207,7 +213,7 @@ func (h *MessageHandler) GetUnreadMessageCount(w http.ResponseWriter, r *http.Re
 // SendGroupMessage handles sending a message to a group chat
 func (h *MessageHandler) SendGroupMessage(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -223,21 +229,26 @@ func (h *MessageHandler) SendGroupMessage(w http.ResponseWriter, r *http.Request
 
 	// Parse request body
 	var req struct {
-		Content string `json:"content"`
+		Content string         `json:"content"`
+		Media   []models.Media `json:"media"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
-	if req.Content == "" {
+	if req.Content == "" && len(req.Media) == 0 {
 		utils.RespondWithError(w, http.StatusBadRequest, "Message content cannot be empty")
 		return
 	}
 
 	// Create group message
-	messageID, err := models.CreateGroupMessage(h.db, user.ID, groupID, req.Content)
+	messageID, err := models.CreateGroupMessage(h.db, user.ID, groupID, req.Content, req.Media)
 	if err != nil {
+		if isAttachmentError(err) {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -258,7 +269,7 @@ func (h *MessageHandler) SendGroupMessage(w http.ResponseWriter, r *http.Request
 // GetGroupMessages retrieves messages for a group chat
 func (h *MessageHandler) GetGroupMessages(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
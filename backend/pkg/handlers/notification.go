@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/hezronokwach/soshi/pkg/middleware"
 	"github.com/hezronokwach/soshi/pkg/models"
 	"github.com/hezronokwach/soshi/pkg/utils"
 )
@@ -20,7 +21,7 @@ func NewNotificationHandler(db *sql.DB) *NotificationHandler {
 // GetNotifications retrieves notifications for the current user
 func (h *NotificationHandler) GetNotifications(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -42,20 +43,32 @@ func (h *NotificationHandler) GetNotifications(w http.ResponseWriter, r *http.Re
 		}
 	}
 
+	after, err := cursorFromRequest(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		return
+	}
+
 	// Get notifications
-	notifications, err := models.GetUserNotifications(h.db, user.ID, page, limit)
+	notifications, next, err := models.GetUserNotifications(h.db, user.ID, after, page, limit)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve notifications")
 		return
 	}
 
-	utils.RespondWithJSON(w, http.StatusOK, notifications)
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"notifications": notifications,
+		"page":          page,
+		"limit":         limit,
+		"hasMore":       next != nil,
+		"nextCursor":    nextCursorValue(next),
+	})
 }
 
 // MarkNotificationAsRead marks a notification as read
 func (h *NotificationHandler) MarkNotificationAsRead(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -85,7 +98,7 @@ func (h *NotificationHandler) MarkNotificationAsRead(w http.ResponseWriter, r *h
 // MarkAllNotificationsAsRead marks all notifications as read for the current user
 func (h *NotificationHandler) MarkAllNotificationsAsRead(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -107,7 +120,7 @@ func (h *NotificationHandler) MarkAllNotificationsAsRead(w http.ResponseWriter,
 // GetUnreadCount gets the count of unread notifications
 func (h *NotificationHandler) GetUnreadCount(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -0,0 +1,339 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hezronokwach/soshi/pkg/middleware"
+	"github.com/hezronokwach/soshi/pkg/models"
+	"github.com/hezronokwach/soshi/pkg/oidc"
+	"github.com/hezronokwach/soshi/pkg/utils"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// OIDCLogin starts signing in through the configured OpenID provider.
+// The optional redirect query parameter is the app path to land on afterwards.
+func (h *AuthHandler) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if !h.oidcClient.Enabled() {
+		utils.RespondWithError(w, http.StatusNotFound, oidc.ErrNotConfigured.Error())
+		return
+	}
+
+	authURL, err := h.beginOIDCLogin(w, r, r.URL.Query().Get("redirect"), 0)
+	if err != nil {
+		log.Printf("Failed to start OIDC login: %v", err)
+		utils.RespondWithError(w, http.StatusBadGateway, "Failed to reach the identity provider")
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// LinkOIDCIdentity starts linking an OpenID provider account to the current user.
+// It returns the provider URL for the client to navigate to.
+func (h *AuthHandler) LinkOIDCIdentity(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if !h.oidcClient.Enabled() {
+		utils.RespondWithError(w, http.StatusNotFound, oidc.ErrNotConfigured.Error())
+		return
+	}
+
+	authURL, err := h.beginOIDCLogin(w, r, r.URL.Query().Get("redirect"), user.ID)
+	if err != nil {
+		log.Printf("Failed to start OIDC link: %v", err)
+		utils.RespondWithError(w, http.StatusBadGateway, "Failed to reach the identity provider")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"url": authURL})
+}
+
+// OIDCCallback finishes a login or link when the provider redirects back.
+// The browser is sent on to the app either way, with any error in the URL fragment.
+func (h *AuthHandler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if !h.oidcClient.Enabled() {
+		utils.RespondWithError(w, http.StatusNotFound, oidc.ErrNotConfigured.Error())
+		return
+	}
+
+	query := r.URL.Query()
+
+	// The state must come back to the browser that started the login, otherwise someone
+	// could finish their own login in another person's browser and sign them in as themselves
+	cookie, err := r.Cookie(utils.OIDCStateCookieName)
+	utils.ClearOIDCStateCookie(w)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(query.Get("state"))) != 1 {
+		redirectWithError(w, r, "/login", "Your sign-in attempt expired, please try again")
+		return
+	}
+
+	// Look up the login attempt; this also makes the state single-use
+	state, err := models.ConsumeOIDCState(h.db, query.Get("state"))
+	if err != nil {
+		if err != models.ErrInvalidOIDCState {
+			log.Printf("Failed to load OIDC state: %v", err)
+		}
+		redirectWithError(w, r, "/login", "Your sign-in attempt expired, please try again")
+		return
+	}
+
+	failurePath := "/login"
+	if state.LinkUserID != 0 {
+		failurePath = state.RedirectTo
+	}
+
+	// The provider reports refusals, such as the user cancelling, as query parameters
+	if providerError := query.Get("error"); providerError != "" {
+		redirectWithError(w, r, failurePath, "Sign-in was cancelled or refused by the identity provider")
+		return
+	}
+
+	claims, err := h.oidcClient.Exchange(r.Context(), query.Get("code"), state.CodeVerifier, state.Nonce)
+	if err != nil {
+		log.Printf("OIDC code exchange failed: %v", err)
+		redirectWithError(w, r, failurePath, "Could not sign in with the identity provider")
+		return
+	}
+
+	// Linking an identity to an account that is already signed in
+	if state.LinkUserID != 0 {
+		err := models.CreateUserIdentity(h.db, state.LinkUserID, claims.Issuer, claims.Subject, claims.Email)
+		if err != nil {
+			if err == models.ErrIdentityInUse {
+				redirectWithError(w, r, failurePath, err.Error())
+				return
+			}
+			log.Printf("Failed to link identity: %v", err)
+			redirectWithError(w, r, failurePath, "Failed to link account")
+			return
+		}
+		http.Redirect(w, r, appURL()+state.RedirectTo, http.StatusFound)
+		return
+	}
+
+	user, err := h.resolveOIDCUser(claims)
+	if err != nil {
+		if errors.Is(err, errOIDCAccountExists) || errors.Is(err, errOIDCNoEmail) {
+			redirectWithError(w, r, failurePath, err.Error())
+			return
+		}
+		log.Printf("Failed to resolve OIDC user: %v", err)
+		redirectWithError(w, r, failurePath, "Could not sign in with the identity provider")
+		return
+	}
+
+	// Suspended and banned users can't sign in
+	if user.IsSuspended() {
+		redirectWithError(w, r, failurePath, suspensionMessage(user))
+		return
+	}
+
+	// Enrolled users still owe a second factor, which the login page collects
+	twoFactorEnabled, err := models.IsTwoFactorEnabled(h.db, user.ID)
+	if err != nil {
+		redirectWithError(w, r, failurePath, "Authentication error")
+		return
+	}
+	if twoFactorEnabled {
+		pendingToken, err := models.CreatePendingLogin(h.db, user.ID)
+		if err != nil {
+			redirectWithError(w, r, failurePath, "Authentication error")
+			return
+		}
+		fragment := url.Values{}
+		fragment.Set("two_factor_required", "1")
+		fragment.Set("pending_token", pendingToken)
+		http.Redirect(w, r, appURL()+"/login#"+fragment.Encode(), http.StatusFound)
+		return
+	}
+
+	// Create session
+	if _, err := h.startSession(w, r, user); err != nil {
+		redirectWithError(w, r, failurePath, "Failed to create session")
+		return
+	}
+
+	http.Redirect(w, r, appURL()+state.RedirectTo, http.StatusFound)
+}
+
+// GetIdentities lists the OpenID provider accounts linked to the current user
+func (h *AuthHandler) GetIdentities(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	identities, err := models.GetUserIdentities(h.db, user.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve linked accounts")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, identities)
+}
+
+// UnlinkIdentity removes one of the current user's linked OpenID provider accounts
+func (h *AuthHandler) UnlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get identity ID from URL
+	identityId, err := strconv.Atoi(chi.URLParam(r, "identityID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid identity ID")
+		return
+	}
+
+	err = models.DeleteUserIdentity(h.db, identityId, user.ID)
+	if err != nil {
+		switch err {
+		case models.ErrIdentityNotFound:
+			utils.RespondWithError(w, http.StatusNotFound, "Linked account not found")
+		case models.ErrLastSignInMethod:
+			utils.RespondWithError(w, http.StatusConflict, err.Error())
+		default:
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to unlink account")
+		}
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Account unlinked successfully"})
+}
+
+var (
+	errOIDCAccountExists = errors.New("an account with this email already exists, sign in with your password and link it from your settings")
+	errOIDCNoEmail       = errors.New("the identity provider did not share an email address")
+)
+
+// resolveOIDCUser finds the user an ID token signs in as: the user already linked to the
+// identity, else an existing user with the same email verified on both sides, else a new user
+func (h *AuthHandler) resolveOIDCUser(claims *oidc.Claims) (*models.User, error) {
+	identity, err := models.GetUserIdentity(h.db, claims.Issuer, claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if identity != nil {
+		if err := models.TouchUserIdentity(h.db, identity.ID, claims.Email); err != nil {
+			return nil, err
+		}
+		user, err := models.GetUserById(h.db, identity.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, errors.New("linked user not found")
+		}
+		return user, nil
+	}
+
+	if claims.Email == "" {
+		return nil, errOIDCNoEmail
+	}
+
+	user, err := models.GetUserByEmail(h.db, claims.Email)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		userId, err := models.CreateExternalUser(h.db, models.ExternalAccount{
+			Issuer:        claims.Issuer,
+			Subject:       claims.Subject,
+			Email:         claims.Email,
+			EmailVerified: bool(claims.EmailVerified),
+			FirstName:     claims.GivenName,
+			LastName:      claims.FamilyName,
+			Avatar:        claims.Picture,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return models.GetUserById(h.db, userId)
+	}
+
+	// Only trust the match when both the provider and we have checked the address.
+	// Otherwise anyone could claim an existing account by its email, or pre-register
+	// someone else's address and keep access once they sign in with the provider.
+	if !bool(claims.EmailVerified) || user.EmailVerifiedAt == nil {
+		return nil, errOIDCAccountExists
+	}
+	if err := models.CreateUserIdentity(h.db, user.ID, claims.Issuer, claims.Subject, claims.Email); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// beginOIDCLogin stores a new login attempt, ties it to the browser with a cookie
+// and returns the provider URL for it
+func (h *AuthHandler) beginOIDCLogin(w http.ResponseWriter, r *http.Request, redirectTo string, linkUserId int) (string, error) {
+	if !isAppPath(redirectTo) {
+		redirectTo = "/feed"
+		if linkUserId != 0 {
+			redirectTo = "/settings"
+		}
+	}
+
+	state, err := oidc.RandomString()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		return "", err
+	}
+	codeVerifier, err := oidc.RandomString()
+	if err != nil {
+		return "", err
+	}
+
+	// Build the URL first so a provider outage doesn't leave a stored state behind
+	authURL, err := h.oidcClient.AuthCodeURL(r.Context(), state, nonce, codeVerifier)
+	if err != nil {
+		return "", err
+	}
+
+	err = models.CreateOIDCState(h.db, state, models.OIDCState{
+		CodeVerifier: codeVerifier,
+		Nonce:        nonce,
+		RedirectTo:   redirectTo,
+		LinkUserID:   linkUserId,
+	})
+	if err != nil {
+		return "", err
+	}
+	utils.SetOIDCStateCookie(w, state, time.Now().Add(models.OIDCStateTTL))
+
+	return authURL, nil
+}
+
+// isAppPath reports whether a redirect target is a path within the app.
+// Anything else, including protocol-relative URLs, could send users to another site.
+func isAppPath(path string) bool {
+	return strings.HasPrefix(path, "/") && !strings.HasPrefix(path, "//") && !strings.Contains(path, "\\")
+}
+
+// redirectWithError sends the browser to an app page with an error message in the URL fragment
+func redirectWithError(w http.ResponseWriter, r *http.Request, path string, message string) {
+	fragment := url.Values{}
+	fragment.Set("error", message)
+	http.Redirect(w, r, appURL()+path+"#"+fragment.Encode(), http.StatusFound)
+}
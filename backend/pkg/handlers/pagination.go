@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/hezronokwach/soshi/pkg/models"
+)
+
+// cursorFromRequest reads the optional cursor query parameter of a list. Lists page by
+// cursor when one is given and fall back to the page parameter otherwise.
+func cursorFromRequest(r *http.Request) (*models.Cursor, error) {
+	encoded := r.URL.Query().Get("cursor")
+	if encoded == "" {
+		return nil, nil
+	}
+	return models.DecodeCursor(encoded)
+}
+
+// nextCursorValue is the nextCursor returned with a page: the encoded cursor of the next
+// page, or null on the last page
+func nextCursorValue(next *models.Cursor) interface{} {
+	if next == nil {
+		return nil
+	}
+	return next.Encode()
+}
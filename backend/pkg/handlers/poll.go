@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hezronokwach/soshi/pkg/middleware"
+	"github.com/hezronokwach/soshi/pkg/models"
+	"github.com/hezronokwach/soshi/pkg/utils"
+	"github.com/hezronokwach/soshi/pkg/websocket"
+)
+
+// PollHandler handles poll related requests
+type PollHandler struct {
+	db  *sql.DB
+	hub *websocket.Hub
+}
+
+// NewPollHandler creates a new PollHandler
+func NewPollHandler(db *sql.DB, hub *websocket.Hub) *PollHandler {
+	return &PollHandler{db: db, hub: hub}
+}
+
+// GetPoll retrieves a poll and its results
+func (h *PollHandler) GetPoll(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get poll ID from URL
+	pollId, err := strconv.Atoi(chi.URLParam(r, "pollID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid poll ID")
+		return
+	}
+
+	// Get poll, checking the user can see it
+	poll, err := models.GetPoll(h.db, pollId, user.ID)
+	if err != nil {
+		if err == models.ErrPollNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, "Poll not found")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get poll")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, poll)
+}
+
+// Vote records the user's vote on a poll, replacing any earlier vote
+func (h *PollHandler) Vote(w http.ResponseWriter, r *http.Request) {
+	// Parse request body
+	var req struct {
+		OptionIDs []int `json:"option_ids"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if len(req.OptionIDs) == 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "At least one option is required")
+		return
+	}
+
+	h.vote(w, r, req.OptionIDs)
+}
+
+// WithdrawVote removes the user's vote from a poll
+func (h *PollHandler) WithdrawVote(w http.ResponseWriter, r *http.Request) {
+	h.vote(w, r, nil)
+}
+
+// vote replaces the user's vote and pushes the new results to everyone who can see the poll
+func (h *PollHandler) vote(w http.ResponseWriter, r *http.Request, optionIds []int) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get poll ID from URL
+	pollId, err := strconv.Atoi(chi.URLParam(r, "pollID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid poll ID")
+		return
+	}
+
+	// Vote
+	poll, err := models.VotePoll(h.db, pollId, user.ID, optionIds)
+	if err != nil {
+		switch {
+		case err == models.ErrPollNotFound:
+			utils.RespondWithError(w, http.StatusNotFound, "Poll not found")
+		case err == models.ErrPollClosed:
+			utils.RespondWithError(w, http.StatusConflict, err.Error())
+		case isPollError(err):
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		default:
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to record vote")
+		}
+		return
+	}
+
+	// Push the new results live. Don't fail the request if they can't be loaded.
+	if results, err := models.GetPollResults(h.db, pollId); err == nil {
+		h.hub.BroadcastPollResults(results)
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, poll)
+}
+
+// isPollError reports whether an error is about the poll or vote a request sent
+func isPollError(err error) bool {
+	switch err {
+	case models.ErrTooFewPollOptions, models.ErrTooManyPollOptions, models.ErrInvalidPollOption,
+		models.ErrDuplicatePollOption, models.ErrInvalidPollCloseTime, models.ErrInvalidPollVote:
+		return true
+	}
+	return false
+}
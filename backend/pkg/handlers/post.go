@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/hezronokwach/soshi/pkg/middleware"
 	"github.com/hezronokwach/soshi/pkg/models"
 	"github.com/hezronokwach/soshi/pkg/utils"
 
@@ -16,14 +19,20 @@ type PostHandler struct {
 	db *sql.DB
 }
 
+// feedScorers are the ranked feed orders, by the sort parameter that selects them.
+// Without one, or with sort=latest, the feed is newest first.
+var feedScorers = map[string]models.FeedScorer{
+	"top": models.EngagementScore,
+}
+
 func NewPostHandler(db *sql.DB) *PostHandler {
 	return &PostHandler{db: db}
 }
 
-// GetPosts retrieves posts for the feed
+// GetPosts retrieves posts for the feed, newest first, or ranked by engagement with sort=top
 func (h *PostHandler) GetPosts(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -46,20 +55,59 @@ func (h *PostHandler) GetPosts(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	after, err := cursorFromRequest(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		return
+	}
+
+	// Ranked feeds aren't in date order, so they are paged by page number only
+	if sort := r.URL.Query().Get("sort"); sort != "" && sort != "latest" {
+		scorer, ok := feedScorers[sort]
+		if !ok {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid sort: "+sort)
+			return
+		}
+		if after != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Cursors can't be used with sort="+sort)
+			return
+		}
+
+		posts, hasMore, err := models.GetRankedFeedPosts(h.db, user.ID, scorer, page, limit)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve posts")
+			return
+		}
+		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"posts":      posts,
+			"page":       page,
+			"limit":      limit,
+			"hasMore":    hasMore,
+			"nextCursor": nil,
+		})
+		return
+	}
+
 	// Get posts
-	posts, err := models.GetFeedPosts(h.db, user.ID, page, limit, privacy)
+	posts, next, err := models.GetFeedPosts(h.db, user.ID, after, page, limit, privacy)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve posts")
 		return
 	}
 
-	utils.RespondWithJSON(w, http.StatusOK, posts)
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"posts":      posts,
+		"page":       page,
+		"limit":      limit,
+		"hasMore":    next != nil,
+		"nextCursor": nextCursorValue(next),
+	})
 }
 
 // CreatePost creates a new post
 func (h *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -67,10 +115,16 @@ func (h *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 
 	// Parse request body
 	var req struct {
-		Content       string `json:"content"`
-		ImageURL      string `json:"image_url"`
-		Privacy       string `json:"privacy"`
-		SelectedUsers []int  `json:"selected_users"`
+		Content         string         `json:"content"`
+		ImageURL        string         `json:"image_url"`
+		Media           []models.Media `json:"media"`
+		Privacy         string         `json:"privacy"`
+		SelectedUsers   []int          `json:"selected_users"`
+		Status          string         `json:"status"`
+		PublishAt       *time.Time     `json:"publish_at"`
+		SharedPostID    *int           `json:"shared_post_id"`
+		Poll            *models.Poll   `json:"poll"`
+		AudienceListIDs []int          `json:"audience_list_ids"` // shares a private post with the user's audience lists
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -78,8 +132,8 @@ func (h *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate required fields
-	if req.Content == "" {
+	// Validate required fields. Reposts share another post without adding content.
+	if req.Content == "" && req.SharedPostID == nil {
 		utils.RespondWithError(w, http.StatusBadRequest, "Content is required")
 		return
 	}
@@ -91,25 +145,41 @@ func (h *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 
 	// Create post
 	post := models.Post{
-		UserID:        user.ID,
-		Content:       req.Content,
-		ImageURL:      req.ImageURL,
-		Privacy:       req.Privacy,
-		SelectedUsers: req.SelectedUsers,
+		UserID:          user.ID,
+		Content:         req.Content,
+		ImageURL:        req.ImageURL,
+		Media:           req.Media,
+		Privacy:         req.Privacy,
+		SelectedUsers:   req.SelectedUsers,
+		Status:          req.Status,
+		PublishAt:       req.PublishAt,
+		SharedPostID:    req.SharedPostID,
+		Poll:            req.Poll,
+		AudienceListIDs: req.AudienceListIDs,
 	}
 
 	postId, err := models.CreatePost(h.db, post)
 	if err != nil {
+		if err == models.ErrInvalidPostStatus || err == models.ErrInvalidPublishTime || isAttachmentError(err) || isPollError(err) {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err == models.ErrAudienceListNotFound {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err == models.ErrCannotSharePost {
+			utils.RespondWithError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		if err == models.ErrAlreadyReposted {
+			utils.RespondWithError(w, http.StatusConflict, err.Error())
+			return
+		}
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create post")
 		return
 	}
 
-	// Create activity record
-	if err := models.CreatePostActivity(h.db, user.ID, postId, req.Content); err != nil {
-		// Log error but don't fail the request
-		// In production, you might want to use a proper logger
-	}
-
 	// Get created post
 	createdPost, err := models.GetPostById(h.db, postId, user.ID)
 	if err != nil {
@@ -117,13 +187,27 @@ func (h *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Create activity record. Drafts and scheduled posts get theirs when they are published.
+	// Shares are recorded as shares and tell the author of the shared post.
+	if createdPost.Status == models.PostStatusPublished && createdPost.SharedPostID != nil {
+		_ = models.RecordPostShare(h.db, createdPost)
+	} else if createdPost.Status == models.PostStatusPublished {
+		if err := models.CreatePostActivity(h.db, user.ID, postId, req.Content); err != nil {
+			// Log error but don't fail the request
+			// In production, you might want to use a proper logger
+		}
+	}
+
+	// Notify mentioned users who can see it. For drafts and scheduled posts that is nobody yet.
+	_ = models.NotifyMentions(h.db, models.TagTargetPost, postId)
+
 	utils.RespondWithJSON(w, http.StatusCreated, createdPost)
 }
 
 // UpdatePost updates an existing post
 func (h *PostHandler) UpdatePost(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -131,10 +215,12 @@ func (h *PostHandler) UpdatePost(w http.ResponseWriter, r *http.Request) {
 
 	// Parse request body
 	var req struct {
-		ID            int    `json:"id"`
-		Content       string `json:"content"`
-		Privacy       string `json:"privacy"`
-		SelectedUsers []int  `json:"selected_users"`
+		ID              int            `json:"id"`
+		Content         string         `json:"content"`
+		Privacy         string         `json:"privacy"`
+		SelectedUsers   []int          `json:"selected_users"`
+		Media           []models.Media `json:"media"`             // replaces the attachments when given
+		AudienceListIDs []int          `json:"audience_list_ids"` // replaces the audience lists when given, kept otherwise
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -159,13 +245,30 @@ func (h *PostHandler) UpdatePost(w http.ResponseWriter, r *http.Request) {
 		"privacy":        req.Privacy,
 		"selected_users": req.SelectedUsers,
 	}
+	if req.Media != nil {
+		updates["media"] = req.Media
+	}
+	if req.AudienceListIDs != nil {
+		updates["audience_list_ids"] = req.AudienceListIDs
+	}
 
-	err := models.UpdatePost(h.db, req.ID, updates, user.ID)
+	err := models.UpdatePost(h.db, req.ID, updates, user)
 	if err != nil {
+		if err == models.ErrForbidden {
+			utils.RespondWithError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		if isAttachmentError(err) || err == models.ErrAudienceListNotFound {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	// Notify users newly mentioned, or newly able to see the post
+	_ = models.NotifyMentions(h.db, models.TagTargetPost, req.ID)
+
 	// Get updated post
 	updatedPost, err := models.GetPostById(h.db, req.ID, user.ID)
 	if err != nil {
@@ -179,7 +282,7 @@ func (h *PostHandler) UpdatePost(w http.ResponseWriter, r *http.Request) {
 // DeletePost deletes a post
 func (h *PostHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -202,8 +305,12 @@ func (h *PostHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Delete post
-	err := models.DeletePost(h.db, req.ID, user.ID)
+	err := models.DeletePost(h.db, req.ID, user)
 	if err != nil {
+		if err == models.ErrForbidden {
+			utils.RespondWithError(w, http.StatusForbidden, err.Error())
+			return
+		}
 		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -214,7 +321,7 @@ func (h *PostHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
 // GetReactions gets reactions for a post
 func (h *PostHandler) GetReactions(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -238,10 +345,195 @@ func (h *PostHandler) GetReactions(w http.ResponseWriter, r *http.Request) {
 	utils.RespondWithJSON(w, http.StatusOK, reactions)
 }
 
+// GetRevisions retrieves the earlier versions of a post the user can view
+func (h *PostHandler) GetRevisions(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get post ID from URL
+	postIdStr := chi.URLParam(r, "postID")
+	postId, err := strconv.Atoi(postIdStr)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+
+	// Get post, checking the user can view it
+	post, err := models.GetPostById(h.db, postId, user.ID)
+	if err != nil {
+		if err == models.ErrCannotViewPost {
+			utils.RespondWithError(w, http.StatusForbidden, "You are not allowed to view this post")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get post")
+		return
+	}
+	if post == nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Post not found")
+		return
+	}
+
+	// Get revisions
+	revisions, err := models.GetRevisions(h.db, models.RevisionTargetPost, postId, user.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve revisions")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"current":   post,
+		"revisions": revisions,
+	})
+}
+
+// GetQueuedPosts retrieves the current user's drafts and scheduled posts
+func (h *PostHandler) GetQueuedPosts(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	posts, err := models.GetQueuedPosts(h.db, user.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve queued posts")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, posts)
+}
+
+// SchedulePost changes when one of the current user's drafts or scheduled posts is published.
+// Setting status to "published" publishes it now and "draft" takes it off the schedule.
+func (h *PostHandler) SchedulePost(w http.ResponseWriter, r *http.Request) {
+	// Parse request body
+	var req struct {
+		Status    string     `json:"status"`
+		PublishAt *time.Time `json:"publish_at"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	h.setPostSchedule(w, r, req.Status, req.PublishAt)
+}
+
+// UnschedulePost cancels a scheduled post, keeping it as a draft
+func (h *PostHandler) UnschedulePost(w http.ResponseWriter, r *http.Request) {
+	h.setPostSchedule(w, r, models.PostStatusDraft, nil)
+}
+
+// setPostSchedule applies a schedule change and responds with the updated post
+func (h *PostHandler) setPostSchedule(w http.ResponseWriter, r *http.Request, status string, publishAt *time.Time) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get post ID from URL
+	postId, err := strconv.Atoi(chi.URLParam(r, "postID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+
+	status, err = models.SetPostSchedule(h.db, postId, user.ID, status, publishAt)
+	if err != nil {
+		switch err {
+		case models.ErrForbidden:
+			utils.RespondWithError(w, http.StatusForbidden, err.Error())
+		case models.ErrInvalidPostStatus, models.ErrInvalidPublishTime:
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		case models.ErrPostAlreadyPublished:
+			utils.RespondWithError(w, http.StatusConflict, err.Error())
+		default:
+			utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	// Get updated post
+	post, err := models.GetPostById(h.db, postId, user.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve updated post")
+		return
+	}
+
+	// Publishing now records the post's activity and notifies mentioned users,
+	// as creating a published post does
+	if status == models.PostStatusPublished && post.SharedPostID != nil {
+		_ = models.RecordPostShare(h.db, post)
+	} else if status == models.PostStatusPublished {
+		if err := models.CreatePostActivity(h.db, user.ID, postId, post.Content); err != nil {
+			// Log error but don't fail the request
+		}
+		_ = models.NotifyMentions(h.db, models.TagTargetPost, postId)
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, post)
+}
+
+// GetHashtagPosts retrieves the posts tagged with a hashtag that the current user can see
+func (h *PostHandler) GetHashtagPosts(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get hashtag from URL, with or without its leading #
+	tag := strings.TrimPrefix(chi.URLParam(r, "tag"), "#")
+	tags := models.ExtractHashtags("#" + tag)
+	if len(tags) != 1 || tags[0] != strings.ToLower(tag) {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid hashtag")
+		return
+	}
+
+	// Parse query parameters
+	page := 1
+	limit := 10
+
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 50 {
+			limit = l
+		}
+	}
+
+	posts, err := models.GetHashtagPosts(h.db, tags[0], user.ID, page, limit)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve posts")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"tag":     tags[0],
+		"posts":   posts,
+		"page":    page,
+		"limit":   limit,
+		"hasMore": len(posts) == limit,
+	})
+}
+
 // GetCommentedPosts retrieves posts that the current user has commented on
 func (h *PostHandler) GetCommentedPosts(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -263,25 +555,32 @@ func (h *PostHandler) GetCommentedPosts(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
+	after, err := cursorFromRequest(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		return
+	}
+
 	// Get commented posts
-	posts, err := models.GetCommentedPosts(h.db, user.ID, page, limit)
+	posts, next, err := models.GetCommentedPosts(h.db, user.ID, after, page, limit)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve commented posts")
 		return
 	}
 
 	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
-		"posts":   posts,
-		"page":    page,
-		"limit":   limit,
-		"hasMore": len(posts) == limit,
+		"posts":      posts,
+		"page":       page,
+		"limit":      limit,
+		"hasMore":    next != nil,
+		"nextCursor": nextCursorValue(next),
 	})
 }
 
 // SavePost saves a post for the current user
 func (h *PostHandler) SavePost(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -309,7 +608,7 @@ func (h *PostHandler) SavePost(w http.ResponseWriter, r *http.Request) {
 // UnsavePost removes a saved post for the current user
 func (h *PostHandler) UnsavePost(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -337,7 +636,7 @@ func (h *PostHandler) UnsavePost(w http.ResponseWriter, r *http.Request) {
 // CheckPostSaved checks if a post is saved by the current user
 func (h *PostHandler) CheckPostSaved(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -365,7 +664,7 @@ func (h *PostHandler) CheckPostSaved(w http.ResponseWriter, r *http.Request) {
 // GetSavedPosts retrieves posts saved by the current user
 func (h *PostHandler) GetSavedPosts(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -387,25 +686,32 @@ func (h *PostHandler) GetSavedPosts(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	after, err := cursorFromRequest(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		return
+	}
+
 	// Get saved posts
-	posts, err := models.GetSavedPosts(h.db, user.ID, page, limit)
+	posts, next, err := models.GetSavedPosts(h.db, user.ID, after, page, limit)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve saved posts")
 		return
 	}
 
 	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
-		"posts":   posts,
-		"page":    page,
-		"limit":   limit,
-		"hasMore": len(posts) == limit,
+		"posts":      posts,
+		"page":       page,
+		"limit":      limit,
+		"hasMore":    next != nil,
+		"nextCursor": nextCursorValue(next),
 	})
 }
 
 // GetLikedPosts retrieves posts liked by the current user
 func (h *PostHandler) GetLikedPosts(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -427,25 +733,32 @@ func (h *PostHandler) GetLikedPosts(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	after, err := cursorFromRequest(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		return
+	}
+
 	// Get liked posts
-	posts, err := models.GetLikedPosts(h.db, user.ID, page, limit)
+	posts, next, err := models.GetLikedPosts(h.db, user.ID, after, page, limit)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve liked posts")
 		return
 	}
 
 	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
-		"posts":   posts,
-		"page":    page,
-		"limit":   limit,
-		"hasMore": len(posts) == limit,
+		"posts":      posts,
+		"page":       page,
+		"limit":      limit,
+		"hasMore":    next != nil,
+		"nextCursor": nextCursorValue(next),
 	})
 }
 
 // AddReaction adds a reaction to a post
 func (h *PostHandler) AddReaction(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
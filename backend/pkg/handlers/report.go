@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/hezronokwach/soshi/pkg/middleware"
+	"github.com/hezronokwach/soshi/pkg/models"
+	"github.com/hezronokwach/soshi/pkg/utils"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type ReportHandler struct {
+	db *sql.DB
+}
+
+func NewReportHandler(db *sql.DB) *ReportHandler {
+	return &ReportHandler{db: db}
+}
+
+// ReportPost reports a post the user can see
+func (h *ReportHandler) ReportPost(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get post ID from URL
+	postId, err := strconv.Atoi(chi.URLParam(r, "postID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+
+	// Posts the user can't see are reported as missing rather than forbidden
+	post, err := models.GetPostById(h.db, postId, user.ID)
+	if err != nil || post == nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Post not found")
+		return
+	}
+
+	h.fileReport(w, r, user, models.ModTargetPost, post.ID, post.UserID)
+}
+
+// ReportComment reports a comment on a post the user can see
+func (h *ReportHandler) ReportComment(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get comment ID from URL
+	commentId, err := strconv.Atoi(chi.URLParam(r, "commentID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid comment ID")
+		return
+	}
+
+	comment, err := models.GetCommentById(h.db, commentId)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve comment")
+		return
+	}
+	if comment == nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Comment not found")
+		return
+	}
+
+	// The comment is visible to whoever can see its post
+	post, err := models.GetPostById(h.db, comment.PostID, user.ID)
+	if err != nil || post == nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Comment not found")
+		return
+	}
+
+	h.fileReport(w, r, user, models.ModTargetComment, comment.ID, comment.UserID)
+}
+
+// ReportGroupPost reports a post in a group the user belongs to
+func (h *ReportHandler) ReportGroupPost(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get group and post IDs from URL
+	groupId, err := strconv.Atoi(chi.URLParam(r, "groupID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid group ID")
+		return
+	}
+	postId, err := strconv.Atoi(chi.URLParam(r, "postID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+
+	var authorId int
+	err = h.db.QueryRow(
+		"SELECT user_id FROM group_posts WHERE id = ? AND group_id = ? AND hidden_at IS NULL",
+		postId, groupId,
+	).Scan(&authorId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			utils.RespondWithError(w, http.StatusNotFound, "Post not found")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve post")
+		return
+	}
+
+	// Verify user has access to this post (must be group member)
+	isMember, err := models.IsGroupMember(h.db, groupId, user.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to verify access")
+		return
+	}
+	if !isMember {
+		utils.RespondWithError(w, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	h.fileReport(w, r, user, models.ModTargetGroupPost, postId, authorId)
+}
+
+// ReportMessage reports a private message the user received or a message in one of their group chats
+func (h *ReportHandler) ReportMessage(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get message ID from URL
+	messageId, err := strconv.Atoi(chi.URLParam(r, "messageID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid message ID")
+		return
+	}
+
+	message, err := models.GetMessageByID(h.db, messageId)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve message")
+		return
+	}
+	if message == nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Message not found")
+		return
+	}
+
+	// Only people in the conversation can report a message
+	canSee := message.SenderID == user.ID || (message.ReceiverID != nil && *message.ReceiverID == user.ID)
+	if !canSee && message.GroupID != nil {
+		canSee, err = models.IsGroupMember(h.db, *message.GroupID, user.ID)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to verify access")
+			return
+		}
+	}
+	if !canSee {
+		utils.RespondWithError(w, http.StatusNotFound, "Message not found")
+		return
+	}
+
+	h.fileReport(w, r, user, models.ModTargetMessage, message.ID, message.SenderID)
+}
+
+// ReportUser reports a user's profile
+func (h *ReportHandler) ReportUser(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get target user ID from URL
+	targetUserId, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	targetUser, err := models.GetUserById(h.db, targetUserId)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve user")
+		return
+	}
+	if targetUser == nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	h.fileReport(w, r, user, models.ModTargetUser, targetUser.ID, targetUser.ID)
+}
+
+// fileReport reads the reason for a report from the request body and files it
+func (h *ReportHandler) fileReport(w http.ResponseWriter, r *http.Request, user *models.User, targetType string, targetId int, targetUserId int) {
+	// Parse request body
+	var req struct {
+		Reason  string `json:"reason"`
+		Details string `json:"details"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if len(req.Details) > 1000 {
+		utils.RespondWithError(w, http.StatusBadRequest, "Details must be at most 1000 characters")
+		return
+	}
+
+	reportId, err := models.CreateReport(h.db, models.Report{
+		ReporterID:   user.ID,
+		TargetType:   targetType,
+		TargetID:     targetId,
+		TargetUserID: targetUserId,
+		Reason:       req.Reason,
+		Details:      req.Details,
+	})
+	if err != nil {
+		switch err {
+		case models.ErrInvalidReportReason, models.ErrCannotReportSelf:
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		case models.ErrDuplicateReport:
+			utils.RespondWithError(w, http.StatusConflict, err.Error())
+		default:
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to file report")
+		}
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"id":      reportId,
+		"message": "Thanks, a moderator will review your report",
+	})
+}
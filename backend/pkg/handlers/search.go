@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/hezronokwach/soshi/pkg/middleware"
+	"github.com/hezronokwach/soshi/pkg/models"
+	"github.com/hezronokwach/soshi/pkg/utils"
+)
+
+// searchTypeResources maps each kind of search result to the API token scope resource
+// that covers it
+var searchTypeResources = map[string]string{
+	models.SearchTypePost:      "posts",
+	models.SearchTypeComment:   "posts",
+	models.SearchTypeGroupPost: "groups",
+	models.SearchTypeUser:      "users",
+	models.SearchTypeGroup:     "groups",
+}
+
+type SearchHandler struct {
+	db *sql.DB
+}
+
+func NewSearchHandler(db *sql.DB) *SearchHandler {
+	return &SearchHandler{db: db}
+}
+
+// Search searches posts, comments, group posts, people and groups.
+// Query parameters: q (required), type (comma-separated kinds of result, default all), page and limit.
+func (h *SearchHandler) Search(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	query := r.URL.Query().Get("q")
+	if strings.TrimSpace(query) == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Search query is required")
+		return
+	}
+
+	// Parse the kinds of result to search
+	types := []string{}
+	requested := r.URL.Query().Get("type") != ""
+	if requested {
+		for _, searchType := range strings.Split(r.URL.Query().Get("type"), ",") {
+			searchType = strings.TrimSpace(searchType)
+			if _, ok := searchTypeResources[searchType]; !ok {
+				utils.RespondWithError(w, http.StatusBadRequest, "Invalid search type: "+searchType)
+				return
+			}
+			types = append(types, searchType)
+		}
+	} else {
+		types = append(types, models.SearchTypes...)
+	}
+
+	// API tokens only search the areas they were granted. Asking for anything else is
+	// refused; when no type is given, the rest are searched.
+	if token, ok := middleware.APITokenFromContext(r.Context()); ok {
+		allowed := []string{}
+		for _, searchType := range types {
+			scope := searchTypeResources[searchType] + ":read"
+			if token.HasScope(scope) {
+				allowed = append(allowed, searchType)
+			} else if requested {
+				utils.RespondWithError(w, http.StatusForbidden, "Token is missing scope "+scope)
+				return
+			}
+		}
+		if len(allowed) == 0 {
+			utils.RespondWithError(w, http.StatusForbidden, "Token has no scope that allows searching")
+			return
+		}
+		types = allowed
+	}
+
+	// Parse pagination
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 || limit > 50 {
+		limit = 20
+	}
+
+	results, hasMore, err := models.Search(h.db, user.ID, query, types, page, limit)
+	if err != nil {
+		if err == models.ErrEmptySearch {
+			utils.RespondWithError(w, http.StatusBadRequest, "Search query must contain letters or numbers")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to search")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"query":   query,
+		"types":   types,
+		"results": results,
+		"page":    page,
+		"limit":   limit,
+		"hasMore": hasMore,
+	})
+}
@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/hezronokwach/soshi/pkg/middleware"
+	"github.com/hezronokwach/soshi/pkg/models"
+	"github.com/hezronokwach/soshi/pkg/totp"
+	"github.com/hezronokwach/soshi/pkg/utils"
+)
+
+// GetTwoFactorStatus reports whether the current user has two-factor authentication enabled
+func (h *AuthHandler) GetTwoFactorStatus(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	twoFactor, err := models.GetTwoFactor(h.db, user.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve two-factor status")
+		return
+	}
+
+	enabled := twoFactor != nil && twoFactor.EnabledAt != nil
+	response := map[string]interface{}{
+		"enabled": enabled,
+	}
+	if enabled {
+		remaining, err := models.CountRecoveryCodes(h.db, user.ID)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve two-factor status")
+			return
+		}
+		response["enabled_at"] = twoFactor.EnabledAt
+		response["recovery_codes_remaining"] = remaining
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+// SetupTwoFactor generates a TOTP secret for the current user to add to an authenticator app
+func (h *AuthHandler) SetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Don't silently replace a working enrollment
+	enabled, err := models.IsTwoFactorEnabled(h.db, user.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to set up two-factor authentication")
+		return
+	}
+	if enabled {
+		utils.RespondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+
+	secret, err := models.SetupTwoFactor(h.db, user.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to set up two-factor authentication")
+		return
+	}
+
+	// The client renders the provisioning URI as a QR code
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{
+		"secret":           secret,
+		"provisioning_uri": totp.ProvisioningURI(secret, models.TwoFactorIssuer, user.Email),
+	})
+}
+
+// EnableTwoFactor confirms the pending TOTP secret with a code and returns recovery codes
+func (h *AuthHandler) EnableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse request body
+	var req struct {
+		Code string `json:"code"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if req.Code == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Code is required")
+		return
+	}
+
+	codes, err := models.EnableTwoFactor(h.db, user.ID, req.Code)
+	if err != nil {
+		if err == models.ErrTwoFactorNotSetUp || err == models.ErrInvalidTwoFactorCode {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to enable two-factor authentication")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTwoFactor turns off two-factor authentication after re-checking the password and a code
+func (h *AuthHandler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse request body
+	var req struct {
+		Password     string `json:"password"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// Check password
+	authenticated, err := models.AuthenticateUser(h.db, user.Email, req.Password)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Authentication error")
+		return
+	}
+	if authenticated == nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid password")
+		return
+	}
+
+	// Check second factor
+	if !h.checkSecondFactor(w, user.ID, req.Code, req.RecoveryCode) {
+		return
+	}
+
+	if err := models.DisableTwoFactor(h.db, user.ID); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to disable two-factor authentication")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces the current user's recovery codes after checking a code
+func (h *AuthHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse request body
+	var req struct {
+		Code string `json:"code"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// Check second factor
+	if !h.checkSecondFactor(w, user.ID, req.Code, "") {
+		return
+	}
+
+	codes, err := models.RegenerateRecoveryCodes(h.db, user.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to regenerate recovery codes")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"recovery_codes": codes,
+	})
+}
+
+// LoginTwoFactor completes a login that was challenged for a second factor
+func (h *AuthHandler) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	// Parse request body
+	var req struct {
+		PendingToken string `json:"pending_token"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if req.PendingToken == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Pending token is required")
+		return
+	}
+
+	// Get pending login
+	userId, err := models.GetPendingLogin(h.db, req.PendingToken)
+	if err != nil {
+		if err == models.ErrInvalidPendingLogin {
+			utils.RespondWithError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Authentication error")
+		return
+	}
+
+	// Get user
+	user, err := models.GetUserById(h.db, userId)
+	if err != nil || user == nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve user")
+		return
+	}
+
+	// Wrong codes count towards the same throttle as wrong passwords
+	ipAddress := utils.ClientIP(r)
+	if !h.checkLoginThrottle(w, user.Email, ipAddress) {
+		return
+	}
+
+	// Check second factor
+	if !h.checkSecondFactor(w, userId, req.Code, req.RecoveryCode) {
+		_ = models.RecordPendingLoginFailure(h.db, req.PendingToken)
+		if err := models.RecordLoginFailure(h.db, user.Email, ipAddress); err != nil {
+			log.Printf("Failed to record login failure: %v", err)
+		}
+		return
+	}
+
+	if err := models.DeletePendingLogin(h.db, req.PendingToken); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Authentication error")
+		return
+	}
+
+	// Create session
+	session, err := h.startSession(w, r, user)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create session")
+		return
+	}
+	if err := models.ClearLoginFailures(h.db, user.Email); err != nil {
+		log.Printf("Failed to clear login failures: %v", err)
+	}
+
+	respondWithSession(w, user, session)
+}
+
+// checkSecondFactor verifies either a TOTP code or a recovery code for a user.
+// It writes the error response and returns false if neither is valid.
+func (h *AuthHandler) checkSecondFactor(w http.ResponseWriter, userId int, code string, recoveryCode string) bool {
+	var err error
+	switch {
+	case code != "":
+		err = models.VerifyTwoFactorCode(h.db, userId, code)
+	case recoveryCode != "":
+		err = models.UseRecoveryCode(h.db, userId, recoveryCode)
+	default:
+		utils.RespondWithError(w, http.StatusBadRequest, "Code or recovery code is required")
+		return false
+	}
+
+	if err != nil {
+		if err == models.ErrInvalidTwoFactorCode || err == models.ErrTwoFactorNotSetUp {
+			utils.RespondWithError(w, http.StatusUnauthorized, err.Error())
+			return false
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to verify code")
+		return false
+	}
+
+	return true
+}
@@ -1,29 +1,38 @@
 package handlers
 
 import (
+	"database/sql"
 	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/hezronokwach/soshi/pkg/middleware"
 	"github.com/hezronokwach/soshi/pkg/models"
 	"github.com/hezronokwach/soshi/pkg/utils"
 
 	"github.com/google/uuid"
 )
 
-type UploadHandler struct{}
+type UploadHandler struct {
+	db *sql.DB
+}
 
-func NewUploadHandler() *UploadHandler {
-	return &UploadHandler{}
+func NewUploadHandler(db *sql.DB) *UploadHandler {
+	return &UploadHandler{db: db}
 }
 
-// UploadFile handles file uploads
+// UploadFile handles file uploads. The upload is recorded so it can be attached to posts,
+// comments and messages by the ID returned with its URL.
 func (h *UploadHandler) UploadFile(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -77,9 +86,35 @@ func (h *UploadHandler) UploadFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Return the full URL path for the uploaded file
+	// Read the image's dimensions. They are left unknown if the image can't be decoded.
+	var width, height int
+	if _, err := file.Seek(0, io.SeekStart); err == nil {
+		if config, _, err := image.DecodeConfig(file); err == nil {
+			width, height = config.Width, config.Height
+		}
+	}
+
+	// Record the upload
 	fileURL := fmt.Sprintf("/uploads/%s", filename)
-	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"url": fileURL})
+	media, err := models.RecordUpload(h.db, user.ID, fileURL, contentType, width, height)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to record upload")
+		return
+	}
+
+	// Return the full URL path for the uploaded file
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"id":        media.ID,
+		"url":       media.URL,
+		"mime_type": media.MimeType,
+		"width":     media.Width,
+		"height":    media.Height,
+	})
+}
+
+// isAttachmentError reports whether an error is about the attachments a request asked for
+func isAttachmentError(err error) bool {
+	return err == models.ErrTooManyAttachments || err == models.ErrInvalidAttachment || err == models.ErrAltTextTooLong
 }
 
 // isAllowedFileType checks if the file type is allowed
@@ -5,10 +5,13 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/hezronokwach/soshi/pkg/middleware"
 	"github.com/hezronokwach/soshi/pkg/models"
 	"github.com/hezronokwach/soshi/pkg/utils"
+	"github.com/hezronokwach/soshi/pkg/validation"
 	"github.com/hezronokwach/soshi/pkg/websocket"
 )
 
@@ -24,7 +27,7 @@ func NewUserHandler(db *sql.DB, hub *websocket.Hub) *UserHandler {
 // GetFollowers retrieves users who are following the specified user
 func (h *UserHandler) GetFollowers(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -43,7 +46,7 @@ func (h *UserHandler) GetFollowers(w http.ResponseWriter, r *http.Request) {
 // GetProfile retrieves user profile information
 func (h *UserHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -89,7 +92,7 @@ func (h *UserHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
 // UpdateProfile updates user profile information
 func (h *UserHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -101,6 +104,14 @@ func (h *UserHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Validate fields
+	v := validation.New()
+	validateProfile(v, &updateData)
+	if !v.Valid() {
+		utils.RespondWithValidationErrors(w, v.Errors)
+		return
+	}
+
 	// Update user profile
 	updateData.ID = user.ID
 	if err := models.UpdateUser(h.db, &updateData); err != nil {
@@ -118,10 +129,26 @@ func (h *UserHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 	utils.RespondWithJSON(w, http.StatusOK, updatedProfile)
 }
 
+// validateProfile checks the profile fields a user fills in themselves, trimming
+// surrounding whitespace from names first
+func validateProfile(v *validation.Validator, user *models.User) {
+	user.FirstName = strings.TrimSpace(user.FirstName)
+	user.LastName = strings.TrimSpace(user.LastName)
+	user.Nickname = strings.TrimSpace(user.Nickname)
+
+	v.Required("first_name", user.FirstName)
+	v.MaxLength("first_name", user.FirstName, validation.MaxNameLength)
+	v.Required("last_name", user.LastName)
+	v.MaxLength("last_name", user.LastName, validation.MaxNameLength)
+	v.DateOfBirth("date_of_birth", user.DateOfBirth, validation.MinimumAgeFromEnv())
+	v.MaxLength("nickname", user.Nickname, validation.MaxNicknameLength)
+	v.MaxLength("about_me", user.AboutMe, validation.MaxAboutMeLength)
+}
+
 // UpdateProfilePrivacy updates user profile privacy setting
 func (h *UserHandler) UpdateProfilePrivacy(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -156,7 +183,7 @@ func (h *UserHandler) UpdateProfilePrivacy(w http.ResponseWriter, r *http.Reques
 // GetFollowing retrieves users that the current user is following
 func (h *UserHandler) GetFollowing(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -190,7 +217,7 @@ func (h *UserHandler) GetFollowing(w http.ResponseWriter, r *http.Request) {
 // GetSuggestedUsers retrieves users that the current user might want to follow
 func (h *UserHandler) GetSuggestedUsers(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -220,7 +247,7 @@ func (h *UserHandler) GetSuggestedUsers(w http.ResponseWriter, r *http.Request)
 // GetOnlineUsers retrieves users that are currently online (connected via WebSocket)
 func (h *UserHandler) GetOnlineUsers(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -283,7 +310,7 @@ func (h *UserHandler) GetOnlineUsers(w http.ResponseWriter, r *http.Request) {
 // GetFollowCounts retrieves follower and following counts for a user
 func (h *UserHandler) GetFollowCounts(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -317,7 +344,7 @@ func (h *UserHandler) GetFollowCounts(w http.ResponseWriter, r *http.Request) {
 // FollowUser handles following a user
 func (h *UserHandler) FollowUser(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -359,7 +386,7 @@ func (h *UserHandler) FollowUser(w http.ResponseWriter, r *http.Request) {
 // UnfollowUser handles unfollowing a user
 func (h *UserHandler) UnfollowUser(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -390,7 +417,7 @@ func (h *UserHandler) UnfollowUser(w http.ResponseWriter, r *http.Request) {
 // GetFollowStatus gets the follow status between current user and target user
 func (h *UserHandler) GetFollowStatus(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -420,7 +447,7 @@ func (h *UserHandler) GetFollowStatus(w http.ResponseWriter, r *http.Request) {
 // CancelFollowRequest cancels a pending follow request
 func (h *UserHandler) CancelFollowRequest(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -450,7 +477,7 @@ func (h *UserHandler) CancelFollowRequest(w http.ResponseWriter, r *http.Request
 
 // GetAllUsers returns all users (public and private) for the sidebar
 func (h *UserHandler) GetAllUsers(w http.ResponseWriter, r *http.Request) {
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -467,7 +494,7 @@ func (h *UserHandler) GetAllUsers(w http.ResponseWriter, r *http.Request) {
 
 // AcceptMessageRequestHandler allows a user to accept a message request
 func (h *UserHandler) AcceptMessageRequestHandler(w http.ResponseWriter, r *http.Request) {
-	user, ok := r.Context().Value("user").(*models.User)
+	user, ok := middleware.UserFromContext(r.Context())
 	if !ok {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -0,0 +1,108 @@
+// Package jobs contains background workers started alongside the HTTP server
+package jobs
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hezronokwach/soshi/pkg/models"
+)
+
+// UploadsDir is where user uploads are stored. Uploaded file names start with the
+// uploader's user ID, which is how a deleted user's files are found.
+const UploadsDir = "./uploads"
+
+// RunAccountDeletion deletes accounts whose grace period has passed and removes their
+// uploaded files, checking at the given interval. It never returns.
+func RunAccountDeletion(db *sql.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := models.DeleteDueAccounts(db)
+		if err != nil {
+			log.Printf("Failed to delete scheduled accounts: %v", err)
+		} else if deleted > 0 {
+			log.Printf("Deleted %d scheduled account(s)", deleted)
+		}
+
+		cleanUpDeletedUserFiles(db)
+
+		<-ticker.C
+	}
+}
+
+// cleanUpDeletedUserFiles removes the uploads and data exports of deleted users
+func cleanUpDeletedUserFiles(db *sql.DB) {
+	userIds, err := models.GetPendingFileCleanups(db)
+	if err != nil {
+		log.Printf("Failed to get pending file cleanups: %v", err)
+		return
+	}
+
+	for _, userId := range userIds {
+		removed := 0
+		failed := false
+		for _, dir := range []string{UploadsDir, models.DataExportDir()} {
+			n, err := removeUserFiles(dir, userId)
+			removed += n
+			if err != nil {
+				log.Printf("Failed to remove files for deleted user %d in %s: %v", userId, dir, err)
+				failed = true
+			}
+		}
+
+		// Leave the entry in place to retry on the next run
+		if failed {
+			continue
+		}
+
+		if err := models.CompleteFileCleanup(db, userId); err != nil {
+			log.Printf("Failed to complete file cleanup for deleted user %d: %v", userId, err)
+			continue
+		}
+		log.Printf("Removed %d file(s) for deleted user %d", removed, userId)
+	}
+}
+
+// userFiles lists the files in dir that belong to a user
+func userFiles(dir string, userId int) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	prefix := fmt.Sprintf("%d_", userId)
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasPrefix(entry.Name(), prefix) {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return files, nil
+}
+
+// removeUserFiles deletes the files in dir that belong to a user and returns how many were removed
+func removeUserFiles(dir string, userId int) (int, error) {
+	files, err := userFiles(dir, userId)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, file := range files {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
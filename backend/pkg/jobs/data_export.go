@@ -0,0 +1,180 @@
+package jobs
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/hezronokwach/soshi/pkg/models"
+)
+
+// RunDataExports builds queued data exports and removes expired ones,
+// checking at the given interval. It never returns.
+func RunDataExports(db *sql.DB, interval time.Duration) {
+	// Anything left half-built by a previous run starts over
+	if err := models.ResetInterruptedDataExports(db); err != nil {
+		log.Printf("Failed to requeue interrupted data exports: %v", err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			export, err := models.ClaimNextDataExport(db)
+			if err != nil {
+				log.Printf("Failed to claim data export: %v", err)
+				break
+			}
+			if export == nil {
+				break
+			}
+			processDataExport(db, export)
+		}
+
+		removeExpiredDataExports(db)
+
+		<-ticker.C
+	}
+}
+
+// processDataExport builds one export and records the outcome
+func processDataExport(db *sql.DB, export *models.DataExport) {
+	fileName := fmt.Sprintf("%d_%d_%s.zip", export.UserID, export.ID, time.Now().Format("20060102150405"))
+
+	if err := buildDataExport(db, export.UserID, fileName); err != nil {
+		log.Printf("Failed to build data export %d: %v", export.ID, err)
+		if err := models.FailDataExport(db, export.ID, "Failed to build export"); err != nil {
+			log.Printf("Failed to mark data export %d as failed: %v", export.ID, err)
+		}
+		return
+	}
+
+	if err := models.CompleteDataExport(db, export.ID, fileName); err != nil {
+		log.Printf("Failed to mark data export %d as ready: %v", export.ID, err)
+	}
+}
+
+// buildDataExport writes a ZIP archive of a user's data and uploaded media to the export directory
+func buildDataExport(db *sql.DB, userId int, fileName string) error {
+	data, err := models.CollectUserData(db, userId)
+	if err != nil {
+		return err
+	}
+
+	media, err := userFiles(UploadsDir, userId)
+	if err != nil {
+		return err
+	}
+
+	dir := models.DataExportDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	// Write to a temporary name so a half-written archive is never served
+	path := filepath.Join(dir, fileName)
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+
+	archive := zip.NewWriter(file)
+	if err := writeDataExport(archive, data, media); err != nil {
+		archive.Close()
+		file.Close()
+		return err
+	}
+	if err := archive.Close(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// writeDataExport adds one JSON file per data section and a media folder to the archive
+func writeDataExport(archive *zip.Writer, data map[string][]map[string]interface{}, media []string) error {
+	names := make([]string, 0, len(data))
+	for name := range data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		rows := data[name]
+		var content interface{} = rows
+		// The profile is a single record
+		if name == "profile" && len(rows) == 1 {
+			content = rows[0]
+		}
+
+		w, err := archive.Create(name + ".json")
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(content); err != nil {
+			return err
+		}
+	}
+
+	for _, path := range media {
+		if err := addFileToArchive(archive, path, "media/"+filepath.Base(path)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addFileToArchive copies a file on disk into the archive
+func addFileToArchive(archive *zip.Writer, path string, name string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, src)
+	return err
+}
+
+// removeExpiredDataExports deletes exports whose download window has passed, along with their files
+func removeExpiredDataExports(db *sql.DB) {
+	exports, err := models.GetExpiredDataExports(db)
+	if err != nil {
+		log.Printf("Failed to get expired data exports: %v", err)
+		return
+	}
+
+	for _, export := range exports {
+		if export.FileName != "" {
+			path := filepath.Join(models.DataExportDir(), export.FileName)
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				log.Printf("Failed to remove data export file %s: %v", path, err)
+				continue
+			}
+		}
+		if err := models.DeleteDataExport(db, export.ID); err != nil {
+			log.Printf("Failed to delete data export %d: %v", export.ID, err)
+		}
+	}
+}
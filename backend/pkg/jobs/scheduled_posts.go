@@ -0,0 +1,42 @@
+package jobs
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/hezronokwach/soshi/pkg/models"
+)
+
+// RunScheduledPosts publishes scheduled posts once their publish time has passed,
+// checking at the given interval. It never returns.
+func RunScheduledPosts(db *sql.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		published, err := models.PublishDuePosts(db)
+		if err != nil {
+			log.Printf("Failed to publish scheduled posts: %v", err)
+		}
+
+		// The post is created, as far as followers can tell, when it is published
+		for _, post := range published {
+			if post.SharedPostID != nil {
+				if err := models.RecordPostShare(db, &post); err != nil {
+					log.Printf("Failed to record share for scheduled post %d: %v", post.ID, err)
+				}
+			} else if err := models.CreatePostActivity(db, post.UserID, post.ID, post.Content); err != nil {
+				log.Printf("Failed to record activity for scheduled post %d: %v", post.ID, err)
+			}
+			if err := models.NotifyMentions(db, models.TagTargetPost, post.ID); err != nil {
+				log.Printf("Failed to notify users mentioned in scheduled post %d: %v", post.ID, err)
+			}
+		}
+		if len(published) > 0 {
+			log.Printf("Published %d scheduled post(s)", len(published))
+		}
+
+		<-ticker.C
+	}
+}
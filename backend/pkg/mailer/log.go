@@ -0,0 +1,57 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LogMailer writes messages to files in a directory, or to the log when no directory is set.
+// It is intended for local development and testing.
+type LogMailer struct {
+	dir  string
+	from string
+}
+
+// NewLogMailer creates a mailer that writes messages to dir
+func NewLogMailer(dir string, from string) *LogMailer {
+	return &LogMailer{dir: dir, from: from}
+}
+
+// Send writes the message
+func (m *LogMailer) Send(msg Message) error {
+	content := formatMessage(m.from, msg)
+
+	if m.dir == "" {
+		log.Printf("Mail to %s:\n%s", msg.To, content)
+		return nil
+	}
+
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create mail directory: %w", err)
+	}
+
+	// One file per message, named so that they sort by time
+	recipient := strings.NewReplacer("@", "_at_", "/", "_").Replace(msg.To)
+	filename := fmt.Sprintf("%d_%s.eml", time.Now().UnixNano(), recipient)
+	if err := os.WriteFile(filepath.Join(m.dir, filename), []byte(content), 0o644); err != nil {
+		return fmt.Errorf("failed to write mail file: %w", err)
+	}
+
+	return nil
+}
+
+// formatMessage renders a message as a minimal RFC 5322 email
+func formatMessage(from string, msg Message) string {
+	return "From: " + from + "\r\n" +
+		"To: " + msg.To + "\r\n" +
+		"Subject: " + msg.Subject + "\r\n" +
+		"Date: " + time.Now().Format(time.RFC1123Z) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" +
+		msg.Body + "\r\n"
+}
@@ -0,0 +1,47 @@
+package mailer
+
+import (
+	"log"
+	"os"
+	"strconv"
+)
+
+// Message is an email to be delivered
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers email messages
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// NewFromEnv returns the mailer selected by the MAIL_DRIVER environment variable.
+// "smtp" sends through an SMTP server; anything else writes messages to MAIL_LOG_DIR
+// (or the server log when unset) so the whole flow can be exercised offline.
+func NewFromEnv() Mailer {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "no-reply@soshi.local"
+	}
+
+	switch os.Getenv("MAIL_DRIVER") {
+	case "smtp":
+		port, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
+		if err != nil {
+			port = 587
+		}
+		return NewSMTPMailer(SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		})
+	default:
+		log.Println("Using log mailer; emails will not be delivered")
+		return NewLogMailer(os.Getenv("MAIL_LOG_DIR"), from)
+	}
+}
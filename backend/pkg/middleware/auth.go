@@ -4,16 +4,25 @@ import (
 	"context"
 	"database/sql"
 	"net/http"
+	"strings"
 
 	"github.com/hezronokwach/soshi/pkg/models"
+	"github.com/hezronokwach/soshi/pkg/utils"
 )
 
-// Auth middleware to check if user is authenticated
+// Auth middleware to check if user is authenticated, either by session cookie
+// or by a personal access token in the Authorization header
 func Auth(db *sql.DB) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Scripts and bots authenticate with a personal access token instead of a cookie
+			if bearer, ok := bearerToken(r); ok {
+				authenticateToken(db, w, r, next, bearer)
+				return
+			}
+
 			// Get session token from cookie
-			cookie, err := r.Cookie("session_token")
+			cookie, err := r.Cookie(utils.SessionCookieName)
 			if err != nil {
 				if err == http.ErrNoCookie {
 					http.Error(w, "Unauthorized", http.StatusUnauthorized)
@@ -35,20 +44,76 @@ func Auth(db *sql.DB) func(http.Handler) http.Handler {
 				return
 			}
 
+			// Extend the session and rotate its token when due
+			rotated, err := models.RefreshSession(db, session)
+			if err != nil {
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+
+			// Re-issue the cookie if the token changed, or if the client is still
+			// presenting the previous token during the rotation grace period
+			if rotated || cookie.Value != session.Token {
+				utils.SetSessionCookie(w, session.Token, session.ExpiresAt)
+			}
+
 			// Get user
 			user, err := models.GetUserById(db, session.UserID)
 			if err != nil {
 				http.Error(w, "Internal server error", http.StatusInternalServerError)
 				return
 			}
-			if user == nil {
+			if user == nil || user.IsSuspended() {
 				http.Error(w, "Unauthorized", http.StatusUnauthorized)
 				return
 			}
 
-			// Add user to context
-			ctx := context.WithValue(r.Context(), "user", user)
+			// Add user and session to context
+			ctx := context.WithValue(r.Context(), userContextKey, user)
+			ctx = context.WithValue(ctx, sessionContextKey, session)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
+
+// bearerToken extracts the token from an "Authorization: Bearer" header
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return "", false
+	}
+	token := strings.TrimSpace(header[7:])
+	return token, token != ""
+}
+
+// authenticateToken authenticates a request carrying a personal access token
+func authenticateToken(db *sql.DB, w http.ResponseWriter, r *http.Request, next http.Handler, bearer string) {
+	// Get token
+	token, err := models.GetAPITokenByToken(db, bearer)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if token == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Get user
+	user, err := models.GetUserById(db, token.UserID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	// Accounts awaiting deletion can only be reactivated by logging in,
+	// and suspended accounts can't be used at all
+	if user == nil || user.DeletionScheduledAt != nil || user.IsSuspended() {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Add user and token to context
+	ctx := context.WithValue(r.Context(), userContextKey, user)
+	ctx = context.WithValue(ctx, apiTokenContextKey, token)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
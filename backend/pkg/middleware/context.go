@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/hezronokwach/soshi/pkg/models"
+)
+
+// contextKey is unexported so no other package can collide with the values Auth stores
+type contextKey int
+
+const (
+	userContextKey contextKey = iota
+	sessionContextKey
+	apiTokenContextKey
+)
+
+// UserFromContext returns the authenticated user stored by Auth
+func UserFromContext(ctx context.Context) (*models.User, bool) {
+	user, ok := ctx.Value(userContextKey).(*models.User)
+	return user, ok && user != nil
+}
+
+// SessionFromContext returns the session of a cookie-authenticated request
+func SessionFromContext(ctx context.Context) (*models.Session, bool) {
+	session, ok := ctx.Value(sessionContextKey).(*models.Session)
+	return session, ok && session != nil
+}
+
+// APITokenFromContext returns the personal access token of a token-authenticated request
+func APITokenFromContext(ctx context.Context) (*models.APIToken, bool) {
+	token, ok := ctx.Value(apiTokenContextKey).(*models.APIToken)
+	return token, ok && token != nil
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"net/http"
+	"strings"
+
+	"github.com/hezronokwach/soshi/pkg/models"
+	"github.com/hezronokwach/soshi/pkg/utils"
+)
+
+// CSRFHeaderName is the request header that must carry the session's CSRF token
+const CSRFHeaderName = "X-CSRF-Token"
+
+// CSRF middleware protects cookie-authenticated requests from cross-site request forgery.
+// Every POST, PUT, PATCH and DELETE that carries a session cookie must echo the session's
+// CSRF token (exposed by /api/auth/session) in the X-CSRF-Token header.
+// Safe methods, including the WebSocket upgrade, and bearer-token requests are exempt,
+// since a browser never attaches an Authorization header on its own.
+func CSRF(db *sql.DB) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if _, ok := bearerToken(r); ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Without a session cookie there is nothing to forge
+			cookie, err := r.Cookie(utils.SessionCookieName)
+			if err != nil || cookie.Value == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Get session
+			session, err := models.GetSessionByToken(db, cookie.Value)
+			if err != nil {
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			if session == nil {
+				// Stale cookie; authentication will reject the request where it matters
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			token := strings.TrimSpace(r.Header.Get(CSRFHeaderName))
+			if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(session.CSRFToken)) != 1 {
+				http.Error(w, "Invalid CSRF token", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
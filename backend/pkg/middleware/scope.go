@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+)
+
+// RequireScope middleware limits personal access tokens to the resources they were granted.
+// GET and HEAD requests need the resource's ":read" scope and all other methods its ":write" scope.
+// Requests authenticated with a session cookie are not restricted. Must run after Auth.
+func RequireScope(resource string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := APITokenFromContext(r.Context())
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			scope := resource + ":write"
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				scope = resource + ":read"
+			}
+			if !token.HasScope(scope) {
+				http.Error(w, "Token is missing scope "+scope, http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireSession middleware rejects requests authenticated with a personal access token,
+// for routes that manage the account's credentials. Must run after Auth.
+func RequireSession() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := APITokenFromContext(r.Context()); ok {
+				http.Error(w, "This endpoint cannot be used with an API token", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
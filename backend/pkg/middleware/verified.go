@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/hezronokwach/soshi/pkg/models"
+)
+
+// RequireVerifiedEmail middleware blocks users who haven't confirmed their email address.
+// It does nothing unless REQUIRE_EMAIL_VERIFICATION is enabled, and must run after Auth.
+func RequireVerifiedEmail() func(http.Handler) http.Handler {
+	required := models.EmailVerificationRequired()
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !required {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			user, ok := UserFromContext(r.Context())
+			if !ok {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if user.EmailVerifiedAt == nil {
+				http.Error(w, "Email address not verified", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// ScheduleAccountDeletion marks a user's account for deletion after the grace period
+// and signs it out everywhere. Logging in again before then cancels the deletion.
+// It returns when the account will be deleted.
+func ScheduleAccountDeletion(db *sql.DB, userId int) (time.Time, error) {
+	deleteAt := time.Now().Add(durationFromEnv("ACCOUNT_DELETION_GRACE_PERIOD", 14*24*time.Hour))
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"UPDATE users SET deletion_scheduled_at = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		deleteAt, userId,
+	)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// Sign out everywhere
+	if _, err := tx.Exec("DELETE FROM sessions WHERE user_id = ?", userId); err != nil {
+		return time.Time{}, err
+	}
+	if _, err := tx.Exec("DELETE FROM pending_logins WHERE user_id = ?", userId); err != nil {
+		return time.Time{}, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return time.Time{}, err
+	}
+
+	return deleteAt, nil
+}
+
+// CancelAccountDeletion clears a scheduled deletion. It returns true if one was pending.
+func CancelAccountDeletion(db *sql.DB, userId int) (bool, error) {
+	result, err := db.Exec(
+		"UPDATE users SET deletion_scheduled_at = NULL WHERE id = ? AND deletion_scheduled_at IS NOT NULL",
+		userId,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// DeleteDueAccounts deletes every account whose grace period has passed and queues their
+// uploaded files for removal. Everything else the user owns goes with the user row through
+// ON DELETE CASCADE. It returns the number of accounts deleted.
+func DeleteDueAccounts(db *sql.DB) (int, error) {
+	rows, err := db.Query(
+		"SELECT id FROM users WHERE deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= ?",
+		time.Now(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	var userIds []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		userIds = append(userIds, id)
+	}
+	rows.Close()
+
+	deleted := 0
+	for _, userId := range userIds {
+		if err := deleteAccount(db, userId); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// deleteAccount deletes a user and records that their files need cleaning up, atomically
+func deleteAccount(db *sql.DB, userId int) error {
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("INSERT OR IGNORE INTO pending_file_cleanups (user_id) VALUES (?)", userId)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM users WHERE id = ?", userId)
+	if err != nil {
+		return err
+	}
+
+	// Commit transaction
+	return tx.Commit()
+}
+
+// GetPendingFileCleanups returns the IDs of deleted users whose files still need removing
+func GetPendingFileCleanups(db *sql.DB) ([]int, error) {
+	rows, err := db.Query("SELECT user_id FROM pending_file_cleanups ORDER BY created_at")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIds []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		userIds = append(userIds, id)
+	}
+
+	return userIds, nil
+}
+
+// CompleteFileCleanup marks a deleted user's files as removed
+func CompleteFileCleanup(db *sql.DB, userId int) error {
+	_, err := db.Exec("DELETE FROM pending_file_cleanups WHERE user_id = ?", userId)
+	return err
+}
@@ -132,24 +132,25 @@ func GetUserActivities(db *sql.DB, userID int, filters map[string]interface{}, p
 	return activities, nil
 }
 
-// GetUserPosts retrieves all posts by a user for activity display
-func GetUserPosts(db *sql.DB, userID int, page, limit int) ([]Post, error) {
+// GetUserPosts retrieves all posts by a user for activity display.
+// Drafts, scheduled posts and posts hidden by a moderator are only included when the viewer is their author.
+func GetUserPosts(db *sql.DB, userID int, viewerID int, page, limit int) ([]Post, error) {
 	offset := (page - 1) * limit
 	posts := []Post{}
 
 	query := `
 		SELECT p.id, p.user_id, p.content, p.image_url, p.privacy, 
 		       COALESCE(p.like_count, 0) as like_count, COALESCE(p.dislike_count, 0) as dislike_count, 
-		       p.created_at, p.updated_at,
+		       p.created_at, p.updated_at, p.hidden_at IS NOT NULL, COALESCE(p.revision_count, 0), p.status, p.publish_at,
 		       u.id, u.first_name, u.last_name, u.nickname, u.avatar
 		FROM posts p
 		JOIN users u ON p.user_id = u.id
-		WHERE p.user_id = ?
+		WHERE p.user_id = ? AND (p.hidden_at IS NULL OR p.user_id = ?) AND (p.status = 'published' OR p.user_id = ?)
 		ORDER BY p.created_at DESC
 		LIMIT ? OFFSET ?
 	`
 
-	rows, err := db.Query(query, userID, limit, offset)
+	rows, err := db.Query(query, userID, viewerID, viewerID, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -158,20 +159,41 @@ func GetUserPosts(db *sql.DB, userID int, page, limit int) ([]Post, error) {
 	for rows.Next() {
 		var post Post
 		var user User
+		var publishAt sql.NullTime
 
 		err := rows.Scan(
 			&post.ID, &post.UserID, &post.Content, &post.ImageURL, &post.Privacy,
-			&post.LikeCount, &post.DislikeCount, &post.CreatedAt, &post.UpdatedAt,
+			&post.LikeCount, &post.DislikeCount, &post.CreatedAt, &post.UpdatedAt, &post.Hidden, &post.RevisionCount,
+			&post.Status, &publishAt,
 			&user.ID, &user.FirstName, &user.LastName, &user.Nickname, &user.Avatar,
 		)
 		if err != nil {
 			return nil, err
 		}
+		post.Edited = post.RevisionCount > 0
+		if publishAt.Valid {
+			post.PublishAt = &publishAt.Time
+		}
 
 		post.User = &user
 		posts = append(posts, post)
 	}
 
+	// Get attachments
+	if err := loadPostMedia(db, MediaTargetPost, posts); err != nil {
+		return nil, err
+	}
+
+	// Get the posts they share
+	if err := loadPostShares(db, posts, viewerID); err != nil {
+		return nil, err
+	}
+
+	// Get polls
+	if err := loadPostPolls(db, PollTargetPost, posts, viewerID); err != nil {
+		return nil, err
+	}
+
 	return posts, nil
 }
 
@@ -307,6 +329,28 @@ func CreatePostActivity(db *sql.DB, userID int, postID int, content string) erro
 	})
 }
 
+// CreatePostShareActivity records when a user reposts or quotes a post
+func CreatePostShareActivity(db *sql.DB, userID int, postID int, sharedPostID int, content string, sharedPostUserID int) error {
+	metadata := map[string]interface{}{
+		"content_preview": truncateString(content, 100),
+		"shared_post_id":  sharedPostID,
+	}
+
+	var targetUID *int
+	if sharedPostUserID != userID {
+		targetUID = &sharedPostUserID
+	}
+
+	return CreateActivity(db, Activity{
+		UserID:       userID,
+		ActivityType: "post_shared",
+		TargetType:   "post",
+		TargetID:     postID,
+		TargetUserID: targetUID,
+		Metadata:     metadata,
+	})
+}
+
 // CreateCommentActivity records when a user creates a comment
 func CreateCommentActivity(db *sql.DB, userID int, commentID int, postID int, content string, targetUserID int) error {
 	metadata := map[string]interface{}{
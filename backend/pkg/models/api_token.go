@@ -0,0 +1,209 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// APITokenPrefix marks personal access tokens so they are easy to recognise, e.g. in leaked secrets
+const APITokenPrefix = "soshi_pat_"
+
+// ScopeResources are the API areas a personal access token can be granted access to.
+// Each resource has a ":read" scope for GET requests and a ":write" scope for everything else.
+var ScopeResources = []string{
+	"posts",
+	"groups",
+	"users",
+	"messages",
+	"notifications",
+	"activity",
+	"uploads",
+}
+
+// ErrAPITokenNotFound is returned when a token does not exist or belongs to another user
+var ErrAPITokenNotFound = errors.New("api token not found")
+
+// APIToken is a personal access token used by scripts and bots in place of a session cookie
+type APIToken struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// HasScope checks if the token was granted a scope
+func (t *APIToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidScope checks if a scope is one that can be granted
+func IsValidScope(scope string) bool {
+	resource, access, found := strings.Cut(scope, ":")
+	if !found || (access != "read" && access != "write") {
+		return false
+	}
+	for _, r := range ScopeResources {
+		if r == resource {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateAPIToken creates a personal access token for a user.
+// It returns the stored token along with the plain-text value, which is never shown again.
+func CreateAPIToken(db *sql.DB, userId int, name string, scopes []string, expiresAt *time.Time) (*APIToken, string, error) {
+	secret, err := generateToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	plain := APITokenPrefix + secret
+
+	// Store scopes in a stable order without duplicates
+	unique := map[string]bool{}
+	for _, scope := range scopes {
+		unique[scope] = true
+	}
+	scopes = make([]string, 0, len(unique))
+	for scope := range unique {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+
+	token := &APIToken{
+		UserID:    userId,
+		Name:      name,
+		Prefix:    plain[:len(APITokenPrefix)+6],
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+
+	result, err := db.Exec(
+		`INSERT INTO api_tokens (user_id, name, token_hash, token_prefix, scopes, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		userId, name, hashToken(plain), token.Prefix, strings.Join(scopes, " "), expiresAt, token.CreatedAt,
+	)
+	if err != nil {
+		return nil, "", err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, "", err
+	}
+	token.ID = int(id)
+
+	return token, plain, nil
+}
+
+// scanAPIToken scans a token row selected with apiTokenColumns
+func scanAPIToken(row interface{ Scan(...interface{}) error }, token *APIToken) error {
+	var scopes string
+	var lastUsedAt, expiresAt sql.NullTime
+	err := row.Scan(
+		&token.ID, &token.UserID, &token.Name, &token.Prefix, &scopes,
+		&lastUsedAt, &expiresAt, &token.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	token.Scopes = strings.Fields(scopes)
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	if expiresAt.Valid {
+		token.ExpiresAt = &expiresAt.Time
+	}
+	return nil
+}
+
+const apiTokenColumns = `id, user_id, name, token_prefix, scopes, last_used_at, expires_at, created_at`
+
+// GetAPITokenByToken retrieves an unexpired token by its plain-text value and records that it was used
+func GetAPITokenByToken(db *sql.DB, plain string) (*APIToken, error) {
+	token := &APIToken{}
+	err := scanAPIToken(db.QueryRow(
+		`SELECT `+apiTokenColumns+` FROM api_tokens WHERE token_hash = ?`,
+		hashToken(plain),
+	), token)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	// Check if token is expired
+	now := time.Now()
+	if token.ExpiresAt != nil && token.ExpiresAt.Before(now) {
+		return nil, nil
+	}
+
+	// Record use, throttled like session last-seen updates
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= lastSeenResolution {
+		if _, err := db.Exec("UPDATE api_tokens SET last_used_at = ? WHERE id = ?", now, token.ID); err != nil {
+			return nil, err
+		}
+		token.LastUsedAt = &now
+	}
+
+	return token, nil
+}
+
+// GetUserAPITokens retrieves all of a user's tokens
+func GetUserAPITokens(db *sql.DB, userId int) ([]APIToken, error) {
+	tokens := []APIToken{}
+
+	rows, err := db.Query(`
+		SELECT `+apiTokenColumns+`
+		FROM api_tokens
+		WHERE user_id = ?
+		ORDER BY created_at DESC
+	`, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var token APIToken
+		if err := scanAPIToken(rows, &token); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, nil
+}
+
+// DeleteUserAPIToken revokes one of a user's tokens by ID
+func DeleteUserAPIToken(db *sql.DB, tokenId int, userId int) error {
+	result, err := db.Exec("DELETE FROM api_tokens WHERE id = ? AND user_id = ?", tokenId, userId)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrAPITokenNotFound
+	}
+
+	return nil
+}
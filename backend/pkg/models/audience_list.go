@@ -0,0 +1,288 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaxAudienceListNameLength is the longest name an audience list can have, in characters
+const MaxAudienceListNameLength = 50
+
+var (
+	ErrAudienceListNotFound      = errors.New("audience list not found")
+	ErrInvalidAudienceListName   = fmt.Errorf("audience list names must be 1 to %d characters", MaxAudienceListNameLength)
+	ErrAudienceListNameTaken     = errors.New("you already have an audience list with this name")
+	ErrInvalidAudienceListMember = errors.New("user can't be added to the audience list")
+)
+
+// AudienceList is a named list of users, like "Close friends", that its owner can share private
+// posts with. Posts see the list's current members, so changes apply to past posts too.
+type AudienceList struct {
+	ID          int       `json:"id"`
+	UserID      int       `json:"user_id"`
+	Name        string    `json:"name"`
+	MemberCount int       `json:"member_count"`
+	Members     []User    `json:"members,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// audienceListName validates and trims the name of an audience list
+func audienceListName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > MaxAudienceListNameLength {
+		return "", ErrInvalidAudienceListName
+	}
+	return name, nil
+}
+
+// CreateAudienceList creates an empty audience list owned by a user
+func CreateAudienceList(db *sql.DB, userId int, name string) (*AudienceList, error) {
+	name, err := audienceListName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the user doesn't already have a list with this name
+	var exists bool
+	err = db.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM audience_lists WHERE user_id = ? AND name = ?)",
+		userId, name,
+	).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrAudienceListNameTaken
+	}
+
+	result, err := db.Exec("INSERT INTO audience_lists (user_id, name) VALUES (?, ?)", userId, name)
+	if err != nil {
+		return nil, err
+	}
+
+	listId, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return GetAudienceList(db, int(listId), userId)
+}
+
+// GetAudienceLists retrieves a user's audience lists with their member counts, by name
+func GetAudienceLists(db *sql.DB, userId int) ([]AudienceList, error) {
+	lists := []AudienceList{}
+
+	rows, err := db.Query(
+		`SELECT l.id, l.user_id, l.name, l.created_at, l.updated_at,
+		(SELECT COUNT(*) FROM audience_list_members m WHERE m.list_id = l.id)
+		FROM audience_lists l
+		WHERE l.user_id = ?
+		ORDER BY l.name COLLATE NOCASE, l.id`,
+		userId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var list AudienceList
+		if err := rows.Scan(&list.ID, &list.UserID, &list.Name, &list.CreatedAt, &list.UpdatedAt, &list.MemberCount); err != nil {
+			return nil, err
+		}
+		lists = append(lists, list)
+	}
+
+	return lists, rows.Err()
+}
+
+// GetAudienceList retrieves one of a user's audience lists with its members.
+// Other users' lists are reported as not found.
+func GetAudienceList(db *sql.DB, listId int, userId int) (*AudienceList, error) {
+	list := &AudienceList{}
+	err := db.QueryRow(
+		"SELECT id, user_id, name, created_at, updated_at FROM audience_lists WHERE id = ? AND user_id = ?",
+		listId, userId,
+	).Scan(&list.ID, &list.UserID, &list.Name, &list.CreatedAt, &list.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrAudienceListNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(
+		`SELECT u.id, u.first_name, u.last_name, u.avatar, u.nickname
+		FROM audience_list_members m
+		JOIN users u ON m.user_id = u.id
+		WHERE m.list_id = ?
+		ORDER BY u.first_name, u.last_name, u.id`,
+		listId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list.Members = []User{}
+	for rows.Next() {
+		var member User
+		if err := rows.Scan(&member.ID, &member.FirstName, &member.LastName, &member.Avatar, &member.Nickname); err != nil {
+			return nil, err
+		}
+		list.Members = append(list.Members, member)
+	}
+	list.MemberCount = len(list.Members)
+
+	return list, rows.Err()
+}
+
+// RenameAudienceList renames one of a user's audience lists
+func RenameAudienceList(db *sql.DB, listId int, userId int, name string) error {
+	name, err := audienceListName(name)
+	if err != nil {
+		return err
+	}
+
+	// Check no other list of the user has this name
+	var exists bool
+	err = db.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM audience_lists WHERE user_id = ? AND name = ? AND id != ?)",
+		userId, name, listId,
+	).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrAudienceListNameTaken
+	}
+
+	return updateAudienceList(db, listId, userId, "UPDATE audience_lists SET name = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND user_id = ?", name)
+}
+
+// DeleteAudienceList deletes one of a user's audience lists. Its members lose access to the
+// private posts shared with them through it.
+func DeleteAudienceList(db *sql.DB, listId int, userId int) error {
+	return updateAudienceList(db, listId, userId, "DELETE FROM audience_lists WHERE id = ? AND user_id = ?")
+}
+
+// AddAudienceListMember adds a user to one of its owner's audience lists, giving them access to
+// the private posts shared with the list, including earlier ones
+func AddAudienceListMember(db *sql.DB, listId int, ownerId int, memberId int) error {
+	if _, err := GetAudienceList(db, listId, ownerId); err != nil {
+		return err
+	}
+	if memberId == ownerId {
+		return ErrInvalidAudienceListMember
+	}
+
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)", memberId).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrInvalidAudienceListMember
+	}
+
+	_, err = db.Exec(
+		"INSERT OR IGNORE INTO audience_list_members (list_id, user_id) VALUES (?, ?)",
+		listId, memberId,
+	)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("UPDATE audience_lists SET updated_at = CURRENT_TIMESTAMP WHERE id = ?", listId)
+	return err
+}
+
+// RemoveAudienceListMember removes a user from one of its owner's audience lists, taking away
+// their access to the private posts shared with the list
+func RemoveAudienceListMember(db *sql.DB, listId int, ownerId int, memberId int) error {
+	if _, err := GetAudienceList(db, listId, ownerId); err != nil {
+		return err
+	}
+
+	_, err := db.Exec("DELETE FROM audience_list_members WHERE list_id = ? AND user_id = ?", listId, memberId)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("UPDATE audience_lists SET updated_at = CURRENT_TIMESTAMP WHERE id = ?", listId)
+	return err
+}
+
+// updateAudienceList runs a statement on one of a user's audience lists. The list and owner IDs
+// bind to the statement's last two placeholders.
+func updateAudienceList(db *sql.DB, listId int, userId int, query string, args ...interface{}) error {
+	args = append(args, listId, userId)
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrAudienceListNotFound
+	}
+	return nil
+}
+
+// setPostAudienceLists replaces the audience lists a private post is shared with. Only the
+// author's own lists can be used. It must run in the transaction that creates or edits the post.
+func setPostAudienceLists(tx *sql.Tx, postId int, userId int, listIds []int) error {
+	if _, err := tx.Exec("DELETE FROM post_audience_lists WHERE post_id = ?", postId); err != nil {
+		return err
+	}
+
+	seen := map[int]bool{}
+	for _, listId := range listIds {
+		if seen[listId] {
+			continue
+		}
+		seen[listId] = true
+
+		result, err := tx.Exec(
+			`INSERT INTO post_audience_lists (post_id, list_id)
+			SELECT ?, id FROM audience_lists WHERE id = ? AND user_id = ?`,
+			postId, listId, userId,
+		)
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return ErrAudienceListNotFound
+		}
+	}
+
+	return nil
+}
+
+// getPostAudienceLists retrieves the IDs of the audience lists a post is shared with
+func getPostAudienceLists(db *sql.DB, postId int) ([]int, error) {
+	rows, err := db.Query("SELECT list_id FROM post_audience_lists WHERE post_id = ? ORDER BY list_id", postId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	listIds := []int{}
+	for rows.Next() {
+		var listId int
+		if err := rows.Scan(&listId); err != nil {
+			return nil, err
+		}
+		listIds = append(listIds, listId)
+	}
+
+	return listIds, rows.Err()
+}
@@ -7,23 +7,34 @@ import (
 )
 
 type Comment struct {
-	ID           int       `json:"id"`
-	PostID       int       `json:"post_id"`
-	UserID       int       `json:"user_id"`
-	ParentID     *int      `json:"parent_id"`
-	Content      string    `json:"content"`
-	ImageURL     string    `json:"image_url,omitempty"`
-	LikeCount    int       `json:"like_count"`
-	DislikeCount int       `json:"dislike_count"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-	User         *User     `json:"user,omitempty"`
-	Replies      []Comment `json:"replies,omitempty"`
+	ID            int       `json:"id"`
+	PostID        int       `json:"post_id"`
+	UserID        int       `json:"user_id"`
+	ParentID      *int      `json:"parent_id"`
+	Content       string    `json:"content"`
+	ImageURL      string    `json:"image_url,omitempty"`
+	Media         []Media   `json:"media"`
+	LikeCount     int       `json:"like_count"`
+	DislikeCount  int       `json:"dislike_count"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Hidden        bool      `json:"hidden,omitempty"`
+	User          *User     `json:"user,omitempty"`
+	Replies       []Comment `json:"replies,omitempty"`
+	Edited        bool      `json:"edited"`
+	RevisionCount int       `json:"revision_count"`
 }
 
 // CreateComment creates a new comment
 func CreateComment(db *sql.DB, comment Comment) (int, error) {
-	result, err := db.Exec(
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
 		`INSERT INTO comments (post_id, user_id, parent_id, content, image_url)
 		VALUES (?, ?, ?, ?, ?)`,
 		comment.PostID, comment.UserID, comment.ParentID, comment.Content, comment.ImageURL,
@@ -37,6 +48,21 @@ func CreateComment(db *sql.DB, comment Comment) (int, error) {
 		return 0, err
 	}
 
+	// Index hashtags and mentions
+	if err := indexContentTags(tx, TagTargetComment, int(commentId), comment.UserID, comment.Content); err != nil {
+		return 0, err
+	}
+
+	// Attach uploads
+	if err := attachMedia(tx, MediaTargetComment, int(commentId), comment.UserID, comment.Media, comment.ImageURL); err != nil {
+		return 0, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
 	return int(commentId), nil
 }
 
@@ -48,13 +74,13 @@ func GetCommentById(db *sql.DB, commentId int) (*Comment, error) {
 	err := db.QueryRow(
 		`SELECT c.id, c.post_id, c.user_id, c.parent_id, c.content, c.image_url, 
 		COALESCE(c.like_count, 0) as like_count, COALESCE(c.dislike_count, 0) as dislike_count, 
-		c.created_at, c.updated_at
+		c.created_at, c.updated_at, c.hidden_at IS NOT NULL, COALESCE(c.revision_count, 0)
 		FROM comments c
 		WHERE c.id = ?`,
 		commentId,
 	).Scan(
 		&comment.ID, &comment.PostID, &comment.UserID, &comment.ParentID, &comment.Content, &comment.ImageURL,
-		&comment.LikeCount, &comment.DislikeCount, &comment.CreatedAt, &comment.UpdatedAt,
+		&comment.LikeCount, &comment.DislikeCount, &comment.CreatedAt, &comment.UpdatedAt, &comment.Hidden, &comment.RevisionCount,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -62,6 +88,7 @@ func GetCommentById(db *sql.DB, commentId int) (*Comment, error) {
 		}
 		return nil, err
 	}
+	comment.Edited = comment.RevisionCount > 0
 
 	// Get comment user
 	comment.User, err = GetUserById(db, comment.UserID)
@@ -69,10 +96,17 @@ func GetCommentById(db *sql.DB, commentId int) (*Comment, error) {
 		return nil, err
 	}
 
+	// Get attachments
+	comment.Media, err = GetMedia(db, MediaTargetComment, comment.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	return comment, nil
 }
 
-// GetPostComments retrieves comments for a post
+// GetPostComments retrieves comments for a post.
+// Comments hidden by a moderator are only included for their author, given as the viewerId option.
 func GetPostComments(db *sql.DB, postId int, options map[string]interface{}) ([]Comment, error) {
 	comments := []Comment{}
 
@@ -80,6 +114,7 @@ func GetPostComments(db *sql.DB, postId int, options map[string]interface{}) ([]
 	page := 1
 	limit := 20
 	var parentId *int = nil
+	viewerId := 0
 
 	// Override with options if provided
 	if p, ok := options["page"].(int); ok {
@@ -91,6 +126,9 @@ func GetPostComments(db *sql.DB, postId int, options map[string]interface{}) ([]
 	if p, ok := options["parentId"].(*int); ok {
 		parentId = p
 	}
+	if v, ok := options["viewerId"].(int); ok {
+		viewerId = v
+	}
 
 	offset := (page - 1) * limit
 
@@ -103,42 +141,42 @@ func GetPostComments(db *sql.DB, postId int, options map[string]interface{}) ([]
 		query = `
 			SELECT c.id, c.post_id, c.user_id, c.parent_id, c.content, c.image_url, 
 			COALESCE(c.like_count, 0) as like_count, COALESCE(c.dislike_count, 0) as dislike_count, 
-			c.created_at, c.updated_at,
+			c.created_at, c.updated_at, c.hidden_at IS NOT NULL, COALESCE(c.revision_count, 0),
 			u.id as user_id, u.email, u.first_name, u.last_name, u.avatar, u.nickname
 			FROM comments c
 			JOIN users u ON c.user_id = u.id
-			WHERE c.post_id = ? AND c.parent_id IS NULL
+			WHERE c.post_id = ? AND c.parent_id IS NULL AND (c.hidden_at IS NULL OR c.user_id = ?)
 			ORDER BY c.created_at DESC
 			LIMIT ? OFFSET ?
 		`
-		args = []interface{}{postId, limit, offset}
+		args = []interface{}{postId, viewerId, limit, offset}
 	} else if *parentId == -1 {
 		// Special case: get all replies for the post (no pagination)
 		query = `
 			SELECT c.id, c.post_id, c.user_id, c.parent_id, c.content, c.image_url, 
 			COALESCE(c.like_count, 0) as like_count, COALESCE(c.dislike_count, 0) as dislike_count, 
-			c.created_at, c.updated_at,
+			c.created_at, c.updated_at, c.hidden_at IS NOT NULL, COALESCE(c.revision_count, 0),
 			u.id as user_id, u.email, u.first_name, u.last_name, u.avatar, u.nickname
 			FROM comments c
 			JOIN users u ON c.user_id = u.id
-			WHERE c.post_id = ? AND c.parent_id IS NOT NULL
+			WHERE c.post_id = ? AND c.parent_id IS NOT NULL AND (c.hidden_at IS NULL OR c.user_id = ?)
 			ORDER BY c.created_at DESC
 		`
-		args = []interface{}{postId}
+		args = []interface{}{postId, viewerId}
 	} else {
 		// Get replies to a specific comment
 		query = `
 			SELECT c.id, c.post_id, c.user_id, c.parent_id, c.content, c.image_url, 
 			COALESCE(c.like_count, 0) as like_count, COALESCE(c.dislike_count, 0) as dislike_count, 
-			c.created_at, c.updated_at,
+			c.created_at, c.updated_at, c.hidden_at IS NOT NULL, COALESCE(c.revision_count, 0),
 			u.id as user_id, u.email, u.first_name, u.last_name, u.avatar, u.nickname
 			FROM comments c
 			JOIN users u ON c.user_id = u.id
-			WHERE c.parent_id = ?
+			WHERE c.parent_id = ? AND (c.hidden_at IS NULL OR c.user_id = ?)
 			ORDER BY c.created_at DESC
 			LIMIT ? OFFSET ?
 		`
-		args = []interface{}{*parentId, limit, offset}
+		args = []interface{}{*parentId, viewerId, limit, offset}
 	}
 
 	rows, err := db.Query(query, args...)
@@ -153,12 +191,13 @@ func GetPostComments(db *sql.DB, postId int, options map[string]interface{}) ([]
 
 		err := rows.Scan(
 			&comment.ID, &comment.PostID, &comment.UserID, &comment.ParentID, &comment.Content, &comment.ImageURL,
-			&comment.LikeCount, &comment.DislikeCount, &comment.CreatedAt, &comment.UpdatedAt,
+			&comment.LikeCount, &comment.DislikeCount, &comment.CreatedAt, &comment.UpdatedAt, &comment.Hidden, &comment.RevisionCount,
 			&user.ID, &user.Email, &user.FirstName, &user.LastName, &user.Avatar, &user.Nickname,
 		)
 		if err != nil {
 			return nil, err
 		}
+		comment.Edited = comment.RevisionCount > 0
 
 		comment.User = &user
 
@@ -167,8 +206,8 @@ func GetPostComments(db *sql.DB, postId int, options map[string]interface{}) ([]
 			// Get reply count
 			var replyCount int
 			err := db.QueryRow(
-				"SELECT COUNT(*) FROM comments WHERE parent_id = ?",
-				comment.ID,
+				"SELECT COUNT(*) FROM comments WHERE parent_id = ? AND (hidden_at IS NULL OR user_id = ?)",
+				comment.ID, viewerId,
 			).Scan(&replyCount)
 			if err != nil {
 				return nil, err
@@ -181,6 +220,7 @@ func GetPostComments(db *sql.DB, postId int, options map[string]interface{}) ([]
 					"parentId": &comment.ID,
 					"limit":    replyLimit,
 					"page":     1,
+					"viewerId": viewerId,
 				})
 				if err != nil {
 					return nil, err
@@ -192,12 +232,17 @@ func GetPostComments(db *sql.DB, postId int, options map[string]interface{}) ([]
 		comments = append(comments, comment)
 	}
 
+	// Get attachments
+	if err := loadCommentMedia(db, comments); err != nil {
+		return nil, err
+	}
+
 	return comments, nil
 }
 
 // UpdateComment updates a comment
-func UpdateComment(db *sql.DB, commentId int, updates map[string]interface{}, userId int) error {
-	// Check if user owns the comment
+func UpdateComment(db *sql.DB, commentId int, updates map[string]interface{}, actor *User) error {
+	// Check if user may edit the comment
 	var commentUserId int
 	err := db.QueryRow("SELECT user_id FROM comments WHERE id = ?", commentId).Scan(&commentUserId)
 	if err != nil {
@@ -207,27 +252,57 @@ func UpdateComment(db *sql.DB, commentId int, updates map[string]interface{}, us
 		return err
 	}
 
-	if commentUserId != userId {
-		return errors.New("unauthorized to update this comment")
+	if !CanEditContent(actor, commentUserId) {
+		return ErrForbidden
+	}
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Keep the current version in the comment's history
+	content, _ := updates["content"].(string)
+	imageUrl, _ := updates["image_url"].(string)
+	if err := recordRevision(tx, RevisionTargetComment, commentId, content, imageUrl); err != nil {
+		return err
 	}
 
 	// Update comment
-	_, err = db.Exec(
+	_, err = tx.Exec(
 		`UPDATE comments SET content = ?, image_url = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
 		updates["content"], updates["image_url"], commentId,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+
+	// Re-index hashtags and mentions
+	if err := indexContentTags(tx, TagTargetComment, commentId, commentUserId, content); err != nil {
+		return err
+	}
+
+	// Replace attachments with the new ones, or the single image of clients that send image_url
+	media, _ := updates["media"].([]Media)
+	if err := attachMedia(tx, MediaTargetComment, commentId, commentUserId, media, imageUrl); err != nil {
+		return err
+	}
+
+	// Commit transaction
+	return tx.Commit()
 }
 
 // DeleteComment deletes a comment
-func DeleteComment(db *sql.DB, commentId int, userId int, postOwnerId int) error {
+func DeleteComment(db *sql.DB, commentId int, actor *User) error {
 	// Check if comment exists
 	var commentUserId int
-	var postId int
+	var postOwnerId int
 	err := db.QueryRow(
-		"SELECT user_id, post_id FROM comments WHERE id = ?",
+		"SELECT c.user_id, p.user_id FROM comments c JOIN posts p ON c.post_id = p.id WHERE c.id = ?",
 		commentId,
-	).Scan(&commentUserId, &postId)
+	).Scan(&commentUserId, &postOwnerId)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return errors.New("comment not found")
@@ -235,10 +310,9 @@ func DeleteComment(db *sql.DB, commentId int, userId int, postOwnerId int) error
 		return err
 	}
 
-	// Check if user is authorized to delete the comment
-	// User can delete if they are the comment author, post owner, or admin
-	if commentUserId != userId && postOwnerId != userId {
-		return errors.New("unauthorized to delete this comment")
+	// The comment author, the post owner and moderators can delete the comment
+	if !CanDeleteContent(actor, commentUserId, postOwnerId) {
+		return ErrForbidden
 	}
 
 	// Delete comment (cascade will handle replies)
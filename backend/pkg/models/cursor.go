@@ -0,0 +1,66 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor is returned when a pagination cursor can't be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// sqliteTimestampLayout is how CURRENT_TIMESTAMP formats times, which is how created_at
+// columns are stored. Cursors are compared against those columns in this format.
+const sqliteTimestampLayout = "2006-01-02 15:04:05"
+
+// Cursor marks a position in a list sorted newest first by (created_at, id). A page of the list
+// ends with the cursor of its last item and the next page starts after it, so items added
+// while someone scrolls don't push items they have already seen onto the next page.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        int
+}
+
+// Encode returns the cursor as an opaque, URL-safe string
+func (c Cursor) Encode() string {
+	raw := strconv.FormatInt(c.CreatedAt.Unix(), 10) + ":" + strconv.Itoa(c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor parses a cursor made by Encode
+func DecodeCursor(encoded string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	seconds, id, found := strings.Cut(string(raw), ":")
+	if !found {
+		return nil, ErrInvalidCursor
+	}
+	unix, err := strconv.ParseInt(seconds, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	cursorId, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &Cursor{CreatedAt: time.Unix(unix, 0).UTC(), ID: cursorId}, nil
+}
+
+// timestamp returns the cursor's time as SQLite stores it
+func (c Cursor) timestamp() string {
+	return c.CreatedAt.UTC().Format(sqliteTimestampLayout)
+}
+
+// pageOffset is the offset for a page number, or 0 when paging by cursor
+func pageOffset(after *Cursor, page int, limit int) int {
+	if after != nil {
+		return 0
+	}
+	return (page - 1) * limit
+}
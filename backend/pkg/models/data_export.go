@@ -0,0 +1,344 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"time"
+)
+
+// Data export statuses
+const (
+	DataExportPending    = "pending"
+	DataExportProcessing = "processing"
+	DataExportReady      = "ready"
+	DataExportFailed     = "failed"
+)
+
+var (
+	// ErrDataExportNotFound is returned when an export does not exist or belongs to another user
+	ErrDataExportNotFound = errors.New("data export not found")
+	// ErrDataExportInProgress is returned when requesting an export while another is still being built
+	ErrDataExportInProgress = errors.New("a data export is already in progress")
+)
+
+// DataExport is a request for a ZIP archive of everything a user has stored
+type DataExport struct {
+	ID          int        `json:"id"`
+	UserID      int        `json:"user_id"`
+	Status      string     `json:"status"`
+	FileName    string     `json:"-"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// DataExportDir returns the directory finished exports are written to.
+// It is kept apart from ./uploads because exports must not be publicly served.
+func DataExportDir() string {
+	if dir := os.Getenv("DATA_EXPORT_DIR"); dir != "" {
+		return dir
+	}
+	return "./exports"
+}
+
+// DataExportTTL returns how long a finished export stays available for download
+func DataExportTTL() time.Duration {
+	return durationFromEnv("DATA_EXPORT_TTL", 7*24*time.Hour)
+}
+
+// scanDataExport scans an export row selected with dataExportColumns
+func scanDataExport(row interface{ Scan(...interface{}) error }, export *DataExport) error {
+	var fileName, exportError sql.NullString
+	var completedAt, expiresAt sql.NullTime
+	err := row.Scan(
+		&export.ID, &export.UserID, &export.Status, &fileName, &exportError,
+		&export.CreatedAt, &completedAt, &expiresAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	export.FileName = fileName.String
+	export.Error = exportError.String
+	if completedAt.Valid {
+		export.CompletedAt = &completedAt.Time
+	}
+	if expiresAt.Valid {
+		export.ExpiresAt = &expiresAt.Time
+	}
+	return nil
+}
+
+const dataExportColumns = `id, user_id, status, file_name, error, created_at, completed_at, expires_at`
+
+// CreateDataExport queues a new export for a user
+func CreateDataExport(db *sql.DB, userId int) (*DataExport, error) {
+	// Only one export is built at a time per user
+	var inProgress bool
+	err := db.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM data_exports WHERE user_id = ? AND status IN (?, ?))",
+		userId, DataExportPending, DataExportProcessing,
+	).Scan(&inProgress)
+	if err != nil {
+		return nil, err
+	}
+	if inProgress {
+		return nil, ErrDataExportInProgress
+	}
+
+	now := time.Now()
+	result, err := db.Exec(
+		"INSERT INTO data_exports (user_id, status, created_at) VALUES (?, ?, ?)",
+		userId, DataExportPending, now,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return &DataExport{ID: int(id), UserID: userId, Status: DataExportPending, CreatedAt: now}, nil
+}
+
+// GetUserDataExports retrieves a user's exports, newest first
+func GetUserDataExports(db *sql.DB, userId int) ([]DataExport, error) {
+	exports := []DataExport{}
+
+	rows, err := db.Query(
+		`SELECT `+dataExportColumns+` FROM data_exports WHERE user_id = ? ORDER BY created_at DESC`,
+		userId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var export DataExport
+		if err := scanDataExport(rows, &export); err != nil {
+			return nil, err
+		}
+		exports = append(exports, export)
+	}
+
+	return exports, nil
+}
+
+// GetUserDataExport retrieves one of a user's exports by ID
+func GetUserDataExport(db *sql.DB, exportId int, userId int) (*DataExport, error) {
+	export := &DataExport{}
+	err := scanDataExport(db.QueryRow(
+		`SELECT `+dataExportColumns+` FROM data_exports WHERE id = ? AND user_id = ?`,
+		exportId, userId,
+	), export)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrDataExportNotFound
+		}
+		return nil, err
+	}
+	return export, nil
+}
+
+// ClaimNextDataExport marks the oldest pending export as processing and returns it,
+// or nil when there is nothing to do
+func ClaimNextDataExport(db *sql.DB) (*DataExport, error) {
+	export := &DataExport{}
+	err := scanDataExport(db.QueryRow(
+		`SELECT `+dataExportColumns+` FROM data_exports WHERE status = ? ORDER BY created_at LIMIT 1`,
+		DataExportPending,
+	), export)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	_, err = db.Exec(
+		"UPDATE data_exports SET status = ? WHERE id = ? AND status = ?",
+		DataExportProcessing, export.ID, DataExportPending,
+	)
+	if err != nil {
+		return nil, err
+	}
+	export.Status = DataExportProcessing
+
+	return export, nil
+}
+
+// ResetInterruptedDataExports requeues exports that were being built when the server stopped
+func ResetInterruptedDataExports(db *sql.DB) error {
+	_, err := db.Exec(
+		"UPDATE data_exports SET status = ? WHERE status = ?",
+		DataExportPending, DataExportProcessing,
+	)
+	return err
+}
+
+// CompleteDataExport marks an export as ready for download
+func CompleteDataExport(db *sql.DB, exportId int, fileName string) error {
+	now := time.Now()
+	_, err := db.Exec(
+		"UPDATE data_exports SET status = ?, file_name = ?, completed_at = ?, expires_at = ? WHERE id = ?",
+		DataExportReady, fileName, now, now.Add(DataExportTTL()), exportId,
+	)
+	return err
+}
+
+// FailDataExport marks an export as failed
+func FailDataExport(db *sql.DB, exportId int, reason string) error {
+	_, err := db.Exec(
+		"UPDATE data_exports SET status = ?, error = ?, completed_at = ? WHERE id = ?",
+		DataExportFailed, reason, time.Now(), exportId,
+	)
+	return err
+}
+
+// GetExpiredDataExports retrieves finished exports whose download window has passed
+func GetExpiredDataExports(db *sql.DB) ([]DataExport, error) {
+	exports := []DataExport{}
+
+	rows, err := db.Query(
+		`SELECT `+dataExportColumns+` FROM data_exports WHERE expires_at IS NOT NULL AND expires_at <= ?`,
+		time.Now(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var export DataExport
+		if err := scanDataExport(rows, &export); err != nil {
+			return nil, err
+		}
+		exports = append(exports, export)
+	}
+
+	return exports, nil
+}
+
+// DeleteDataExport deletes an export record
+func DeleteDataExport(db *sql.DB, exportId int) error {
+	_, err := db.Exec("DELETE FROM data_exports WHERE id = ?", exportId)
+	return err
+}
+
+// userDataQueries lists what goes into a data export, one JSON file per entry
+var userDataQueries = []struct {
+	name  string
+	query string
+	args  int // how many times the user ID is bound
+}{
+	{"profile", `SELECT u.id, u.email, u.first_name, u.last_name, u.date_of_birth, u.avatar, u.nickname,
+		u.about_me, u.created_at, u.updated_at, u.email_verified_at, p.is_public
+		FROM users u LEFT JOIN user_profiles p ON u.id = p.user_id WHERE u.id = ?`, 1},
+	{"activity_settings", `SELECT show_posts, show_comments, show_likes, show_to_followers_only, updated_at
+		FROM user_activity_settings WHERE user_id = ?`, 1},
+	{"posts", `SELECT id, content, image_url, privacy, like_count, dislike_count, status, publish_at, created_at, updated_at
+		FROM posts WHERE user_id = ? ORDER BY created_at`, 1},
+	{"post_audiences", `SELECT ppu.post_id, ppu.user_id FROM post_privacy_users ppu
+		JOIN posts p ON ppu.post_id = p.id WHERE p.user_id = ?`, 1},
+	{"post_audience_lists", `SELECT pal.post_id, pal.list_id FROM post_audience_lists pal
+		JOIN posts p ON pal.post_id = p.id WHERE p.user_id = ?`, 1},
+	{"audience_lists", `SELECT l.id, l.name, l.created_at, l.updated_at,
+		(SELECT json_group_array(m.user_id) FROM audience_list_members m WHERE m.list_id = l.id) AS member_ids
+		FROM audience_lists l WHERE l.user_id = ? ORDER BY l.id`, 1},
+	{"comments", `SELECT id, post_id, parent_id, content, image_url, created_at, updated_at
+		FROM comments WHERE user_id = ? ORDER BY created_at`, 1},
+	{"group_posts", `SELECT id, group_id, content, image_url, created_at, updated_at
+		FROM group_posts WHERE user_id = ? ORDER BY created_at`, 1},
+	{"group_post_comments", `SELECT id, group_post_id, parent_id, content, image_url, created_at, updated_at
+		FROM group_post_comments WHERE user_id = ? ORDER BY created_at`, 1},
+	{"post_revisions", `SELECT r.post_id, r.content, r.privacy, r.created_at, r.replaced_at
+		FROM post_revisions r JOIN posts p ON r.post_id = p.id WHERE p.user_id = ? ORDER BY r.id`, 1},
+	{"comment_revisions", `SELECT r.comment_id, r.content, r.image_url, r.created_at, r.replaced_at
+		FROM comment_revisions r JOIN comments c ON r.comment_id = c.id WHERE c.user_id = ? ORDER BY r.id`, 1},
+	{"group_post_comment_revisions", `SELECT r.comment_id, r.content, r.image_url, r.created_at, r.replaced_at
+		FROM group_post_comment_revisions r JOIN group_post_comments c ON r.comment_id = c.id
+		WHERE c.user_id = ? ORDER BY r.id`, 1},
+	{"media", `SELECT id, url, mime_type, width, height, alt_text, target_type, target_id, position, created_at
+		FROM media WHERE user_id = ? ORDER BY id`, 1},
+	{"poll_votes", `SELECT v.poll_id, v.option_id, o.text AS option_text, v.created_at
+		FROM poll_votes v JOIN poll_options o ON v.option_id = o.id WHERE v.user_id = ? ORDER BY v.created_at`, 1},
+	{"messages", `SELECT id, sender_id, receiver_id, group_id, content, is_read, created_at
+		FROM messages WHERE sender_id = ? OR receiver_id = ? ORDER BY created_at`, 2},
+	{"follows", `SELECT follower_id, following_id, status, created_at, updated_at
+		FROM follows WHERE follower_id = ? OR following_id = ? ORDER BY created_at`, 2},
+	{"group_memberships", `SELECT gm.group_id, g.title, gm.status, gm.invited_by, g.creator_id = gm.user_id AS is_creator,
+		gm.created_at, gm.updated_at
+		FROM group_members gm JOIN groups g ON gm.group_id = g.id WHERE gm.user_id = ? ORDER BY gm.created_at`, 1},
+	{"activities", `SELECT id, activity_type, target_type, target_id, target_user_id, metadata, is_hidden, created_at
+		FROM user_activities WHERE user_id = ? ORDER BY created_at`, 1},
+}
+
+// CollectUserData gathers everything stored about a user for a data export,
+// keyed by section name. Each section is a list of rows.
+func CollectUserData(db *sql.DB, userId int) (map[string][]map[string]interface{}, error) {
+	data := map[string][]map[string]interface{}{}
+
+	for _, q := range userDataQueries {
+		args := make([]interface{}, q.args)
+		for i := range args {
+			args[i] = userId
+		}
+
+		rows, err := queryRowMaps(db, q.query, args...)
+		if err != nil {
+			return nil, err
+		}
+		data[q.name] = rows
+	}
+
+	return data, nil
+}
+
+// queryRowMaps runs a query and returns each row as a column name to value map
+func queryRowMaps(db *sql.DB, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanRowMaps(rows)
+}
+
+// scanRowMaps reads every row as a column name to value map and closes the rows
+func scanRowMaps(rows *sql.Rows) ([]map[string]interface{}, error) {
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []map[string]interface{}{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			// Text comes back as bytes from the driver
+			if b, ok := values[i].([]byte); ok {
+				row[column] = string(b)
+			} else {
+				row[column] = values[i]
+			}
+		}
+		result = append(result, row)
+	}
+
+	return result, rows.Err()
+}
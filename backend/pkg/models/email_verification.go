@@ -0,0 +1,114 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"time"
+)
+
+// ErrInvalidVerificationToken is returned when a verification token is unknown, expired or already used
+var ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+
+// EmailVerificationRequired reports whether unverified accounts are restricted,
+// as configured by the REQUIRE_EMAIL_VERIFICATION environment variable
+func EmailVerificationRequired() bool {
+	return os.Getenv("REQUIRE_EMAIL_VERIFICATION") == "true"
+}
+
+// CreateEmailVerificationToken creates a token that confirms the given address for a user and returns it.
+// Any earlier unused tokens for the user are invalidated.
+func CreateEmailVerificationToken(db *sql.DB, userId int, email string) (string, error) {
+	token, err := generateToken(32)
+	if err != nil {
+		return "", err
+	}
+	expiresAt := time.Now().Add(durationFromEnv("EMAIL_VERIFICATION_TTL", 48*time.Hour))
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	// Invalidate previous tokens
+	_, err = tx.Exec("DELETE FROM email_verifications WHERE user_id = ? AND used_at IS NULL", userId)
+	if err != nil {
+		return "", err
+	}
+
+	// Insert new token
+	_, err = tx.Exec(
+		`INSERT INTO email_verifications (user_id, email, token_hash, expires_at) VALUES (?, ?, ?, ?)`,
+		userId, email, hashToken(token), expiresAt,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// VerifyEmail consumes a verification token and marks its address as the user's verified email.
+// This is also how an email change takes effect. It returns the ID of the verified user.
+func VerifyEmail(db *sql.DB, token string) (int, error) {
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Find a valid token
+	var verificationId, userId int
+	var email string
+	err = tx.QueryRow(
+		`SELECT id, user_id, email FROM email_verifications
+		WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?`,
+		hashToken(token), time.Now(),
+	).Scan(&verificationId, &userId, &email)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrInvalidVerificationToken
+		}
+		return 0, err
+	}
+
+	// The address may have been taken since the token was sent
+	var taken bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE email = ? AND id != ?)", email, userId).Scan(&taken)
+	if err != nil {
+		return 0, err
+	}
+	if taken {
+		return 0, ErrEmailInUse
+	}
+
+	// Mark token as used
+	_, err = tx.Exec("UPDATE email_verifications SET used_at = ? WHERE id = ?", time.Now(), verificationId)
+	if err != nil {
+		return 0, err
+	}
+
+	// Mark email as verified
+	_, err = tx.Exec(
+		"UPDATE users SET email = ?, email_verified_at = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		email, time.Now(), userId,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return userId, nil
+}
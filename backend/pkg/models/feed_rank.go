@@ -0,0 +1,147 @@
+package models
+
+import (
+	"database/sql"
+	"math"
+	"sort"
+	"time"
+)
+
+// RankedFeedCandidates is how many of the newest feed posts a ranked feed orders.
+// Older posts are left to the latest feed.
+const RankedFeedCandidates = 500
+
+// FeedSignals are what a feed ranking knows about a post and how the viewer relates to it
+type FeedSignals struct {
+	Age           time.Duration
+	LikeCount     int
+	DislikeCount  int
+	CommentCount  int
+	FollowsAuthor bool
+	// Interactions counts the viewer's likes, comments and shares of the author's content
+	Interactions int
+	OwnPost      bool
+}
+
+// FeedScorer scores a post for a ranked feed. Higher scores are shown first.
+type FeedScorer func(signals FeedSignals) float64
+
+// EngagementScore is the default top feed ranking. Reactions, comments and the viewer's ties to
+// the author add to a post's weight, with diminishing returns, and the weight decays as the post
+// ages so new posts with some engagement rise above old popular ones.
+func EngagementScore(signals FeedSignals) float64 {
+	weight := 1.0 +
+		math.Log1p(float64(signals.LikeCount)) +
+		1.5*math.Log1p(float64(signals.CommentCount)) -
+		0.5*math.Log1p(float64(signals.DislikeCount)) +
+		0.75*math.Log1p(float64(signals.Interactions))
+	if signals.FollowsAuthor {
+		weight += 1
+	}
+	if weight < 0.1 {
+		weight = 0.1
+	}
+
+	hours := signals.Age.Hours()
+	if hours < 0 {
+		hours = 0
+	}
+	return weight / math.Pow(hours+2, 1.5)
+}
+
+// GetRankedFeedPosts retrieves the same posts as the latest feed, ranked by a scorer instead of by
+// date. Only the newest RankedFeedCandidates posts are ranked. It also reports whether there are
+// more posts after this page.
+func GetRankedFeedPosts(db *sql.DB, userId int, scorer FeedScorer, page, limit int) ([]Post, bool, error) {
+	rows, err := db.Query(
+		`SELECT p.id, p.user_id, p.content, p.image_url, p.privacy,
+		COALESCE(p.like_count, 0) as like_count, COALESCE(p.dislike_count, 0) as dislike_count,
+		p.created_at, p.updated_at, p.hidden_at IS NOT NULL, COALESCE(p.revision_count, 0), p.status, p.publish_at,
+		u.id as user_id, u.email, u.first_name, u.last_name, u.avatar, u.nickname,
+		(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id AND c.hidden_at IS NULL) AS comment_count,
+		EXISTS (
+			SELECT 1 FROM follows
+			WHERE follower_id = ? AND following_id = p.user_id AND status = 'accepted'
+		) AS follows_author,
+		(
+			SELECT COUNT(*) FROM user_activities ua
+			WHERE ua.user_id = ? AND ua.target_user_id = p.user_id
+			AND ua.activity_type IN ('post_liked', 'comment_created', 'comment_liked', 'post_shared')
+		) AS interactions
+		FROM posts p
+		JOIN users u ON p.user_id = u.id
+		WHERE `+feedPostCondition+`
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT ?`,
+		userId, userId, userId, userId, userId, userId, userId, userId, RankedFeedCandidates,
+	)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	type rankedPost struct {
+		post  Post
+		score float64
+	}
+	now := time.Now()
+	ranked := []rankedPost{}
+	for rows.Next() {
+		var post Post
+		var user User
+		var publishAt sql.NullTime
+		var signals FeedSignals
+		err := rows.Scan(
+			&post.ID, &post.UserID, &post.Content, &post.ImageURL, &post.Privacy,
+			&post.LikeCount, &post.DislikeCount, &post.CreatedAt, &post.UpdatedAt, &post.Hidden, &post.RevisionCount,
+			&post.Status, &publishAt,
+			&user.ID, &user.Email, &user.FirstName, &user.LastName, &user.Avatar, &user.Nickname,
+			&signals.CommentCount, &signals.FollowsAuthor, &signals.Interactions,
+		)
+		if err != nil {
+			return nil, false, err
+		}
+		post.Edited = post.RevisionCount > 0
+		if publishAt.Valid {
+			post.PublishAt = &publishAt.Time
+		}
+		post.User = &user
+
+		signals.Age = now.Sub(post.CreatedAt)
+		signals.LikeCount = post.LikeCount
+		signals.DislikeCount = post.DislikeCount
+		signals.OwnPost = post.UserID == userId
+		ranked = append(ranked, rankedPost{post: post, score: scorer(signals)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	// Rank by score, newest first among equal scores
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].score > ranked[j].score
+	})
+
+	posts := []Post{}
+	offset := (page - 1) * limit
+	for i := offset; i < len(ranked) && i < offset+limit; i++ {
+		posts = append(posts, ranked[i].post)
+	}
+
+	// Get attachments
+	if err := loadPostMedia(db, MediaTargetPost, posts); err != nil {
+		return nil, false, err
+	}
+
+	// Get the posts they share
+	if err := loadPostShares(db, posts, userId); err != nil {
+		return nil, false, err
+	}
+
+	// Get polls
+	if err := loadPostPolls(db, PollTargetPost, posts, userId); err != nil {
+		return nil, false, err
+	}
+
+	return posts, offset+limit < len(ranked), nil
+}
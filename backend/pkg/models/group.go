@@ -172,6 +172,16 @@ func GetGroupById(db *sql.DB, groupId int) (*Group, error) {
 	return group, nil
 }
 
+// IsGroupMember reports whether a user is an accepted member of a group
+func IsGroupMember(db *sql.DB, groupId int, userId int) (bool, error) {
+	var exists bool
+	err := db.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM group_members WHERE group_id = ? AND user_id = ? AND status = 'accepted')",
+		groupId, userId,
+	).Scan(&exists)
+	return exists, err
+}
+
 // GetGroupMembers retrieves members of a group
 func GetGroupMembers(db *sql.DB, groupId int) ([]Member, error) {
 	members := []Member{}
@@ -252,8 +262,8 @@ func JoinGroup(db *sql.DB, groupId int, userId int, invitedBy *int) error {
 }
 
 // RespondToGroupRequest handles accepting or declining a group join request
-func RespondToGroupRequest(db *sql.DB, groupId int, userId int, status string, responderId int) error {
-	// Check if responder is the group creator
+func RespondToGroupRequest(db *sql.DB, groupId int, userId int, status string, responder *User) error {
+	// Check if responder may manage the group
 	var creatorId int
 	err := db.QueryRow("SELECT creator_id FROM groups WHERE id = ?", groupId).Scan(&creatorId)
 	if err != nil {
@@ -262,8 +272,8 @@ func RespondToGroupRequest(db *sql.DB, groupId int, userId int, status string, r
 		}
 		return err
 	}
-	if creatorId != responderId {
-		return errors.New("only the group creator can respond to join requests")
+	if !CanManageGroup(responder, creatorId) {
+		return ErrForbidden
 	}
 
 	// Check if request exists
@@ -352,8 +362,9 @@ func LeaveGroup(db *sql.DB, groupId int, userId int) error {
 	return err
 }
 
-// CreateGroupPost creates a new post in a group
-func CreateGroupPost(db *sql.DB, groupId int, userId int, content string, imageUrl string) (int, error) {
+// CreateGroupPost creates a new post in a group with the given attachments,
+// or the single image at imageUrl if there are none, and an optional poll
+func CreateGroupPost(db *sql.DB, groupId int, userId int, content string, imageUrl string, media []Media, poll *Poll) (int, error) {
 	// Check if user is a member
 	var status string
 	err := db.QueryRow(
@@ -370,8 +381,15 @@ func CreateGroupPost(db *sql.DB, groupId int, userId int, content string, imageU
 		return 0, errors.New("user is not an accepted member of the group")
 	}
 
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
 	// Create post
-	result, err := db.Exec(
+	result, err := tx.Exec(
 		`INSERT INTO group_posts (group_id, user_id, content, image_url) VALUES (?, ?, ?, ?)`,
 		groupId, userId, content, imageUrl,
 	)
@@ -384,10 +402,32 @@ func CreateGroupPost(db *sql.DB, groupId int, userId int, content string, imageU
 		return 0, err
 	}
 
+	// Index hashtags and mentions
+	if err := indexContentTags(tx, TagTargetGroupPost, int(postId), userId, content); err != nil {
+		return 0, err
+	}
+
+	// Attach uploads
+	if err := attachMedia(tx, MediaTargetGroupPost, int(postId), userId, media, imageUrl); err != nil {
+		return 0, err
+	}
+
+	// Attach the poll
+	if poll != nil {
+		if err := createPoll(tx, PollTargetGroupPost, int(postId), poll); err != nil {
+			return 0, err
+		}
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
 	return int(postId), nil
 }
 
-// GetGroupPosts retrieves posts in a group
+// GetGroupPosts retrieves posts in a group. Posts hidden by a moderator are only included for their author.
 func GetGroupPosts(db *sql.DB, groupId int, userId int, page int, limit int) ([]Post, error) {
 	// Check if user is a member
 	var status string
@@ -409,14 +449,14 @@ func GetGroupPosts(db *sql.DB, groupId int, userId int, page int, limit int) ([]
 	posts := []Post{}
 
 	rows, err := db.Query(`
-		SELECT gp.id, gp.user_id, gp.content, gp.image_url, gp.created_at, gp.updated_at,
+		SELECT gp.id, gp.user_id, gp.content, gp.image_url, gp.created_at, gp.updated_at, gp.hidden_at IS NOT NULL,
 		u.id, u.email, u.first_name, u.last_name, u.avatar, u.nickname
 		FROM group_posts gp
 		JOIN users u ON gp.user_id = u.id
-		WHERE gp.group_id = ?
+		WHERE gp.group_id = ? AND (gp.hidden_at IS NULL OR gp.user_id = ?)
 		ORDER BY gp.created_at DESC
 		LIMIT ? OFFSET ?
-	`, groupId, limit, offset)
+	`, groupId, userId, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -427,7 +467,7 @@ func GetGroupPosts(db *sql.DB, groupId int, userId int, page int, limit int) ([]
 		var user User
 
 		err := rows.Scan(
-			&post.ID, &post.UserID, &post.Content, &post.ImageURL, &post.CreatedAt, &post.UpdatedAt,
+			&post.ID, &post.UserID, &post.Content, &post.ImageURL, &post.CreatedAt, &post.UpdatedAt, &post.Hidden,
 			&user.ID, &user.Email, &user.FirstName, &user.LastName, &user.Avatar, &user.Nickname,
 		)
 		if err != nil {
@@ -438,6 +478,16 @@ func GetGroupPosts(db *sql.DB, groupId int, userId int, page int, limit int) ([]
 		posts = append(posts, post)
 	}
 
+	// Get attachments
+	if err := loadPostMedia(db, MediaTargetGroupPost, posts); err != nil {
+		return nil, err
+	}
+
+	// Get polls
+	if err := loadPostPolls(db, PollTargetGroupPost, posts, userId); err != nil {
+		return nil, err
+	}
+
 	return posts, nil
 }
 
@@ -7,18 +7,21 @@ import (
 )
 
 type GroupPostComment struct {
-	ID           int                `json:"id"`
-	GroupPostID  int                `json:"group_post_id"`
-	UserID       int                `json:"user_id"`
-	ParentID     *int               `json:"parent_id"`
-	Content      string             `json:"content"`
-	ImageURL     string             `json:"image_url,omitempty"`
-	LikeCount    int                `json:"like_count"`
-	DislikeCount int                `json:"dislike_count"`
-	CreatedAt    time.Time          `json:"created_at"`
-	UpdatedAt    time.Time          `json:"updated_at"`
-	User         *User              `json:"user,omitempty"`
-	Replies      []GroupPostComment `json:"replies,omitempty"`
+	ID            int                `json:"id"`
+	GroupPostID   int                `json:"group_post_id"`
+	UserID        int                `json:"user_id"`
+	ParentID      *int               `json:"parent_id"`
+	Content       string             `json:"content"`
+	ImageURL      string             `json:"image_url,omitempty"`
+	Media         []Media            `json:"media"`
+	LikeCount     int                `json:"like_count"`
+	DislikeCount  int                `json:"dislike_count"`
+	CreatedAt     time.Time          `json:"created_at"`
+	UpdatedAt     time.Time          `json:"updated_at"`
+	User          *User              `json:"user,omitempty"`
+	Replies       []GroupPostComment `json:"replies,omitempty"`
+	Edited        bool               `json:"edited"`
+	RevisionCount int                `json:"revision_count"`
 }
 
 // CreateGroupPostComment creates a new comment on a group post
@@ -52,8 +55,15 @@ func CreateGroupPostComment(db *sql.DB, comment GroupPostComment) (int, error) {
 		return 0, errors.New("user is not an accepted member of the group")
 	}
 
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
 	// Create the comment
-	result, err := db.Exec(
+	result, err := tx.Exec(
 		`INSERT INTO group_post_comments (group_post_id, user_id, parent_id, content, image_url)
 		VALUES (?, ?, ?, ?, ?)`,
 		comment.GroupPostID, comment.UserID, comment.ParentID, comment.Content, comment.ImageURL,
@@ -67,6 +77,21 @@ func CreateGroupPostComment(db *sql.DB, comment GroupPostComment) (int, error) {
 		return 0, err
 	}
 
+	// Index hashtags and mentions
+	if err := indexContentTags(tx, TagTargetGroupPostComment, int(commentId), comment.UserID, comment.Content); err != nil {
+		return 0, err
+	}
+
+	// Attach uploads
+	if err := attachMedia(tx, MediaTargetGroupPostComment, int(commentId), comment.UserID, comment.Media, comment.ImageURL); err != nil {
+		return 0, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
 	return int(commentId), nil
 }
 
@@ -78,13 +103,13 @@ func GetGroupPostCommentById(db *sql.DB, commentId int) (*GroupPostComment, erro
 	err := db.QueryRow(
 		`SELECT c.id, c.group_post_id, c.user_id, c.parent_id, c.content, c.image_url, 
 		COALESCE(c.like_count, 0) as like_count, COALESCE(c.dislike_count, 0) as dislike_count, 
-		c.created_at, c.updated_at
+		c.created_at, c.updated_at, COALESCE(c.revision_count, 0)
 		FROM group_post_comments c
 		WHERE c.id = ?`,
 		commentId,
 	).Scan(
 		&comment.ID, &comment.GroupPostID, &comment.UserID, &comment.ParentID, &comment.Content, &comment.ImageURL,
-		&comment.LikeCount, &comment.DislikeCount, &comment.CreatedAt, &comment.UpdatedAt,
+		&comment.LikeCount, &comment.DislikeCount, &comment.CreatedAt, &comment.UpdatedAt, &comment.RevisionCount,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -92,6 +117,7 @@ func GetGroupPostCommentById(db *sql.DB, commentId int) (*GroupPostComment, erro
 		}
 		return nil, err
 	}
+	comment.Edited = comment.RevisionCount > 0
 
 	// Get comment user
 	comment.User, err = GetUserById(db, comment.UserID)
@@ -99,6 +125,12 @@ func GetGroupPostCommentById(db *sql.DB, commentId int) (*GroupPostComment, erro
 		return nil, err
 	}
 
+	// Get attachments
+	comment.Media, err = GetMedia(db, MediaTargetGroupPostComment, comment.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	return comment, nil
 }
 
@@ -162,7 +194,7 @@ func GetGroupPostComments(db *sql.DB, groupPostId int, userId int, options map[s
 		query = `
 			SELECT c.id, c.group_post_id, c.user_id, c.parent_id, c.content, c.image_url, 
 			COALESCE(c.like_count, 0) as like_count, COALESCE(c.dislike_count, 0) as dislike_count, 
-			c.created_at, c.updated_at,
+			c.created_at, c.updated_at, COALESCE(c.revision_count, 0),
 			u.id as user_id, u.email, u.first_name, u.last_name, u.avatar, u.nickname
 			FROM group_post_comments c
 			JOIN users u ON c.user_id = u.id
@@ -176,7 +208,7 @@ func GetGroupPostComments(db *sql.DB, groupPostId int, userId int, options map[s
 		query = `
 			SELECT c.id, c.group_post_id, c.user_id, c.parent_id, c.content, c.image_url, 
 			COALESCE(c.like_count, 0) as like_count, COALESCE(c.dislike_count, 0) as dislike_count, 
-			c.created_at, c.updated_at,
+			c.created_at, c.updated_at, COALESCE(c.revision_count, 0),
 			u.id as user_id, u.email, u.first_name, u.last_name, u.avatar, u.nickname
 			FROM group_post_comments c
 			JOIN users u ON c.user_id = u.id
@@ -189,7 +221,7 @@ func GetGroupPostComments(db *sql.DB, groupPostId int, userId int, options map[s
 		query = `
 			SELECT c.id, c.group_post_id, c.user_id, c.parent_id, c.content, c.image_url, 
 			COALESCE(c.like_count, 0) as like_count, COALESCE(c.dislike_count, 0) as dislike_count, 
-			c.created_at, c.updated_at,
+			c.created_at, c.updated_at, COALESCE(c.revision_count, 0),
 			u.id as user_id, u.email, u.first_name, u.last_name, u.avatar, u.nickname
 			FROM group_post_comments c
 			JOIN users u ON c.user_id = u.id
@@ -212,23 +244,30 @@ func GetGroupPostComments(db *sql.DB, groupPostId int, userId int, options map[s
 
 		err := rows.Scan(
 			&comment.ID, &comment.GroupPostID, &comment.UserID, &comment.ParentID, &comment.Content, &comment.ImageURL,
-			&comment.LikeCount, &comment.DislikeCount, &comment.CreatedAt, &comment.UpdatedAt,
+			&comment.LikeCount, &comment.DislikeCount, &comment.CreatedAt, &comment.UpdatedAt, &comment.RevisionCount,
 			&user.ID, &user.Email, &user.FirstName, &user.LastName, &user.Avatar, &user.Nickname,
 		)
 		if err != nil {
 			return nil, err
 		}
+		comment.Edited = comment.RevisionCount > 0
 
 		comment.User = &user
 		comments = append(comments, comment)
 	}
 
+	// Get attachments
+	if err := loadGroupPostCommentMedia(db, comments); err != nil {
+		return nil, err
+	}
+
 	return comments, nil
 }
 
-// UpdateGroupPostComment updates a group post comment
-func UpdateGroupPostComment(db *sql.DB, commentId int, userId int, content string, imageUrl string) error {
-	// Check if the comment exists and belongs to the user
+// UpdateGroupPostComment updates a group post comment. Its attachments are replaced with media,
+// or with the single image at imageUrl if media is empty.
+func UpdateGroupPostComment(db *sql.DB, commentId int, actor *User, content string, imageUrl string, media []Media) error {
+	// Check if the comment exists and the user may edit it
 	var existingUserId int
 	err := db.QueryRow(
 		"SELECT user_id FROM group_post_comments WHERE id = ?",
@@ -241,27 +280,58 @@ func UpdateGroupPostComment(db *sql.DB, commentId int, userId int, content strin
 		return err
 	}
 
-	if existingUserId != userId {
-		return errors.New("unauthorized to update this comment")
+	if !CanEditContent(actor, existingUserId) {
+		return ErrForbidden
+	}
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Keep the current version in the comment's history
+	if err := recordRevision(tx, RevisionTargetGroupPostComment, commentId, content, imageUrl); err != nil {
+		return err
 	}
 
 	// Update the comment
-	_, err = db.Exec(
+	_, err = tx.Exec(
 		`UPDATE group_post_comments SET content = ?, image_url = ?, updated_at = CURRENT_TIMESTAMP 
 		WHERE id = ?`,
 		content, imageUrl, commentId,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+
+	// Re-index hashtags and mentions
+	if err := indexContentTags(tx, TagTargetGroupPostComment, commentId, existingUserId, content); err != nil {
+		return err
+	}
+
+	// Replace attachments
+	if err := attachMedia(tx, MediaTargetGroupPostComment, commentId, existingUserId, media, imageUrl); err != nil {
+		return err
+	}
+
+	// Commit transaction
+	return tx.Commit()
 }
 
 // DeleteGroupPostComment deletes a group post comment
-func DeleteGroupPostComment(db *sql.DB, commentId int, userId int) error {
-	// Check if the comment exists and belongs to the user
+func DeleteGroupPostComment(db *sql.DB, commentId int, actor *User) error {
+	// Check if the comment exists and the user may delete it
 	var existingUserId int
+	var groupCreatorId int
 	err := db.QueryRow(
-		"SELECT user_id FROM group_post_comments WHERE id = ?",
+		`SELECT c.user_id, g.creator_id FROM group_post_comments c
+		JOIN group_posts gp ON c.group_post_id = gp.id
+		JOIN groups g ON gp.group_id = g.id
+		WHERE c.id = ?`,
 		commentId,
-	).Scan(&existingUserId)
+	).Scan(&existingUserId, &groupCreatorId)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return errors.New("comment not found")
@@ -269,8 +339,9 @@ func DeleteGroupPostComment(db *sql.DB, commentId int, userId int) error {
 		return err
 	}
 
-	if existingUserId != userId {
-		return errors.New("unauthorized to delete this comment")
+	// The comment author, the group creator and moderators can delete the comment
+	if !CanDeleteContent(actor, existingUserId, groupCreatorId) {
+		return ErrForbidden
 	}
 
 	// Delete the comment (this will cascade to replies and reactions)
@@ -0,0 +1,305 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrIdentityNotFound is returned when an identity does not exist or belongs to another user
+	ErrIdentityNotFound = errors.New("identity not found")
+	// ErrIdentityInUse is returned when linking an identity that already signs in to another account
+	ErrIdentityInUse = errors.New("this account is already linked to another user")
+	// ErrInvalidOIDCState is returned when a login callback's state is unknown, expired or already used
+	ErrInvalidOIDCState = errors.New("invalid or expired login state")
+	// ErrLastSignInMethod is returned when unlinking would leave an account without a way to sign in
+	ErrLastSignInMethod = errors.New("set a password before unlinking your only sign-in method")
+)
+
+// OIDCStateTTL is how long a user has to finish signing in at the provider
+const OIDCStateTTL = 10 * time.Minute
+
+// UserIdentity links an account at an external OpenID provider to a user
+type UserIdentity struct {
+	ID          int        `json:"id"`
+	UserID      int        `json:"user_id"`
+	Issuer      string     `json:"issuer"`
+	Subject     string     `json:"subject"`
+	Email       string     `json:"email,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+}
+
+// OIDCState is what is remembered about a login between redirecting to the provider and its callback
+type OIDCState struct {
+	CodeVerifier string
+	Nonce        string
+	RedirectTo   string
+	LinkUserID   int // zero unless a signed-in user is linking an identity
+}
+
+// scanUserIdentity scans an identity row selected with userIdentityColumns
+func scanUserIdentity(row interface{ Scan(...interface{}) error }, identity *UserIdentity) error {
+	var email sql.NullString
+	var lastLoginAt sql.NullTime
+	err := row.Scan(
+		&identity.ID, &identity.UserID, &identity.Issuer, &identity.Subject, &email,
+		&identity.CreatedAt, &lastLoginAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	identity.Email = email.String
+	if lastLoginAt.Valid {
+		identity.LastLoginAt = &lastLoginAt.Time
+	}
+	return nil
+}
+
+const userIdentityColumns = `id, user_id, issuer, subject, email, created_at, last_login_at`
+
+// GetUserIdentity retrieves the identity for a provider account, or nil if it isn't linked
+func GetUserIdentity(db *sql.DB, issuer string, subject string) (*UserIdentity, error) {
+	identity := &UserIdentity{}
+	err := scanUserIdentity(db.QueryRow(
+		`SELECT `+userIdentityColumns+` FROM user_identities WHERE issuer = ? AND subject = ?`,
+		issuer, subject,
+	), identity)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return identity, nil
+}
+
+// GetUserIdentities retrieves the identities linked to a user
+func GetUserIdentities(db *sql.DB, userId int) ([]UserIdentity, error) {
+	identities := []UserIdentity{}
+
+	rows, err := db.Query(
+		`SELECT `+userIdentityColumns+` FROM user_identities WHERE user_id = ? ORDER BY created_at`,
+		userId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var identity UserIdentity
+		if err := scanUserIdentity(rows, &identity); err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+
+	return identities, nil
+}
+
+// CreateUserIdentity links a provider account to a user
+func CreateUserIdentity(db *sql.DB, userId int, issuer string, subject string, email string) error {
+	existing, err := GetUserIdentity(db, issuer, subject)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		if existing.UserID == userId {
+			return nil
+		}
+		return ErrIdentityInUse
+	}
+
+	_, err = db.Exec(
+		`INSERT INTO user_identities (user_id, issuer, subject, email, last_login_at) VALUES (?, ?, ?, ?, ?)`,
+		userId, issuer, subject, email, time.Now(),
+	)
+	return err
+}
+
+// TouchUserIdentity records a sign-in through an identity and refreshes the email the provider reported
+func TouchUserIdentity(db *sql.DB, identityId int, email string) error {
+	_, err := db.Exec(
+		"UPDATE user_identities SET email = ?, last_login_at = ? WHERE id = ?",
+		email, time.Now(), identityId,
+	)
+	return err
+}
+
+// DeleteUserIdentity unlinks one of a user's identities. An identity can't be removed
+// while it is the only way to sign in to an account that has no password.
+func DeleteUserIdentity(db *sql.DB, identityId int, userId int) error {
+	var count int
+	var hasPassword bool
+	err := db.QueryRow(
+		`SELECT (SELECT COUNT(*) FROM user_identities WHERE user_id = ?),
+			NOT EXISTS(SELECT 1 FROM users WHERE id = ? AND password = '')`,
+		userId, userId,
+	).Scan(&count, &hasPassword)
+	if err != nil {
+		return err
+	}
+
+	result, err := db.Exec("DELETE FROM user_identities WHERE id = ? AND user_id = ? AND (? OR ? > 1)",
+		identityId, userId, hasPassword, count)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		var exists bool
+		err := db.QueryRow(
+			"SELECT EXISTS(SELECT 1 FROM user_identities WHERE id = ? AND user_id = ?)",
+			identityId, userId,
+		).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrLastSignInMethod
+		}
+		return ErrIdentityNotFound
+	}
+
+	return nil
+}
+
+// ExternalAccount describes a person as an OpenID provider reports them
+type ExternalAccount struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	FirstName     string
+	LastName      string
+	Avatar        string
+}
+
+// CreateExternalUser creates an account for someone signing in through an OpenID provider,
+// linked to their identity there. The account has no password until the user sets one through
+// a password reset, and the email counts as verified when the provider vouches for it.
+func CreateExternalUser(db *sql.DB, account ExternalAccount) (int, error) {
+	// Providers don't always share a name, so fall back to the email's local part
+	firstName := account.FirstName
+	if firstName == "" {
+		firstName = strings.SplitN(account.Email, "@", 2)[0]
+	}
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE email = ?)", account.Email).Scan(&exists)
+	if err != nil {
+		return 0, err
+	}
+	if exists {
+		return 0, ErrEmailInUse
+	}
+
+	// An empty hash never matches, so password login stays closed until a password is set
+	result, err := tx.Exec(
+		`INSERT INTO users (email, password, first_name, last_name, date_of_birth, avatar, nickname, about_me)
+		VALUES (?, '', ?, ?, '', ?, '', '')`,
+		account.Email, firstName, account.LastName, account.Avatar,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	userId, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	// Create user profile (default to public)
+	_, err = tx.Exec(`INSERT INTO user_profiles (user_id, is_public) VALUES (?, ?)`, userId, true)
+	if err != nil {
+		return 0, err
+	}
+
+	if account.EmailVerified {
+		_, err = tx.Exec("UPDATE users SET email_verified_at = CURRENT_TIMESTAMP WHERE id = ?", userId)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO user_identities (user_id, issuer, subject, email, last_login_at) VALUES (?, ?, ?, ?, ?)`,
+		userId, account.Issuer, account.Subject, account.Email, time.Now(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return int(userId), nil
+}
+
+// CreateOIDCState stores a login attempt under the hash of its state parameter
+func CreateOIDCState(db *sql.DB, state string, oidcState OIDCState) error {
+	// Clear out abandoned logins while we're here
+	_, _ = db.Exec("DELETE FROM oidc_login_states WHERE expires_at <= ?", time.Now())
+
+	var linkUserId interface{}
+	if oidcState.LinkUserID != 0 {
+		linkUserId = oidcState.LinkUserID
+	}
+
+	_, err := db.Exec(
+		`INSERT INTO oidc_login_states (state_hash, code_verifier, nonce, redirect_to, link_user_id, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		hashToken(state), oidcState.CodeVerifier, oidcState.Nonce, oidcState.RedirectTo, linkUserId,
+		time.Now().Add(OIDCStateTTL),
+	)
+	return err
+}
+
+// ConsumeOIDCState looks up a login attempt by its state parameter and deletes it,
+// so each state can only complete one login
+func ConsumeOIDCState(db *sql.DB, state string) (*OIDCState, error) {
+	var oidcState OIDCState
+	var redirectTo sql.NullString
+	var linkUserId sql.NullInt64
+	err := db.QueryRow(
+		`SELECT code_verifier, nonce, redirect_to, link_user_id FROM oidc_login_states
+		WHERE state_hash = ? AND expires_at > ?`,
+		hashToken(state), time.Now(),
+	).Scan(&oidcState.CodeVerifier, &oidcState.Nonce, &redirectTo, &linkUserId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInvalidOIDCState
+		}
+		return nil, err
+	}
+
+	result, err := db.Exec("DELETE FROM oidc_login_states WHERE state_hash = ?", hashToken(state))
+	if err != nil {
+		return nil, err
+	}
+	// Lost a race with another request using the same state
+	if rowsAffected, err := result.RowsAffected(); err != nil || rowsAffected == 0 {
+		return nil, ErrInvalidOIDCState
+	}
+
+	oidcState.RedirectTo = redirectTo.String
+	oidcState.LinkUserID = int(linkUserId.Int64)
+	return &oidcState, nil
+}
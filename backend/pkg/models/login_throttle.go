@@ -0,0 +1,202 @@
+package models
+
+import (
+	"database/sql"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LoginThrottlePolicy controls how failed logins are slowed down and locked out
+type LoginThrottlePolicy struct {
+	// MaxFailures is how many failures an account tolerates within the window before it is locked
+	MaxFailures int
+	// MaxIPFailures is how many failures a single IP address tolerates within the window before it is locked
+	MaxIPFailures int
+	// FailureWindow is how long a failure counts towards the thresholds
+	FailureWindow time.Duration
+	// LockoutDuration is how long an account or IP address stays locked
+	LockoutDuration time.Duration
+	// BaseDelay is the wait enforced after an account's first failure; it doubles with each further failure
+	BaseDelay time.Duration
+	// MaxDelay caps the progressive delay
+	MaxDelay time.Duration
+}
+
+// LoginThrottle describes whether a login attempt may go ahead
+type LoginThrottle struct {
+	// Locked is true when the account or IP address has hit its failure threshold
+	Locked bool
+	// RetryAfter is how long the client must wait before trying again; zero means it may try now
+	RetryAfter time.Duration
+}
+
+var (
+	loginThrottlePolicy     LoginThrottlePolicy
+	loginThrottlePolicyOnce sync.Once
+)
+
+// GetLoginThrottlePolicy returns the login throttle policy, loading it from the environment on first use
+func GetLoginThrottlePolicy() LoginThrottlePolicy {
+	loginThrottlePolicyOnce.Do(func() {
+		loginThrottlePolicy = LoginThrottlePolicy{
+			MaxFailures:     intFromEnv("LOGIN_MAX_FAILURES", 5),
+			MaxIPFailures:   intFromEnv("LOGIN_MAX_IP_FAILURES", 50),
+			FailureWindow:   durationFromEnv("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+			LockoutDuration: durationFromEnv("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+			BaseDelay:       durationFromEnv("LOGIN_BASE_DELAY", time.Second),
+			MaxDelay:        durationFromEnv("LOGIN_MAX_DELAY", 30*time.Second),
+		}
+	})
+	return loginThrottlePolicy
+}
+
+// intFromEnv reads a positive integer from the environment
+func intFromEnv(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Printf("Invalid integer for %s: %q, using default %d", key, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+// loginThrottleKeys returns the counter keys for an email and IP address
+func loginThrottleKeys(email string, ipAddress string) (string, string) {
+	return "email:" + strings.ToLower(strings.TrimSpace(email)), "ip:" + ipAddress
+}
+
+// CheckLoginThrottle reports whether a login for an email from an IP address must wait.
+// Both the account and the IP address are checked and the longer wait wins.
+func CheckLoginThrottle(db *sql.DB, email string, ipAddress string) (LoginThrottle, error) {
+	policy := GetLoginThrottlePolicy()
+	emailKey, ipKey := loginThrottleKeys(email, ipAddress)
+	now := time.Now()
+
+	var result LoginThrottle
+	for _, key := range []string{emailKey, ipKey} {
+		var failures int
+		var lastFailureAt time.Time
+		var lockedUntil sql.NullTime
+		err := db.QueryRow(
+			"SELECT failures, last_failure_at, locked_until FROM login_throttles WHERE key = ?",
+			key,
+		).Scan(&failures, &lastFailureAt, &lockedUntil)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return LoginThrottle{}, err
+		}
+
+		// Locked out
+		if lockedUntil.Valid && lockedUntil.Time.After(now) {
+			result.Locked = true
+			if wait := lockedUntil.Time.Sub(now); wait > result.RetryAfter {
+				result.RetryAfter = wait
+			}
+			continue
+		}
+
+		// Progressive delay since the last failure. This only applies to the account:
+		// an IP address may be shared by many users, so it is only held to its lockout threshold.
+		if key == emailKey && failures > 0 && now.Sub(lastFailureAt) < policy.FailureWindow {
+			if wait := lastFailureAt.Add(loginDelay(policy, failures)).Sub(now); wait > result.RetryAfter {
+				result.RetryAfter = wait
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// loginDelay returns how long to wait after the given number of consecutive failures
+func loginDelay(policy LoginThrottlePolicy, failures int) time.Duration {
+	delay := policy.BaseDelay
+	for i := 1; i < failures && delay < policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > policy.MaxDelay {
+		return policy.MaxDelay
+	}
+	return delay
+}
+
+// RecordLoginFailure counts a failed login against both the account and the IP address,
+// locking either one that reaches its threshold
+func RecordLoginFailure(db *sql.DB, email string, ipAddress string) error {
+	policy := GetLoginThrottlePolicy()
+	emailKey, ipKey := loginThrottleKeys(email, ipAddress)
+	now := time.Now()
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	thresholds := map[string]int{emailKey: policy.MaxFailures, ipKey: policy.MaxIPFailures}
+	for key, threshold := range thresholds {
+		var failures int
+		var lastFailureAt time.Time
+		err := tx.QueryRow(
+			"SELECT failures, last_failure_at FROM login_throttles WHERE key = ?",
+			key,
+		).Scan(&failures, &lastFailureAt)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		// Failures outside the window no longer count
+		if err == sql.ErrNoRows || now.Sub(lastFailureAt) >= policy.FailureWindow {
+			failures = 0
+		}
+		failures++
+
+		// Lock once the threshold is reached and start counting afresh
+		var lockedUntil interface{}
+		if failures >= threshold {
+			lockedUntil = now.Add(policy.LockoutDuration)
+			failures = 0
+		}
+
+		_, err = tx.Exec(
+			`INSERT INTO login_throttles (key, failures, last_failure_at, locked_until) VALUES (?, ?, ?, ?)
+			ON CONFLICT(key) DO UPDATE SET failures = excluded.failures, last_failure_at = excluded.last_failure_at,
+			locked_until = COALESCE(excluded.locked_until, login_throttles.locked_until)`,
+			key, failures, now, lockedUntil,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Clear out counters that no longer matter
+	_, err = tx.Exec(
+		"DELETE FROM login_throttles WHERE last_failure_at < ? AND (locked_until IS NULL OR locked_until < ?)",
+		now.Add(-policy.FailureWindow), now,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Commit transaction
+	return tx.Commit()
+}
+
+// ClearLoginFailures resets the account's failure counter after a successful login.
+// The IP address counter is left alone so one valid account can't be used to reset it.
+func ClearLoginFailures(db *sql.DB, email string) error {
+	emailKey, _ := loginThrottleKeys(email, "")
+	_, err := db.Exec("DELETE FROM login_throttles WHERE key = ?", emailKey)
+	return err
+}
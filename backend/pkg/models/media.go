@@ -0,0 +1,315 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Kinds of content media can be attached to
+const (
+	MediaTargetPost             = "post"
+	MediaTargetComment          = "comment"
+	MediaTargetGroupPost        = "group_post"
+	MediaTargetGroupPostComment = "group_post_comment"
+	MediaTargetMessage          = "message"
+)
+
+// MaxMediaAttachments is how many uploads can be attached to one piece of content
+const MaxMediaAttachments = 4
+
+// MaxAltTextLength is the longest alt text an attachment can have, in characters
+const MaxAltTextLength = 1000
+
+var (
+	ErrTooManyAttachments = fmt.Errorf("at most %d attachments are allowed", MaxMediaAttachments)
+	ErrInvalidAttachment  = errors.New("attachment not found")
+	ErrAltTextTooLong     = fmt.Errorf("alt text must be at most %d characters", MaxAltTextLength)
+)
+
+// Media is an uploaded image attached to a post, group post, comment, group post comment or
+// message. When attaching uploads, only the ID and alt text are read.
+type Media struct {
+	ID       int    `json:"id"`
+	URL      string `json:"url"`
+	MimeType string `json:"mime_type"`
+	Width    int    `json:"width,omitempty"`
+	Height   int    `json:"height,omitempty"`
+	AltText  string `json:"alt_text"`
+	Position int    `json:"position"`
+}
+
+// mediaImageTables are the tables that keep their first attachment's URL in image_url,
+// for clients that only show a single image
+var mediaImageTables = map[string]string{
+	MediaTargetPost:             "posts",
+	MediaTargetComment:          "comments",
+	MediaTargetGroupPost:        "group_posts",
+	MediaTargetGroupPostComment: "group_post_comments",
+}
+
+// RecordUpload records a stored upload so its uploader can attach it to content.
+// Width and height are 0 when unknown.
+func RecordUpload(db *sql.DB, userId int, url string, mimeType string, width int, height int) (*Media, error) {
+	result, err := db.Exec(
+		"INSERT INTO media (user_id, url, mime_type, width, height) VALUES (?, ?, ?, ?, ?)",
+		userId, url, mimeType, nullableDimension(width), nullableDimension(height),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaId, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Media{ID: int(mediaId), URL: url, MimeType: mimeType, Width: width, Height: height}, nil
+}
+
+// attachMedia replaces the attachments of a piece of content, in the given order. Only the
+// uploader's own uploads that aren't attached to something else can be attached. It must run in
+// the transaction that creates or edits the content.
+// Clients that send a single image_url instead of attachments get it as the only attachment.
+func attachMedia(tx *sql.Tx, kind string, id int, userId int, media []Media, imageURL string) error {
+	if len(media) == 0 && imageURL != "" {
+		legacy, err := legacyAttachment(tx, kind, id, userId, imageURL)
+		if err != nil {
+			return err
+		}
+		media = []Media{legacy}
+	}
+	if len(media) > MaxMediaAttachments {
+		return ErrTooManyAttachments
+	}
+
+	// Drop attachments that were removed
+	query := "DELETE FROM media WHERE target_type = ? AND target_id = ?"
+	args := []interface{}{kind, id}
+	if len(media) > 0 {
+		query += " AND id NOT IN (?" + strings.Repeat(", ?", len(media)-1) + ")"
+		for _, m := range media {
+			args = append(args, m.ID)
+		}
+	}
+	if _, err := tx.Exec(query, args...); err != nil {
+		return err
+	}
+
+	seen := map[int]bool{}
+	for position, m := range media {
+		if seen[m.ID] {
+			return ErrInvalidAttachment
+		}
+		seen[m.ID] = true
+		if len([]rune(m.AltText)) > MaxAltTextLength {
+			return ErrAltTextTooLong
+		}
+
+		result, err := tx.Exec(
+			`UPDATE media SET target_type = ?, target_id = ?, position = ?, alt_text = ?
+			WHERE id = ? AND user_id = ? AND (target_type IS NULL OR (target_type = ? AND target_id = ?))`,
+			kind, id, position, strings.TrimSpace(m.AltText), m.ID, userId, kind, id,
+		)
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return ErrInvalidAttachment
+		}
+	}
+
+	// Keep image_url on the first attachment
+	if table, ok := mediaImageTables[kind]; ok {
+		_, err := tx.Exec(
+			`UPDATE `+table+` SET image_url = COALESCE((
+				SELECT url FROM media WHERE target_type = ? AND target_id = ? ORDER BY position LIMIT 1
+			), '') WHERE id = ?`,
+			kind, id, id,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// legacyAttachment finds the upload behind an image_url sent by a client that doesn't use
+// attachments, preferring one already attached to the content, or records it if there is none
+func legacyAttachment(tx *sql.Tx, kind string, id int, userId int, imageURL string) (Media, error) {
+	var media Media
+	err := tx.QueryRow(
+		`SELECT id, alt_text FROM media
+		WHERE user_id = ? AND url = ? AND (target_type IS NULL OR (target_type = ? AND target_id = ?))
+		ORDER BY target_type IS NULL, id LIMIT 1`,
+		userId, imageURL, kind, id,
+	).Scan(&media.ID, &media.AltText)
+	if err == nil {
+		return media, nil
+	}
+	if err != sql.ErrNoRows {
+		return media, err
+	}
+
+	result, err := tx.Exec(
+		"INSERT INTO media (user_id, url, mime_type) VALUES (?, ?, ?)",
+		userId, imageURL, mimeTypeFromURL(imageURL),
+	)
+	if err != nil {
+		return media, err
+	}
+	mediaId, err := result.LastInsertId()
+	if err != nil {
+		return media, err
+	}
+	media.ID = int(mediaId)
+	return media, nil
+}
+
+// GetMedia retrieves the attachments of a piece of content, in order
+func GetMedia(db *sql.DB, kind string, id int) ([]Media, error) {
+	media, err := getMediaByTarget(db, kind, []int{id})
+	if err != nil {
+		return nil, err
+	}
+	if media[id] == nil {
+		return []Media{}, nil
+	}
+	return media[id], nil
+}
+
+// getMediaByTarget retrieves the attachments of several pieces of content of one kind,
+// keyed by content ID
+func getMediaByTarget(db *sql.DB, kind string, ids []int) (map[int][]Media, error) {
+	media := map[int][]Media{}
+	if len(ids) == 0 {
+		return media, nil
+	}
+
+	args := []interface{}{kind}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	rows, err := db.Query(
+		`SELECT id, target_id, url, mime_type, COALESCE(width, 0), COALESCE(height, 0), alt_text, position
+		FROM media
+		WHERE target_type = ? AND target_id IN (?`+strings.Repeat(", ?", len(ids)-1)+`)
+		ORDER BY target_id, position`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m Media
+		var targetId int
+		if err := rows.Scan(&m.ID, &targetId, &m.URL, &m.MimeType, &m.Width, &m.Height, &m.AltText, &m.Position); err != nil {
+			return nil, err
+		}
+		media[targetId] = append(media[targetId], m)
+	}
+
+	return media, rows.Err()
+}
+
+// loadPostMedia fills in the attachments of posts or group posts
+func loadPostMedia(db *sql.DB, kind string, posts []Post) error {
+	ids := make([]int, len(posts))
+	for i := range posts {
+		ids[i] = posts[i].ID
+	}
+	media, err := getMediaByTarget(db, kind, ids)
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		posts[i].Media = mediaOrEmpty(media[posts[i].ID])
+	}
+	return nil
+}
+
+// loadCommentMedia fills in the attachments of comments
+func loadCommentMedia(db *sql.DB, comments []Comment) error {
+	ids := make([]int, len(comments))
+	for i := range comments {
+		ids[i] = comments[i].ID
+	}
+	media, err := getMediaByTarget(db, MediaTargetComment, ids)
+	if err != nil {
+		return err
+	}
+	for i := range comments {
+		comments[i].Media = mediaOrEmpty(media[comments[i].ID])
+	}
+	return nil
+}
+
+// loadGroupPostCommentMedia fills in the attachments of group post comments
+func loadGroupPostCommentMedia(db *sql.DB, comments []GroupPostComment) error {
+	ids := make([]int, len(comments))
+	for i := range comments {
+		ids[i] = comments[i].ID
+	}
+	media, err := getMediaByTarget(db, MediaTargetGroupPostComment, ids)
+	if err != nil {
+		return err
+	}
+	for i := range comments {
+		comments[i].Media = mediaOrEmpty(media[comments[i].ID])
+	}
+	return nil
+}
+
+// loadMessageMedia fills in the attachments of messages
+func loadMessageMedia(db *sql.DB, messages []Message) error {
+	ids := make([]int, len(messages))
+	for i := range messages {
+		ids[i] = messages[i].ID
+	}
+	media, err := getMediaByTarget(db, MediaTargetMessage, ids)
+	if err != nil {
+		return err
+	}
+	for i := range messages {
+		messages[i].Media = mediaOrEmpty(media[messages[i].ID])
+	}
+	return nil
+}
+
+// mediaOrEmpty returns an empty list for content without attachments, so it is sent as []
+func mediaOrEmpty(media []Media) []Media {
+	if media == nil {
+		return []Media{}
+	}
+	return media
+}
+
+// mimeTypeFromURL guesses an image's MIME type from its file extension
+func mimeTypeFromURL(url string) string {
+	switch strings.ToLower(path.Ext(url)) {
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".png":
+		return "image/png"
+	case ".gif":
+		return "image/gif"
+	}
+	return ""
+}
+
+// nullableDimension stores unknown image dimensions as NULL
+func nullableDimension(pixels int) interface{} {
+	if pixels <= 0 {
+		return nil
+	}
+	return pixels
+}
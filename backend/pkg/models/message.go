@@ -12,19 +12,28 @@ type Message struct {
 	ReceiverID *int      `json:"receiver_id,omitempty"`
 	GroupID    *int      `json:"group_id,omitempty"`
 	Content    string    `json:"content"`
+	Media      []Media   `json:"media"`
 	IsRead     bool      `json:"is_read"`
 	CreatedAt  time.Time `json:"created_at"`
+	Hidden     bool      `json:"hidden,omitempty"`
 	Sender     *User     `json:"sender,omitempty"`
 	Receiver   *User     `json:"receiver,omitempty"`
 }
 
-// CreatePrivateMessage creates a new private message between users
-func CreatePrivateMessage(db *sql.DB, senderId int, receiverId int, content string) (*Message, error) {
+// CreatePrivateMessage creates a new private message between users with the given attachments
+func CreatePrivateMessage(db *sql.DB, senderId int, receiverId int, content string, media []Media) (*Message, error) {
 	// Allow sending messages to any user; message requests are handled at the conversation level
 	// (Old restriction removed to support message requests)
 
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
 	// Create message
-	result, err := db.Exec(
+	result, err := tx.Exec(
 		`INSERT INTO messages (sender_id, receiver_id, content) VALUES (?, ?, ?)`,
 		senderId, receiverId, content,
 	)
@@ -37,6 +46,16 @@ func CreatePrivateMessage(db *sql.DB, senderId int, receiverId int, content stri
 		return nil, err
 	}
 
+	// Attach uploads
+	if err := attachMedia(tx, MediaTargetMessage, int(messageId), senderId, media, ""); err != nil {
+		return nil, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
 	// Retrieve the created message
 	var message Message
 	err = db.QueryRow(`
@@ -52,11 +71,16 @@ func CreatePrivateMessage(db *sql.DB, senderId int, receiverId int, content stri
 		return nil, err
 	}
 
+	message.Media, err = GetMedia(db, MediaTargetMessage, message.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	return &message, nil
 }
 
-// CreateGroupMessage creates a new message in a group chat
-func CreateGroupMessage(db *sql.DB, senderId int, groupId int, content string) (int, error) {
+// CreateGroupMessage creates a new message in a group chat with the given attachments
+func CreateGroupMessage(db *sql.DB, senderId int, groupId int, content string, media []Media) (int, error) {
 	// Check if user is a member of the group
 	var status string
 	err := db.QueryRow(
@@ -73,8 +97,15 @@ func CreateGroupMessage(db *sql.DB, senderId int, groupId int, content string) (
 		return 0, errors.New("user is not an accepted member of the group")
 	}
 
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
 	// Create message
-	result, err := db.Exec(
+	result, err := tx.Exec(
 		`INSERT INTO messages (sender_id, group_id, content) VALUES (?, ?, ?)`,
 		senderId, groupId, content,
 	)
@@ -87,25 +118,37 @@ func CreateGroupMessage(db *sql.DB, senderId int, groupId int, content string) (
 		return 0, err
 	}
 
+	// Attach uploads
+	if err := attachMedia(tx, MediaTargetMessage, int(messageId), senderId, media, ""); err != nil {
+		return 0, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
 	return int(messageId), nil
 }
 
-// GetPrivateMessages retrieves private messages between two users
+// GetPrivateMessages retrieves private messages between two users, as seen by the first.
+// Messages hidden by a moderator are only included for their sender.
 func GetPrivateMessages(db *sql.DB, userId1 int, userId2 int, page int, limit int) ([]Message, error) {
 	offset := (page - 1) * limit
 	messages := []Message{}
 
 	rows, err := db.Query(`
-		SELECT m.id, m.sender_id, m.receiver_id, m.content, m.is_read, m.created_at,
+		SELECT m.id, m.sender_id, m.receiver_id, m.content, m.is_read, m.created_at, m.hidden_at IS NOT NULL,
 		s.id, s.email, s.first_name, s.last_name, s.avatar, s.nickname,
 		r.id, r.email, r.first_name, r.last_name, r.avatar, r.nickname
 		FROM messages m
 		JOIN users s ON m.sender_id = s.id
 		JOIN users r ON m.receiver_id = r.id
-		WHERE (m.sender_id = ? AND m.receiver_id = ?) OR (m.sender_id = ? AND m.receiver_id = ?)
+		WHERE ((m.sender_id = ? AND m.receiver_id = ?) OR (m.sender_id = ? AND m.receiver_id = ?))
+		AND (m.hidden_at IS NULL OR m.sender_id = ?)
 		ORDER BY m.created_at ASC
 		LIMIT ? OFFSET ?
-	`, userId1, userId2, userId2, userId1, limit, offset)
+	`, userId1, userId2, userId2, userId1, userId1, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -117,7 +160,7 @@ func GetPrivateMessages(db *sql.DB, userId1 int, userId2 int, page int, limit in
 		var receiver User
 
 		err := rows.Scan(
-			&message.ID, &message.SenderID, &message.ReceiverID, &message.Content, &message.IsRead, &message.CreatedAt,
+			&message.ID, &message.SenderID, &message.ReceiverID, &message.Content, &message.IsRead, &message.CreatedAt, &message.Hidden,
 			&sender.ID, &sender.Email, &sender.FirstName, &sender.LastName, &sender.Avatar, &sender.Nickname,
 			&receiver.ID, &receiver.Email, &receiver.FirstName, &receiver.LastName, &receiver.Avatar, &receiver.Nickname,
 		)
@@ -130,6 +173,11 @@ func GetPrivateMessages(db *sql.DB, userId1 int, userId2 int, page int, limit in
 		messages = append(messages, message)
 	}
 
+	// Get attachments
+	if err := loadMessageMedia(db, messages); err != nil {
+		return nil, err
+	}
+
 	// Mark messages as read
 	_, err = db.Exec(`
 		UPDATE messages 
@@ -143,7 +191,8 @@ func GetPrivateMessages(db *sql.DB, userId1 int, userId2 int, page int, limit in
 	return messages, nil
 }
 
-// GetGroupMessages retrieves messages in a group chat
+// GetGroupMessages retrieves messages in a group chat.
+// Messages hidden by a moderator are only included for their sender.
 func GetGroupMessages(db *sql.DB, groupId int, userId int, page int, limit int) ([]Message, error) {
 	// Check if user is a member of the group
 	var status string
@@ -165,14 +214,14 @@ func GetGroupMessages(db *sql.DB, groupId int, userId int, page int, limit int)
 	messages := []Message{}
 
 	rows, err := db.Query(`
-		SELECT m.id, m.sender_id, m.group_id, m.content, m.is_read, m.created_at,
+		SELECT m.id, m.sender_id, m.group_id, m.content, m.is_read, m.created_at, m.hidden_at IS NOT NULL,
 		s.id, s.email, s.first_name, s.last_name, s.avatar, s.nickname
 		FROM messages m
 		JOIN users s ON m.sender_id = s.id
-		WHERE m.group_id = ?
+		WHERE m.group_id = ? AND (m.hidden_at IS NULL OR m.sender_id = ?)
 		ORDER BY m.created_at ASC
 		LIMIT ? OFFSET ?
-	`, groupId, limit, offset)
+	`, groupId, userId, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -183,7 +232,7 @@ func GetGroupMessages(db *sql.DB, groupId int, userId int, page int, limit int)
 		var sender User
 
 		err := rows.Scan(
-			&message.ID, &message.SenderID, &message.GroupID, &message.Content, &message.IsRead, &message.CreatedAt,
+			&message.ID, &message.SenderID, &message.GroupID, &message.Content, &message.IsRead, &message.CreatedAt, &message.Hidden,
 			&sender.ID, &sender.Email, &sender.FirstName, &sender.LastName, &sender.Avatar, &sender.Nickname,
 		)
 		if err != nil {
@@ -194,6 +243,11 @@ func GetGroupMessages(db *sql.DB, groupId int, userId int, page int, limit int)
 		messages = append(messages, message)
 	}
 
+	// Get attachments
+	if err := loadMessageMedia(db, messages); err != nil {
+		return nil, err
+	}
+
 	return messages, nil
 }
 
@@ -222,7 +276,8 @@ func GetUserConversations(db *sql.DB, userId int) ([]ConversationInfo, error) {
 			(SELECT COUNT(*) FROM messages
 			 WHERE receiver_id = ? AND sender_id = u.id AND is_read = 0) as unread_count,
 			(SELECT content FROM messages
-			 WHERE (sender_id = ? AND receiver_id = u.id) OR (sender_id = u.id AND receiver_id = ?)
+			 WHERE ((sender_id = ? AND receiver_id = u.id) OR (sender_id = u.id AND receiver_id = ?))
+			 AND (hidden_at IS NULL OR sender_id = ?)
 			 ORDER BY created_at DESC LIMIT 1) as last_message,
 			(SELECT created_at FROM messages
 			 WHERE (sender_id = ? AND receiver_id = u.id) OR (sender_id = u.id AND receiver_id = ?)
@@ -231,7 +286,7 @@ func GetUserConversations(db *sql.DB, userId int) ([]ConversationInfo, error) {
 		JOIN users u ON (m.sender_id = u.id OR m.receiver_id = u.id) AND u.id != ?
 		WHERE (m.sender_id = ? OR m.receiver_id = ?) AND m.group_id IS NULL
 		ORDER BY last_message_time DESC
-	`, userId, userId, userId, userId, userId, userId, userId, userId, userId)
+	`, userId, userId, userId, userId, userId, userId, userId, userId, userId, userId)
 	if err != nil {
 		return nil, err
 	}
@@ -351,5 +406,12 @@ func GetMessageByID(db *sql.DB, messageID int) (*Message, error) {
 	}
 
 	message.Sender = &sender
+
+	// Get attachments
+	message.Media, err = GetMedia(db, MediaTargetMessage, message.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	return message, nil
 }
@@ -0,0 +1,367 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Moderation actions recorded in the moderation log
+const (
+	ModActionSuspendUser     = "suspend_user"
+	ModActionBanUser         = "ban_user"
+	ModActionReinstateUser   = "reinstate_user"
+	ModActionDeletePost      = "delete_post"
+	ModActionDeleteComment   = "delete_comment"
+	ModActionDeleteGroupPost = "delete_group_post"
+	ModActionDeleteGroup     = "delete_group"
+	ModActionDeleteMessage   = "delete_message"
+	ModActionHideContent     = "hide_content"
+)
+
+// Moderation target types
+const (
+	ModTargetUser      = "user"
+	ModTargetPost      = "post"
+	ModTargetComment   = "comment"
+	ModTargetGroupPost = "group_post"
+	ModTargetGroup     = "group"
+	ModTargetMessage   = "message"
+)
+
+var (
+	// ErrModerationTargetNotFound is returned when the user or content to act on doesn't exist
+	ErrModerationTargetNotFound = errors.New("moderation target not found")
+	// ErrCannotModerateUser is returned when acting on a user whose role puts them beyond moderation
+	ErrCannotModerateUser = errors.New("this user cannot be suspended or banned")
+)
+
+// ModerationLogEntry is one action taken through the moderation console
+type ModerationLogEntry struct {
+	ID           int             `json:"id"`
+	ModeratorID  *int            `json:"moderator_id"`
+	Action       string          `json:"action"`
+	TargetType   string          `json:"target_type"`
+	TargetID     int             `json:"target_id"`
+	TargetUserID *int            `json:"target_user_id,omitempty"`
+	Reason       string          `json:"reason,omitempty"`
+	Metadata     json.RawMessage `json:"metadata,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+	Moderator    *User           `json:"moderator,omitempty"`
+}
+
+// IsSuspended reports whether the user is currently banned or suspended
+func (u *User) IsSuspended() bool {
+	return u.BannedAt != nil || (u.SuspendedUntil != nil && u.SuspendedUntil.After(time.Now()))
+}
+
+// SuspendUser keeps a user from signing in until the given time and signs them out everywhere
+func SuspendUser(db *sql.DB, moderatorId int, userId int, until time.Time, reason string) error {
+	return setUserStanding(db, moderatorId, userId, ModActionSuspendUser, reason, until, nil)
+}
+
+// BanUser keeps a user from signing in indefinitely and signs them out everywhere
+func BanUser(db *sql.DB, moderatorId int, userId int, reason string) error {
+	return setUserStanding(db, moderatorId, userId, ModActionBanUser, reason, nil, time.Now())
+}
+
+// ReinstateUser lifts a user's suspension or ban
+func ReinstateUser(db *sql.DB, moderatorId int, userId int, reason string) error {
+	return setUserStanding(db, moderatorId, userId, ModActionReinstateUser, reason, nil, nil)
+}
+
+// setUserStanding updates a user's suspension and ban and logs the action, atomically
+func setUserStanding(db *sql.DB, moderatorId int, userId int, action string, reason string, suspendedUntil interface{}, bannedAt interface{}) error {
+	target, err := GetUserById(db, userId)
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		return ErrModerationTargetNotFound
+	}
+	// Staff can't be locked out through the console; their role has to be changed first
+	if target.Role != RoleUser || target.ID == moderatorId {
+		return ErrCannotModerateUser
+	}
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var moderationReason interface{}
+	if action != ModActionReinstateUser {
+		moderationReason = reason
+	}
+	_, err = tx.Exec(
+		`UPDATE users SET suspended_until = ?, banned_at = ?, moderation_reason = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		suspendedUntil, bannedAt, moderationReason, userId,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Sign out everywhere
+	if action != ModActionReinstateUser {
+		if _, err := tx.Exec("DELETE FROM sessions WHERE user_id = ?", userId); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM pending_logins WHERE user_id = ?", userId); err != nil {
+			return err
+		}
+	}
+
+	metadata := map[string]interface{}{}
+	if until, ok := suspendedUntil.(time.Time); ok {
+		metadata["suspended_until"] = until
+	}
+	err = logModerationAction(tx, moderatorId, action, ModTargetUser, userId, userId, reason, metadata)
+	if err != nil {
+		return err
+	}
+
+	// Commit transaction
+	return tx.Commit()
+}
+
+// moderatedContent describes each kind of content moderators can act on:
+// where it lives, how it is removed and how to snapshot it for the log
+var moderatedContent = map[string]struct {
+	table         string
+	deleteAction  string
+	snapshotQuery string
+	hideable      bool
+}{
+	ModTargetPost: {
+		"posts", ModActionDeletePost,
+		"SELECT user_id, content, image_url, privacy, created_at FROM posts WHERE id = ?", true,
+	},
+	ModTargetComment: {
+		"comments", ModActionDeleteComment,
+		"SELECT user_id, content, image_url, post_id, created_at FROM comments WHERE id = ?", true,
+	},
+	ModTargetGroupPost: {
+		"group_posts", ModActionDeleteGroupPost,
+		"SELECT user_id, content, image_url, group_id, created_at FROM group_posts WHERE id = ?", true,
+	},
+	ModTargetMessage: {
+		"messages", ModActionDeleteMessage,
+		"SELECT sender_id AS user_id, receiver_id, group_id, content, created_at FROM messages WHERE id = ?", true,
+	},
+	ModTargetGroup: {
+		"groups", ModActionDeleteGroup,
+		"SELECT creator_id, title, description, category, created_at FROM groups WHERE id = ?", false,
+	},
+}
+
+// ForceDeleteContent deletes any post, comment, group post, message or group regardless of who owns it
+// and logs the action with a snapshot of what was removed. It returns the ID of the content's author.
+func ForceDeleteContent(db *sql.DB, moderatorId int, targetType string, targetId int, reason string) (int, error) {
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	authorId, err := forceDeleteContent(tx, moderatorId, targetType, targetId, reason)
+	if err != nil {
+		return 0, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return authorId, nil
+}
+
+// forceDeleteContent deletes content and logs it as part of a larger transaction
+func forceDeleteContent(tx *sql.Tx, moderatorId int, targetType string, targetId int, reason string) (int, error) {
+	content, ok := moderatedContent[targetType]
+	if !ok {
+		return 0, ErrModerationTargetNotFound
+	}
+
+	// Keep a copy of what is removed so the decision can be reviewed later
+	snapshot, authorId, err := contentSnapshot(tx, targetType, targetId)
+	if err != nil {
+		return 0, err
+	}
+
+	// Delete the content (cascade will handle related records)
+	if _, err := tx.Exec("DELETE FROM "+content.table+" WHERE id = ?", targetId); err != nil {
+		return 0, err
+	}
+
+	err = logModerationAction(tx, moderatorId, content.deleteAction, targetType, targetId, authorId, reason, snapshot)
+	if err != nil {
+		return 0, err
+	}
+
+	return authorId, nil
+}
+
+// hideContent hides content from everyone but its author and logs it as part of a larger transaction
+func hideContent(tx *sql.Tx, moderatorId int, targetType string, targetId int, reason string) (int, error) {
+	content, ok := moderatedContent[targetType]
+	if !ok || !content.hideable {
+		return 0, ErrModerationTargetNotFound
+	}
+
+	snapshot, authorId, err := contentSnapshot(tx, targetType, targetId)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec("UPDATE "+content.table+" SET hidden_at = CURRENT_TIMESTAMP WHERE id = ?", targetId)
+	if err != nil {
+		return 0, err
+	}
+
+	err = logModerationAction(tx, moderatorId, ModActionHideContent, targetType, targetId, authorId, reason, snapshot)
+	if err != nil {
+		return 0, err
+	}
+
+	return authorId, nil
+}
+
+// contentSnapshot reads a piece of moderated content as a column map, along with its author's ID
+func contentSnapshot(q interface {
+	Query(string, ...interface{}) (*sql.Rows, error)
+}, targetType string, targetId int) (map[string]interface{}, int, error) {
+	content, ok := moderatedContent[targetType]
+	if !ok {
+		return nil, 0, ErrModerationTargetNotFound
+	}
+
+	rows, err := q.Query(content.snapshotQuery, targetId)
+	if err != nil {
+		return nil, 0, err
+	}
+	snapshot, err := scanRowMaps(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(snapshot) == 0 {
+		return nil, 0, ErrModerationTargetNotFound
+	}
+
+	var authorId int
+	for _, column := range []string{"user_id", "creator_id"} {
+		if id, ok := snapshot[0][column].(int64); ok {
+			authorId = int(id)
+		}
+	}
+
+	return snapshot[0], authorId, nil
+}
+
+// logModerationAction writes a moderation log entry as part of the action's transaction
+func logModerationAction(tx *sql.Tx, moderatorId int, action string, targetType string, targetId int, targetUserId int, reason string, metadata map[string]interface{}) error {
+	var metadataJSON interface{}
+	if len(metadata) > 0 {
+		b, err := json.Marshal(metadata)
+		if err != nil {
+			return err
+		}
+		metadataJSON = string(b)
+	}
+
+	var targetUser interface{}
+	if targetUserId != 0 {
+		targetUser = targetUserId
+	}
+
+	_, err := tx.Exec(
+		`INSERT INTO moderation_log (moderator_id, action, target_type, target_id, target_user_id, reason, metadata)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		moderatorId, action, targetType, targetId, targetUser, reason, metadataJSON,
+	)
+	return err
+}
+
+// GetModerationLog retrieves moderation log entries, newest first.
+// Supported filters are "moderator_id", "target_user_id" (both int) and "action" (string).
+func GetModerationLog(db *sql.DB, filters map[string]interface{}, page int, limit int) ([]ModerationLogEntry, error) {
+	offset := (page - 1) * limit
+	entries := []ModerationLogEntry{}
+
+	// Build WHERE clause based on filters
+	whereClause := "WHERE 1 = 1"
+	args := []interface{}{}
+	if moderatorId, ok := filters["moderator_id"].(int); ok {
+		whereClause += " AND moderator_id = ?"
+		args = append(args, moderatorId)
+	}
+	if targetUserId, ok := filters["target_user_id"].(int); ok {
+		whereClause += " AND target_user_id = ?"
+		args = append(args, targetUserId)
+	}
+	if action, ok := filters["action"].(string); ok && action != "" {
+		whereClause += " AND action = ?"
+		args = append(args, action)
+	}
+	args = append(args, limit, offset)
+
+	rows, err := db.Query(
+		`SELECT id, moderator_id, action, target_type, target_id, target_user_id, reason, metadata, created_at
+		FROM moderation_log `+whereClause+`
+		ORDER BY created_at DESC, id DESC
+		LIMIT ? OFFSET ?`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry ModerationLogEntry
+		var moderatorId, targetUserId sql.NullInt64
+		var reason, metadata sql.NullString
+		err := rows.Scan(
+			&entry.ID, &moderatorId, &entry.Action, &entry.TargetType, &entry.TargetID, &targetUserId,
+			&reason, &metadata, &entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if moderatorId.Valid {
+			id := int(moderatorId.Int64)
+			entry.ModeratorID = &id
+		}
+		if targetUserId.Valid {
+			id := int(targetUserId.Int64)
+			entry.TargetUserID = &id
+		}
+		entry.Reason = reason.String
+		if metadata.Valid {
+			entry.Metadata = json.RawMessage(metadata.String)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Get moderators
+	for i := range entries {
+		if entries[i].ModeratorID == nil {
+			continue
+		}
+		entries[i].Moderator, err = GetUserById(db, *entries[i].ModeratorID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return entries, nil
+}
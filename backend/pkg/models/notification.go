@@ -33,20 +33,30 @@ func CreateNotification(db *sql.DB, userId int, notificationType string, message
 	return int(notificationId), nil
 }
 
-// GetUserNotifications retrieves notifications for a user
-func GetUserNotifications(db *sql.DB, userId int, page int, limit int) ([]Notification, error) {
-	offset := (page - 1) * limit
+// GetUserNotifications retrieves notifications for a user, newest first. The page starts after
+// the cursor if one is given, or at the page number otherwise. It also returns the cursor of the
+// page's last notification if there are more after it.
+func GetUserNotifications(db *sql.DB, userId int, after *Cursor, page int, limit int) ([]Notification, *Cursor, error) {
+	offset := pageOffset(after, page, limit)
 	notifications := []Notification{}
 
-	rows, err := db.Query(`
+	query := `
 		SELECT id, user_id, type, message, related_id, is_read, created_at
 		FROM notifications
 		WHERE user_id = ?
-		ORDER BY created_at DESC
-		LIMIT ? OFFSET ?
-	`, userId, limit, offset)
+	`
+	args := []interface{}{userId}
+	if after != nil {
+		query += " AND (created_at, id) < (?, ?)"
+		args = append(args, after.timestamp(), after.ID)
+	}
+	// Fetch one extra notification to tell whether there is another page
+	query += " ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?"
+	args = append(args, limit+1, offset)
+
+	rows, err := db.Query(query, args...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer rows.Close()
 
@@ -57,12 +67,22 @@ func GetUserNotifications(db *sql.DB, userId int, page int, limit int) ([]Notifi
 			&notification.RelatedID, &notification.IsRead, &notification.CreatedAt,
 		)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		notifications = append(notifications, notification)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var next *Cursor
+	if len(notifications) > limit {
+		notifications = notifications[:limit]
+		last := notifications[limit-1]
+		next = &Cursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
 
-	return notifications, nil
+	return notifications, next, nil
 }
 
 // MarkNotificationAsRead marks a notification as read
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrInvalidResetToken is returned when a reset token is unknown, expired or already used
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// CreatePasswordResetToken creates a single-use reset token for a user and returns it.
+// Any earlier unused tokens for the user are invalidated.
+func CreatePasswordResetToken(db *sql.DB, userId int) (string, error) {
+	token, err := generateToken(32)
+	if err != nil {
+		return "", err
+	}
+	expiresAt := time.Now().Add(durationFromEnv("PASSWORD_RESET_TTL", time.Hour))
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	// Invalidate previous tokens
+	_, err = tx.Exec("DELETE FROM password_resets WHERE user_id = ? AND used_at IS NULL", userId)
+	if err != nil {
+		return "", err
+	}
+
+	// Insert new token
+	_, err = tx.Exec(
+		`INSERT INTO password_resets (user_id, token_hash, expires_at) VALUES (?, ?, ?)`,
+		userId, hashToken(token), expiresAt,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// ResetPassword consumes a reset token, sets the user's new password, signs out all sessions
+// and revokes the user's personal access tokens.
+// It returns the ID of the user whose password was reset.
+func ResetPassword(db *sql.DB, token string, newPassword string) (int, error) {
+	// Hash password
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return 0, err
+	}
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Find a valid token
+	var resetId, userId int
+	err = tx.QueryRow(
+		`SELECT id, user_id FROM password_resets
+		WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?`,
+		hashToken(token), time.Now(),
+	).Scan(&resetId, &userId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrInvalidResetToken
+		}
+		return 0, err
+	}
+
+	// Mark token as used
+	_, err = tx.Exec("UPDATE password_resets SET used_at = ? WHERE id = ?", time.Now(), resetId)
+	if err != nil {
+		return 0, err
+	}
+
+	// Update password
+	_, err = tx.Exec(
+		"UPDATE users SET password = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		string(hashedPassword), userId,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	// Sign out everywhere
+	_, err = tx.Exec("DELETE FROM sessions WHERE user_id = ?", userId)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec("DELETE FROM api_tokens WHERE user_id = ?", userId)
+	if err != nil {
+		return 0, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return userId, nil
+}
@@ -0,0 +1,40 @@
+package models
+
+// Authorization policy. Every check of who may change or remove something goes through
+// these functions, so ownership and role permissions are applied the same way everywhere.
+
+// CanEditContent reports whether a user may edit a post or comment.
+// Only authors edit their own words; moderators remove content rather than rewrite it.
+func CanEditContent(user *User, authorId int) bool {
+	return user != nil && user.ID == authorId
+}
+
+// CanDeleteContent reports whether a user may delete a post or comment: its author,
+// the owner of what it was posted on (such as the post a comment belongs to), or a moderator
+func CanDeleteContent(user *User, authorId int, ownerIds ...int) bool {
+	if user == nil {
+		return false
+	}
+	if user.ID == authorId || user.Can(PermModerateContent) {
+		return true
+	}
+	for _, ownerId := range ownerIds {
+		if user.ID == ownerId {
+			return true
+		}
+	}
+	return false
+}
+
+// CanViewHiddenContent reports whether a user may still see content a moderator has hidden.
+// Only its author does, so it stays in place for them; moderators review it through the
+// report queue, which carries a snapshot of the content.
+func CanViewHiddenContent(user *User, authorId int) bool {
+	return user != nil && user.ID == authorId
+}
+
+// CanManageGroup reports whether a user may edit a group, delete it and manage its members:
+// the group's creator or a user whose role allows managing groups
+func CanManageGroup(user *User, creatorId int) bool {
+	return user != nil && (user.ID == creatorId || user.Can(PermManageGroups))
+}
@@ -0,0 +1,422 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Kinds of posts a poll can be attached to
+const (
+	PollTargetPost      = "post"
+	PollTargetGroupPost = "group_post"
+)
+
+// Limits on the options of a poll
+const (
+	MinPollOptions      = 2
+	MaxPollOptions      = 10
+	MaxPollOptionLength = 100
+)
+
+var (
+	ErrTooFewPollOptions    = fmt.Errorf("a poll needs at least %d options", MinPollOptions)
+	ErrTooManyPollOptions   = fmt.Errorf("a poll can have at most %d options", MaxPollOptions)
+	ErrInvalidPollOption    = fmt.Errorf("poll options must be 1 to %d characters", MaxPollOptionLength)
+	ErrDuplicatePollOption  = errors.New("poll options must be different")
+	ErrInvalidPollCloseTime = errors.New("poll close time must be in the future")
+	ErrPollNotFound         = errors.New("poll not found")
+	ErrPollClosed           = errors.New("poll is closed")
+	ErrInvalidPollVote      = errors.New("invalid poll vote")
+)
+
+// Poll is a poll attached to a post or group post. When creating a poll, only the option texts,
+// whether it is multiple choice or anonymous, and its close time are read.
+type Poll struct {
+	ID             int          `json:"id"`
+	TargetType     string       `json:"target_type"`
+	TargetID       int          `json:"target_id"`
+	MultipleChoice bool         `json:"multiple_choice"`
+	Anonymous      bool         `json:"anonymous"`
+	ClosesAt       *time.Time   `json:"closes_at,omitempty"`
+	Closed         bool         `json:"closed"`
+	Options        []PollOption `json:"options"`
+	VoterCount     int          `json:"voter_count"`
+	// MyVotes are the IDs of the options the viewer voted for
+	MyVotes []int `json:"my_votes,omitempty"`
+}
+
+// PollOption is one of the answers of a poll
+type PollOption struct {
+	ID        int    `json:"id"`
+	Text      string `json:"text"`
+	Position  int    `json:"position"`
+	VoteCount int    `json:"vote_count"`
+	// Voters are only listed when the poll isn't anonymous
+	Voters []User `json:"voters,omitempty"`
+}
+
+// createPoll attaches a poll to a post or group post. It must run in the transaction that
+// creates the post.
+func createPoll(tx *sql.Tx, kind string, id int, poll *Poll) error {
+	if len(poll.Options) < MinPollOptions {
+		return ErrTooFewPollOptions
+	}
+	if len(poll.Options) > MaxPollOptions {
+		return ErrTooManyPollOptions
+	}
+
+	seen := map[string]bool{}
+	for i := range poll.Options {
+		text := strings.TrimSpace(poll.Options[i].Text)
+		if text == "" || len([]rune(text)) > MaxPollOptionLength {
+			return ErrInvalidPollOption
+		}
+		if seen[strings.ToLower(text)] {
+			return ErrDuplicatePollOption
+		}
+		seen[strings.ToLower(text)] = true
+		poll.Options[i].Text = text
+	}
+
+	// Close times are kept in UTC so they compare correctly in SQL
+	var closesAt interface{}
+	if poll.ClosesAt != nil {
+		if !poll.ClosesAt.After(time.Now()) {
+			return ErrInvalidPollCloseTime
+		}
+		closesAt = poll.ClosesAt.UTC()
+	}
+
+	result, err := tx.Exec(
+		`INSERT INTO polls (target_type, target_id, multiple_choice, anonymous, closes_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		kind, id, poll.MultipleChoice, poll.Anonymous, closesAt,
+	)
+	if err != nil {
+		return err
+	}
+	pollId, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	for position, option := range poll.Options {
+		_, err := tx.Exec(
+			"INSERT INTO poll_options (poll_id, text, position) VALUES (?, ?, ?)",
+			pollId, option.Text, position,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetPoll retrieves a poll with its results as a user sees them. Polls on posts the user can't
+// see are reported as not found.
+func GetPoll(db *sql.DB, pollId int, userId int) (*Poll, error) {
+	poll, err := getPoll(db, pollId)
+	if err != nil {
+		return nil, err
+	}
+	canView, err := CanViewPoll(db, poll, userId)
+	if err != nil {
+		return nil, err
+	}
+	if !canView {
+		return nil, ErrPollNotFound
+	}
+
+	if err := loadPollResults(db, []*Poll{poll}, userId); err != nil {
+		return nil, err
+	}
+	return poll, nil
+}
+
+// GetPollResults retrieves a poll with its results and no viewer's votes, for pushing to
+// everyone who can see it
+func GetPollResults(db *sql.DB, pollId int) (*Poll, error) {
+	poll, err := getPoll(db, pollId)
+	if err != nil {
+		return nil, err
+	}
+	if err := loadPollResults(db, []*Poll{poll}, 0); err != nil {
+		return nil, err
+	}
+	return poll, nil
+}
+
+// getPoll retrieves a poll without its options or results
+func getPoll(db *sql.DB, pollId int) (*Poll, error) {
+	poll := &Poll{}
+	var closesAt sql.NullTime
+	err := db.QueryRow(
+		"SELECT id, target_type, target_id, multiple_choice, anonymous, closes_at FROM polls WHERE id = ?",
+		pollId,
+	).Scan(&poll.ID, &poll.TargetType, &poll.TargetID, &poll.MultipleChoice, &poll.Anonymous, &closesAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrPollNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if closesAt.Valid {
+		poll.ClosesAt = &closesAt.Time
+		poll.Closed = !closesAt.Time.After(time.Now())
+	}
+	return poll, nil
+}
+
+// CanViewPoll checks if a user can see a poll: polls on posts follow the post's visibility and
+// polls on group posts are visible to the group's members
+func CanViewPoll(db *sql.DB, poll *Poll, userId int) (bool, error) {
+	switch poll.TargetType {
+	case PollTargetPost:
+		post, err := getPostForVisibility(db, poll.TargetID)
+		if err != nil || post == nil {
+			return false, err
+		}
+		return CanViewPost(db, post, userId)
+
+	case PollTargetGroupPost:
+		var groupId, authorId int
+		var hidden bool
+		err := db.QueryRow(
+			"SELECT group_id, user_id, hidden_at IS NOT NULL FROM group_posts WHERE id = ?",
+			poll.TargetID,
+		).Scan(&groupId, &authorId, &hidden)
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if hidden && authorId != userId {
+			return false, nil
+		}
+		return IsGroupMember(db, groupId, userId)
+	}
+	return false, nil
+}
+
+// VotePoll replaces a user's vote on a poll with the given options and returns the poll's new
+// results. Single choice polls take one option; an empty vote withdraws the user's vote.
+// Only published posts' polls can be voted on, until the poll closes.
+func VotePoll(db *sql.DB, pollId int, userId int, optionIds []int) (*Poll, error) {
+	poll, err := getPoll(db, pollId)
+	if err != nil {
+		return nil, err
+	}
+	canView, err := CanViewPoll(db, poll, userId)
+	if err != nil {
+		return nil, err
+	}
+	if !canView {
+		return nil, ErrPollNotFound
+	}
+	if poll.TargetType == PollTargetPost {
+		post, err := getPostForVisibility(db, poll.TargetID)
+		if err != nil {
+			return nil, err
+		}
+		if post.Status != PostStatusPublished || post.Hidden {
+			return nil, ErrPollClosed
+		}
+	}
+	if poll.Closed {
+		return nil, ErrPollClosed
+	}
+	if len(optionIds) > 1 && !poll.MultipleChoice {
+		return nil, ErrInvalidPollVote
+	}
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM poll_votes WHERE poll_id = ? AND user_id = ?", pollId, userId); err != nil {
+		return nil, err
+	}
+
+	seen := map[int]bool{}
+	for _, optionId := range optionIds {
+		if seen[optionId] {
+			return nil, ErrInvalidPollVote
+		}
+		seen[optionId] = true
+
+		result, err := tx.Exec(
+			`INSERT INTO poll_votes (poll_id, option_id, user_id)
+			SELECT poll_id, id, ? FROM poll_options WHERE id = ? AND poll_id = ?`,
+			userId, optionId, pollId,
+		)
+		if err != nil {
+			return nil, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if affected == 0 {
+			return nil, ErrInvalidPollVote
+		}
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if err := loadPollResults(db, []*Poll{poll}, userId); err != nil {
+		return nil, err
+	}
+	return poll, nil
+}
+
+// loadPostPolls fills in the polls of posts or group posts, with their results as the viewer sees them
+func loadPostPolls(db *sql.DB, kind string, posts []Post, viewerId int) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	args := []interface{}{kind}
+	index := map[int]int{}
+	for i := range posts {
+		args = append(args, posts[i].ID)
+		index[posts[i].ID] = i
+	}
+	rows, err := db.Query(
+		`SELECT id, target_type, target_id, multiple_choice, anonymous, closes_at FROM polls
+		WHERE target_type = ? AND target_id IN (?`+strings.Repeat(", ?", len(posts)-1)+`)`,
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	polls := []*Poll{}
+	for rows.Next() {
+		poll := &Poll{}
+		var closesAt sql.NullTime
+		err := rows.Scan(&poll.ID, &poll.TargetType, &poll.TargetID, &poll.MultipleChoice, &poll.Anonymous, &closesAt)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		if closesAt.Valid {
+			poll.ClosesAt = &closesAt.Time
+			poll.Closed = !closesAt.Time.After(time.Now())
+		}
+		polls = append(polls, poll)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if err := loadPollResults(db, polls, viewerId); err != nil {
+		return err
+	}
+	for _, poll := range polls {
+		posts[index[poll.TargetID]].Poll = poll
+	}
+	return nil
+}
+
+// loadPollResults fills in the options of polls with their vote counts, their voters on polls
+// that aren't anonymous, and the viewer's votes
+func loadPollResults(db *sql.DB, polls []*Poll, viewerId int) error {
+	if len(polls) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, len(polls))
+	byId := map[int]*Poll{}
+	for i, poll := range polls {
+		args[i] = poll.ID
+		byId[poll.ID] = poll
+		poll.Options = []PollOption{}
+		poll.VoterCount = 0
+		poll.MyVotes = nil
+	}
+	in := "(?" + strings.Repeat(", ?", len(polls)-1) + ")"
+
+	// Options and their vote counts
+	rows, err := db.Query(
+		`SELECT o.poll_id, o.id, o.text, o.position,
+		(SELECT COUNT(*) FROM poll_votes v WHERE v.option_id = o.id)
+		FROM poll_options o
+		WHERE o.poll_id IN `+in+`
+		ORDER BY o.poll_id, o.position`,
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	optionIndex := map[int]int{}
+	for rows.Next() {
+		var pollId int
+		var option PollOption
+		if err := rows.Scan(&pollId, &option.ID, &option.Text, &option.Position, &option.VoteCount); err != nil {
+			rows.Close()
+			return err
+		}
+		optionIndex[option.ID] = len(byId[pollId].Options)
+		byId[pollId].Options = append(byId[pollId].Options, option)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// Voters, who count once however many options they picked
+	rows, err = db.Query(
+		`SELECT v.poll_id, v.option_id, v.user_id, p.anonymous,
+		u.first_name, u.last_name, u.avatar, u.nickname
+		FROM poll_votes v
+		JOIN polls p ON v.poll_id = p.id
+		JOIN users u ON v.user_id = u.id
+		WHERE v.poll_id IN `+in+`
+		ORDER BY v.created_at, v.user_id`,
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	voters := map[int]map[int]bool{}
+	for rows.Next() {
+		var pollId, optionId int
+		var anonymous bool
+		var voter User
+		err := rows.Scan(&pollId, &optionId, &voter.ID, &anonymous,
+			&voter.FirstName, &voter.LastName, &voter.Avatar, &voter.Nickname)
+		if err != nil {
+			return err
+		}
+		poll := byId[pollId]
+		if voters[pollId] == nil {
+			voters[pollId] = map[int]bool{}
+		}
+		if !voters[pollId][voter.ID] {
+			voters[pollId][voter.ID] = true
+			poll.VoterCount++
+		}
+		if voter.ID == viewerId {
+			poll.MyVotes = append(poll.MyVotes, optionId)
+		}
+		if !anonymous {
+			option := &poll.Options[optionIndex[optionId]]
+			option.Voters = append(option.Voters, voter)
+		}
+	}
+
+	return rows.Err()
+}
@@ -6,11 +6,22 @@ import (
 	"time"
 )
 
+// ErrCannotViewPost is returned when fetching a post the user isn't allowed to see
+var ErrCannotViewPost = errors.New("unauthorized to view this post")
+
+// Post states. Drafts and scheduled posts are only visible to their author.
+const (
+	PostStatusDraft     = "draft"
+	PostStatusScheduled = "scheduled"
+	PostStatusPublished = "published"
+)
+
 type Post struct {
 	ID           int       `json:"id"`
 	UserID       int       `json:"user_id"`
 	Content      string    `json:"content"`
 	ImageURL     string    `json:"image_url,omitempty"`
+	Media        []Media   `json:"media"`
 	Privacy      string    `json:"privacy"`
 	LikeCount    int       `json:"like_count"`
 	DislikeCount int       `json:"dislike_count"`
@@ -19,10 +30,59 @@ type Post struct {
 	User         *User     `json:"user,omitempty"`
 	Comments     []Comment `json:"comments,omitempty"`
 	SelectedUsers []int    `json:"selected_users,omitempty"`
+	// AudienceListIDs are the author's audience lists a private post is shared with.
+	// Only the author is shown them.
+	AudienceListIDs []int `json:"audience_list_ids,omitempty"`
+	Hidden       bool      `json:"hidden,omitempty"`
+	Edited       bool      `json:"edited"`
+	RevisionCount int      `json:"revision_count"`
+	Status       string     `json:"status"`
+	PublishAt    *time.Time `json:"publish_at,omitempty"`
+	ShareCount   int        `json:"share_count"`
+	SharedPostID *int       `json:"shared_post_id,omitempty"`
+	SharedPost   *Post      `json:"shared_post,omitempty"`
+	// SharedPostTombstone replaces SharedPost when the viewer can't see the shared post
+	SharedPostTombstone *PostTombstone `json:"shared_post_tombstone,omitempty"`
+	Poll                *Poll          `json:"poll,omitempty"`
 }
 
-// CreatePost creates a new post
+// visiblePostCondition limits a query on posts p to those a user may see under their privacy
+// settings. Private posts are seen by the users picked for them and the current members of the
+// audience lists they target. The user's ID is bound to each of its four placeholders.
+const visiblePostCondition = `(
+	(p.privacy = 'public') OR
+	(p.privacy = 'almost_private' AND p.user_id = ?) OR
+	(p.privacy = 'almost_private' AND EXISTS (
+		SELECT 1 FROM follows
+		WHERE follower_id = ? AND following_id = p.user_id AND status = 'accepted'
+	)) OR
+	(p.privacy = 'private' AND p.user_id = ?) OR
+	(p.privacy = 'private' AND ? IN (
+		SELECT user_id FROM post_privacy_users WHERE post_id = p.id
+		UNION ALL
+		SELECT alm.user_id FROM post_audience_lists pal
+		JOIN audience_list_members alm ON alm.list_id = pal.list_id
+		WHERE pal.post_id = p.id
+	))
+)`
+
+// CreatePost creates a new post. Posts are published straight away unless Status is draft
+// or PublishAt is set, in which case they are queued until the author or scheduler publishes them.
+// A post with SharedPostID set reposts or quotes that post.
 func CreatePost(db *sql.DB, post Post) (int, error) {
+	status, publishAt, err := postSchedule(post.Status, post.PublishAt)
+	if err != nil {
+		return 0, err
+	}
+
+	var sharedPostId interface{}
+	if post.SharedPostID != nil {
+		sharedPostId, err = resolveSharedPost(db, *post.SharedPostID, post.UserID, post.IsRepost())
+		if err != nil {
+			return 0, err
+		}
+	}
+
 	// Begin transaction
 	tx, err := db.Begin()
 	if err != nil {
@@ -32,8 +92,9 @@ func CreatePost(db *sql.DB, post Post) (int, error) {
 
 	// Insert post
 	result, err := tx.Exec(
-		`INSERT INTO posts (user_id, content, image_url, privacy) VALUES (?, ?, ?, ?)`,
-		post.UserID, post.Content, post.ImageURL, post.Privacy,
+		`INSERT INTO posts (user_id, content, image_url, privacy, status, publish_at, shared_post_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		post.UserID, post.Content, post.ImageURL, post.Privacy, status, publishAt, sharedPostId,
 	)
 	if err != nil {
 		return 0, err
@@ -58,6 +119,30 @@ func CreatePost(db *sql.DB, post Post) (int, error) {
 		}
 	}
 
+	// And the audience lists it is shared with
+	if post.Privacy == "private" && len(post.AudienceListIDs) > 0 {
+		if err := setPostAudienceLists(tx, int(postId), post.UserID, post.AudienceListIDs); err != nil {
+			return 0, err
+		}
+	}
+
+	// Index hashtags and mentions
+	if err := indexContentTags(tx, TagTargetPost, int(postId), post.UserID, post.Content); err != nil {
+		return 0, err
+	}
+
+	// Attach uploads
+	if err := attachMedia(tx, MediaTargetPost, int(postId), post.UserID, post.Media, post.ImageURL); err != nil {
+		return 0, err
+	}
+
+	// Attach the poll
+	if post.Poll != nil {
+		if err := createPoll(tx, PollTargetPost, int(postId), post.Poll); err != nil {
+			return 0, err
+		}
+	}
+
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
 		return 0, err
@@ -69,18 +154,21 @@ func CreatePost(db *sql.DB, post Post) (int, error) {
 // GetPostById retrieves a post by ID
 func GetPostById(db *sql.DB, postId int, currentUserId int) (*Post, error) {
 	post := &Post{}
+	var publishAt sql.NullTime
 	
 	// Get post data
 	err := db.QueryRow(
 		`SELECT p.id, p.user_id, p.content, p.image_url, p.privacy, 
 		COALESCE(p.like_count, 0) as like_count, COALESCE(p.dislike_count, 0) as dislike_count, 
-		p.created_at, p.updated_at
+		p.created_at, p.updated_at, p.hidden_at IS NOT NULL, COALESCE(p.revision_count, 0),
+		p.status, p.publish_at
 		FROM posts p
 		WHERE p.id = ?`,
 		postId,
 	).Scan(
 		&post.ID, &post.UserID, &post.Content, &post.ImageURL, &post.Privacy,
-		&post.LikeCount, &post.DislikeCount, &post.CreatedAt, &post.UpdatedAt,
+		&post.LikeCount, &post.DislikeCount, &post.CreatedAt, &post.UpdatedAt, &post.Hidden, &post.RevisionCount,
+		&post.Status, &publishAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -88,6 +176,10 @@ func GetPostById(db *sql.DB, postId int, currentUserId int) (*Post, error) {
 		}
 		return nil, err
 	}
+	post.Edited = post.RevisionCount > 0
+	if publishAt.Valid {
+		post.PublishAt = &publishAt.Time
+	}
 
 	// Check if user can view this post
 	canView, err := CanViewPost(db, post, currentUserId)
@@ -95,7 +187,7 @@ func GetPostById(db *sql.DB, postId int, currentUserId int) (*Post, error) {
 		return nil, err
 	}
 	if !canView {
-		return nil, errors.New("unauthorized to view this post")
+		return nil, ErrCannotViewPost
 	}
 
 	// Get post user
@@ -122,71 +214,128 @@ func GetPostById(db *sql.DB, postId int, currentUserId int) (*Post, error) {
 			}
 			post.SelectedUsers = append(post.SelectedUsers, userId)
 		}
+
+		if post.UserID == currentUserId {
+			post.AudienceListIDs, err = getPostAudienceLists(db, post.ID)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Get attachments
+	post.Media, err = GetMedia(db, MediaTargetPost, post.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the post it shares and its poll
+	posts := []Post{*post}
+	if err := loadPostShares(db, posts, currentUserId); err != nil {
+		return nil, err
 	}
+	if err := loadPostPolls(db, PollTargetPost, posts, currentUserId); err != nil {
+		return nil, err
+	}
+	*post = posts[0]
 
 	return post, nil
 }
 
-// GetFeedPosts retrieves posts for a user's feed
-func GetFeedPosts(db *sql.DB, userId int, page, limit int, privacy []string) ([]Post, error) {
-	offset := (page - 1) * limit
+// feedPostCondition selects the posts that belong in a user's feed: every unhidden post they are
+// allowed to see, plus their own drafts, scheduled posts and hidden posts. The user's ID binds to
+// its 6 placeholders.
+const feedPostCondition = `(p.hidden_at IS NULL OR p.user_id = ?) AND (p.status = 'published' OR p.user_id = ?) AND ` + visiblePostCondition
+
+// GetFeedPosts retrieves posts for a user's feed, newest first. The page starts after the
+// cursor if one is given, or at the page number otherwise. It also returns the cursor of the
+// page's last post if there are more after it.
+func GetFeedPosts(db *sql.DB, userId int, after *Cursor, page, limit int, privacy []string) ([]Post, *Cursor, error) {
+	offset := pageOffset(after, page, limit)
 	posts := []Post{}
+	args := []interface{}{userId, userId, userId, userId, userId, userId}
 
 	// Build query based on privacy settings
 	query := `
 		SELECT p.id, p.user_id, p.content, p.image_url, p.privacy, 
 		COALESCE(p.like_count, 0) as like_count, COALESCE(p.dislike_count, 0) as dislike_count, 
-		p.created_at, p.updated_at,
+		p.created_at, p.updated_at, p.hidden_at IS NOT NULL, COALESCE(p.revision_count, 0), p.status, p.publish_at,
 		u.id as user_id, u.email, u.first_name, u.last_name, u.avatar, u.nickname
 		FROM posts p
 		JOIN users u ON p.user_id = u.id
-		WHERE 
-			(p.privacy = 'public') OR
-			(p.privacy = 'almost_private' AND p.user_id = ?) OR
-			(p.privacy = 'almost_private' AND EXISTS (
-				SELECT 1 FROM follows 
-				WHERE follower_id = ? AND following_id = p.user_id AND status = 'accepted'
-			)) OR
-			(p.privacy = 'private' AND p.user_id = ?) OR
-			(p.privacy = 'private' AND EXISTS (
-				SELECT 1 FROM post_privacy_users 
-				WHERE post_id = p.id AND user_id = ?
-			))
-		ORDER BY p.created_at DESC
-		LIMIT ? OFFSET ?
-	`
+		WHERE `+feedPostCondition
+	if after != nil {
+		query += " AND (p.created_at, p.id) < (?, ?)"
+		args = append(args, after.timestamp(), after.ID)
+	}
+	// Fetch one extra post to tell whether there is another page
+	query += " ORDER BY p.created_at DESC, p.id DESC LIMIT ? OFFSET ?"
+	args = append(args, limit+1, offset)
 
-	rows, err := db.Query(query, userId, userId, userId, userId, limit, offset)
+	rows, err := db.Query(query, args...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		var post Post
 		var user User
+		var publishAt sql.NullTime
 		
 		err := rows.Scan(
 			&post.ID, &post.UserID, &post.Content, &post.ImageURL, &post.Privacy,
-			&post.LikeCount, &post.DislikeCount, &post.CreatedAt, &post.UpdatedAt,
+			&post.LikeCount, &post.DislikeCount, &post.CreatedAt, &post.UpdatedAt, &post.Hidden, &post.RevisionCount,
+			&post.Status, &publishAt,
 			&user.ID, &user.Email, &user.FirstName, &user.LastName, &user.Avatar, &user.Nickname,
 		)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+		post.Edited = post.RevisionCount > 0
+		if publishAt.Valid {
+			post.PublishAt = &publishAt.Time
 		}
 		
 		post.User = &user
 		posts = append(posts, post)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var next *Cursor
+	if len(posts) > limit {
+		posts = posts[:limit]
+		last := posts[limit-1]
+		next = &Cursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
 
-	return posts, nil
+	// Get attachments
+	if err := loadPostMedia(db, MediaTargetPost, posts); err != nil {
+		return nil, nil, err
+	}
+
+	// Get the posts they share
+	if err := loadPostShares(db, posts, userId); err != nil {
+		return nil, nil, err
+	}
+
+	// Get polls
+	if err := loadPostPolls(db, PollTargetPost, posts, userId); err != nil {
+		return nil, nil, err
+	}
+
+	return posts, next, nil
 }
 
-// UpdatePost updates an existing post
-func UpdatePost(db *sql.DB, postId int, updates map[string]interface{}, userId int) error {
-	// Check if user owns the post
+// UpdatePost updates an existing post. A private post keeps its audience lists unless
+// audience_list_ids is given; a post that is no longer private loses them.
+func UpdatePost(db *sql.DB, postId int, updates map[string]interface{}, actor *User) error {
+	// Check if user may edit the post
 	var postUserId int
-	err := db.QueryRow("SELECT user_id FROM posts WHERE id = ?", postId).Scan(&postUserId)
+	var status string
+	err := db.QueryRow("SELECT user_id, status FROM posts WHERE id = ?", postId).Scan(&postUserId, &status)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return errors.New("post not found")
@@ -194,8 +343,8 @@ func UpdatePost(db *sql.DB, postId int, updates map[string]interface{}, userId i
 		return err
 	}
 
-	if postUserId != userId {
-		return errors.New("unauthorized to update this post")
+	if !CanEditContent(actor, postUserId) {
+		return ErrForbidden
 	}
 
 	// Begin transaction
@@ -205,6 +354,16 @@ func UpdatePost(db *sql.DB, postId int, updates map[string]interface{}, userId i
 	}
 	defer tx.Rollback()
 
+	// Keep the current version in the post's history. Drafts and scheduled posts
+	// haven't been seen by anyone yet, so edits to them aren't kept.
+	if status == PostStatusPublished {
+		content, _ := updates["content"].(string)
+		privacy, _ := updates["privacy"].(string)
+		if err := recordRevision(tx, RevisionTargetPost, postId, content, privacy); err != nil {
+			return err
+		}
+	}
+
 	// Update post
 	_, err = tx.Exec(
 		`UPDATE posts SET content = ?, privacy = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
@@ -214,6 +373,19 @@ func UpdatePost(db *sql.DB, postId int, updates map[string]interface{}, userId i
 		return err
 	}
 
+	// Re-index hashtags and mentions
+	content, _ := updates["content"].(string)
+	if err := indexContentTags(tx, TagTargetPost, postId, postUserId, content); err != nil {
+		return err
+	}
+
+	// Replace attachments if new ones were given
+	if media, ok := updates["media"].([]Media); ok {
+		if err := attachMedia(tx, MediaTargetPost, postId, postUserId, media, ""); err != nil {
+			return err
+		}
+	}
+
 	// If privacy is private, update selected users
 	if updates["privacy"] == "private" {
 		// Delete existing selected users
@@ -234,6 +406,19 @@ func UpdatePost(db *sql.DB, postId int, updates map[string]interface{}, userId i
 				}
 			}
 		}
+
+		// Replace the audience lists if new ones were given
+		if listIds, ok := updates["audience_list_ids"].([]int); ok {
+			if err := setPostAudienceLists(tx, postId, postUserId, listIds); err != nil {
+				return err
+			}
+		}
+	} else {
+		// Otherwise drop the audience lists, so making the post private again later
+		// doesn't quietly share it with those lists' members
+		if _, err := tx.Exec("DELETE FROM post_audience_lists WHERE post_id = ?", postId); err != nil {
+			return err
+		}
 	}
 
 	// Commit transaction
@@ -241,8 +426,8 @@ func UpdatePost(db *sql.DB, postId int, updates map[string]interface{}, userId i
 }
 
 // DeletePost deletes a post
-func DeletePost(db *sql.DB, postId int, userId int) error {
-	// Check if user owns the post
+func DeletePost(db *sql.DB, postId int, actor *User) error {
+	// Check if user may delete the post
 	var postUserId int
 	err := db.QueryRow("SELECT user_id FROM posts WHERE id = ?", postId).Scan(&postUserId)
 	if err != nil {
@@ -252,8 +437,8 @@ func DeletePost(db *sql.DB, postId int, userId int) error {
 		return err
 	}
 
-	if postUserId != userId {
-		return errors.New("unauthorized to delete this post")
+	if !CanDeleteContent(actor, postUserId) {
+		return ErrForbidden
 	}
 
 	// Delete post (cascade will handle related records)
@@ -268,6 +453,16 @@ func CanViewPost(db *sql.DB, post *Post, userId int) (bool, error) {
 		return true, nil
 	}
 
+	// Posts hidden by a moderator are only visible to their author
+	if post.Hidden {
+		return false, nil
+	}
+
+	// So are drafts and posts that are scheduled but not yet published
+	if post.Status != PostStatusPublished {
+		return false, nil
+	}
+
 	// Public posts can be viewed by anyone
 	if post.Privacy == "public" {
 		return true, nil
@@ -289,15 +484,20 @@ func CanViewPost(db *sql.DB, post *Post, userId int) (bool, error) {
 		return exists, nil
 	}
 
-	// Private posts can be viewed by selected users
+	// Private posts can be viewed by selected users and by the current members
+	// of the audience lists they target
 	if post.Privacy == "private" {
 		var exists bool
 		err := db.QueryRow(
 			`SELECT EXISTS(
 				SELECT 1 FROM post_privacy_users 
 				WHERE post_id = ? AND user_id = ?
+			) OR EXISTS(
+				SELECT 1 FROM post_audience_lists pal
+				JOIN audience_list_members alm ON alm.list_id = pal.list_id
+				WHERE pal.post_id = ? AND alm.user_id = ?
 			)`,
-			post.ID, userId,
+			post.ID, userId, post.ID, userId,
 		).Scan(&exists)
 		if err != nil {
 			return false, err
@@ -417,61 +617,91 @@ func AddPostReaction(db *sql.DB, postId int, userId int, reactionType string) (m
 	}, nil
 }
 
-// GetCommentedPosts retrieves posts that a user has commented on
-func GetCommentedPosts(db *sql.DB, userId int, page, limit int) ([]Post, error) {
-	offset := (page - 1) * limit
+// GetCommentedPosts retrieves posts that a user has commented on, each listed once
+func GetCommentedPosts(db *sql.DB, userId int, after *Cursor, page, limit int) ([]Post, *Cursor, error) {
+	offset := pageOffset(after, page, limit)
 	posts := []Post{}
+	listedAt := []time.Time{}
 
 	query := `
-		SELECT DISTINCT p.id, p.user_id, p.content, p.image_url, p.privacy, 
+		SELECT p.id, p.user_id, p.content, p.image_url, p.privacy, 
 		COALESCE(p.like_count, 0) as like_count, COALESCE(p.dislike_count, 0) as dislike_count, 
-		p.created_at, p.updated_at,
-		u.id as user_id, u.email, u.first_name, u.last_name, u.avatar, u.nickname
+		p.created_at, p.updated_at, COALESCE(p.revision_count, 0), p.status,
+		u.id as user_id, u.email, u.first_name, u.last_name, u.avatar, u.nickname,
+		c.created_at AS listed_at
 		FROM posts p
 		JOIN users u ON p.user_id = u.id
-		JOIN comments c ON p.id = c.post_id
-		WHERE c.user_id = ?
-		AND (
-			(p.privacy = 'public') OR
-			(p.privacy = 'almost_private' AND p.user_id = ?) OR
-			(p.privacy = 'almost_private' AND EXISTS (
-				SELECT 1 FROM follows 
-				WHERE follower_id = ? AND following_id = p.user_id AND status = 'accepted'
-			)) OR
-			(p.privacy = 'private' AND p.user_id = ?) OR
-			(p.privacy = 'private' AND EXISTS (
-				SELECT 1 FROM post_privacy_users 
-				WHERE post_id = p.id AND user_id = ?
-			))
+		JOIN comments c ON c.id = (
+			SELECT id FROM comments
+			WHERE post_id = p.id AND user_id = ?
+			ORDER BY created_at DESC, id DESC
+			LIMIT 1
 		)
-		ORDER BY c.created_at DESC
-		LIMIT ? OFFSET ?
-	`
-
-	rows, err := db.Query(query, userId, userId, userId, userId, userId, limit, offset)
+		WHERE p.hidden_at IS NULL AND p.status = 'published'
+		AND ` + visiblePostCondition
+	args := []interface{}{userId, userId, userId, userId, userId}
+	if after != nil {
+		query += " AND (c.created_at, p.id) < (?, ?)"
+		args = append(args, after.timestamp(), after.ID)
+	}
+	// Most recently commented on first, by the user's latest comment on each post.
+	// Fetch one extra post to tell whether there is another page.
+	query += " ORDER BY c.created_at DESC, p.id DESC LIMIT ? OFFSET ?"
+	args = append(args, limit+1, offset)
+
+	rows, err := db.Query(query, args...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		var post Post
 		var user User
+		var listed time.Time
 		
 		err := rows.Scan(
 			&post.ID, &post.UserID, &post.Content, &post.ImageURL, &post.Privacy,
-			&post.LikeCount, &post.DislikeCount, &post.CreatedAt, &post.UpdatedAt,
+			&post.LikeCount, &post.DislikeCount, &post.CreatedAt, &post.UpdatedAt, &post.RevisionCount,
+			&post.Status,
 			&user.ID, &user.Email, &user.FirstName, &user.LastName, &user.Avatar, &user.Nickname,
+			&listed,
 		)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
+		post.Edited = post.RevisionCount > 0
 		
 		post.User = &user
 		posts = append(posts, post)
+		listedAt = append(listedAt, listed)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var next *Cursor
+	if len(posts) > limit {
+		posts = posts[:limit]
+		next = &Cursor{CreatedAt: listedAt[limit-1], ID: posts[limit-1].ID}
 	}
 
-	return posts, nil
+	// Get attachments
+	if err := loadPostMedia(db, MediaTargetPost, posts); err != nil {
+		return nil, nil, err
+	}
+
+	// Get the posts they share
+	if err := loadPostShares(db, posts, userId); err != nil {
+		return nil, nil, err
+	}
+
+	// Get polls
+	if err := loadPostPolls(db, PollTargetPost, posts, userId); err != nil {
+		return nil, nil, err
+	}
+
+	return posts, next, nil
 }
 
 // SavePost saves a post for a user
@@ -510,117 +740,165 @@ func IsPostSaved(db *sql.DB, userID, postID int) (bool, error) {
 }
 
 // GetSavedPosts retrieves posts that a user has saved
-func GetSavedPosts(db *sql.DB, userID, page, limit int) ([]Post, error) {
-	offset := (page - 1) * limit
+func GetSavedPosts(db *sql.DB, userID int, after *Cursor, page, limit int) ([]Post, *Cursor, error) {
+	offset := pageOffset(after, page, limit)
 	posts := []Post{}
+	listedAt := []time.Time{}
 
 	query := `
 		SELECT p.id, p.user_id, p.content, p.image_url, p.privacy, 
 		COALESCE(p.like_count, 0) as like_count, COALESCE(p.dislike_count, 0) as dislike_count, 
-		p.created_at, p.updated_at,
-		u.id as user_id, u.email, u.first_name, u.last_name, u.avatar, u.nickname
+		p.created_at, p.updated_at, COALESCE(p.revision_count, 0), p.status,
+		u.id as user_id, u.email, u.first_name, u.last_name, u.avatar, u.nickname,
+		sp.created_at AS listed_at
 		FROM posts p
 		JOIN users u ON p.user_id = u.id
 		JOIN saved_posts sp ON p.id = sp.post_id
-		WHERE sp.user_id = ?
-		AND (
-			(p.privacy = 'public') OR
-			(p.privacy = 'almost_private' AND p.user_id = ?) OR
-			(p.privacy = 'almost_private' AND EXISTS (
-				SELECT 1 FROM follows 
-				WHERE follower_id = ? AND following_id = p.user_id AND status = 'accepted'
-			)) OR
-			(p.privacy = 'private' AND p.user_id = ?) OR
-			(p.privacy = 'private' AND EXISTS (
-				SELECT 1 FROM post_privacy_users 
-				WHERE post_id = p.id AND user_id = ?
-			))
-		)
-		ORDER BY sp.created_at DESC
-		LIMIT ? OFFSET ?
-	`
-
-	rows, err := db.Query(query, userID, userID, userID, userID, userID, limit, offset)
+		WHERE sp.user_id = ? AND p.hidden_at IS NULL AND p.status = 'published'
+		AND ` + visiblePostCondition
+	args := []interface{}{userID, userID, userID, userID, userID}
+	if after != nil {
+		query += " AND (sp.created_at, p.id) < (?, ?)"
+		args = append(args, after.timestamp(), after.ID)
+	}
+	// Fetch one extra post to tell whether there is another page
+	query += " ORDER BY sp.created_at DESC, p.id DESC LIMIT ? OFFSET ?"
+	args = append(args, limit+1, offset)
+
+	rows, err := db.Query(query, args...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		var post Post
 		var user User
+		var listed time.Time
 		
 		err := rows.Scan(
 			&post.ID, &post.UserID, &post.Content, &post.ImageURL, &post.Privacy,
-			&post.LikeCount, &post.DislikeCount, &post.CreatedAt, &post.UpdatedAt,
+			&post.LikeCount, &post.DislikeCount, &post.CreatedAt, &post.UpdatedAt, &post.RevisionCount,
+			&post.Status,
 			&user.ID, &user.Email, &user.FirstName, &user.LastName, &user.Avatar, &user.Nickname,
+			&listed,
 		)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
+		post.Edited = post.RevisionCount > 0
 		
 		post.User = &user
 		posts = append(posts, post)
+		listedAt = append(listedAt, listed)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var next *Cursor
+	if len(posts) > limit {
+		posts = posts[:limit]
+		next = &Cursor{CreatedAt: listedAt[limit-1], ID: posts[limit-1].ID}
 	}
 
-	return posts, nil
+	// Get attachments
+	if err := loadPostMedia(db, MediaTargetPost, posts); err != nil {
+		return nil, nil, err
+	}
+
+	// Get the posts they share
+	if err := loadPostShares(db, posts, userID); err != nil {
+		return nil, nil, err
+	}
+
+	// Get polls
+	if err := loadPostPolls(db, PollTargetPost, posts, userID); err != nil {
+		return nil, nil, err
+	}
+
+	return posts, next, nil
 }
 
 // GetLikedPosts retrieves posts that a user has liked
-func GetLikedPosts(db *sql.DB, userId int, page, limit int) ([]Post, error) {
-	offset := (page - 1) * limit
+func GetLikedPosts(db *sql.DB, userId int, after *Cursor, page, limit int) ([]Post, *Cursor, error) {
+	offset := pageOffset(after, page, limit)
 	posts := []Post{}
+	listedAt := []time.Time{}
 
 	query := `
 		SELECT p.id, p.user_id, p.content, p.image_url, p.privacy, 
 		COALESCE(p.like_count, 0) as like_count, COALESCE(p.dislike_count, 0) as dislike_count, 
-		p.created_at, p.updated_at,
-		u.id as user_id, u.email, u.first_name, u.last_name, u.avatar, u.nickname
+		p.created_at, p.updated_at, COALESCE(p.revision_count, 0), p.status,
+		u.id as user_id, u.email, u.first_name, u.last_name, u.avatar, u.nickname,
+		pr.created_at AS listed_at
 		FROM posts p
 		JOIN users u ON p.user_id = u.id
 		JOIN post_reactions pr ON p.id = pr.post_id
-		WHERE pr.user_id = ? AND pr.reaction_type = 'like'
-		AND (
-			(p.privacy = 'public') OR
-			(p.privacy = 'almost_private' AND p.user_id = ?) OR
-			(p.privacy = 'almost_private' AND EXISTS (
-				SELECT 1 FROM follows 
-				WHERE follower_id = ? AND following_id = p.user_id AND status = 'accepted'
-			)) OR
-			(p.privacy = 'private' AND p.user_id = ?) OR
-			(p.privacy = 'private' AND EXISTS (
-				SELECT 1 FROM post_privacy_users 
-				WHERE post_id = p.id AND user_id = ?
-			))
-		)
-		ORDER BY pr.created_at DESC
-		LIMIT ? OFFSET ?
-	`
-
-	rows, err := db.Query(query, userId, userId, userId, userId, userId, limit, offset)
+		WHERE pr.user_id = ? AND pr.reaction_type = 'like' AND p.hidden_at IS NULL AND p.status = 'published'
+		AND ` + visiblePostCondition
+	args := []interface{}{userId, userId, userId, userId, userId}
+	if after != nil {
+		query += " AND (pr.created_at, p.id) < (?, ?)"
+		args = append(args, after.timestamp(), after.ID)
+	}
+	// Fetch one extra post to tell whether there is another page
+	query += " ORDER BY pr.created_at DESC, p.id DESC LIMIT ? OFFSET ?"
+	args = append(args, limit+1, offset)
+
+	rows, err := db.Query(query, args...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		var post Post
 		var user User
+		var listed time.Time
 		
 		err := rows.Scan(
 			&post.ID, &post.UserID, &post.Content, &post.ImageURL, &post.Privacy,
-			&post.LikeCount, &post.DislikeCount, &post.CreatedAt, &post.UpdatedAt,
+			&post.LikeCount, &post.DislikeCount, &post.CreatedAt, &post.UpdatedAt, &post.RevisionCount,
+			&post.Status,
 			&user.ID, &user.Email, &user.FirstName, &user.LastName, &user.Avatar, &user.Nickname,
+			&listed,
 		)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
+		post.Edited = post.RevisionCount > 0
 		
 		post.User = &user
 		posts = append(posts, post)
+		listedAt = append(listedAt, listed)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var next *Cursor
+	if len(posts) > limit {
+		posts = posts[:limit]
+		next = &Cursor{CreatedAt: listedAt[limit-1], ID: posts[limit-1].ID}
+	}
+
+	// Get attachments
+	if err := loadPostMedia(db, MediaTargetPost, posts); err != nil {
+		return nil, nil, err
+	}
+
+	// Get the posts they share
+	if err := loadPostShares(db, posts, userId); err != nil {
+		return nil, nil, err
+	}
+
+	// Get polls
+	if err := loadPostPolls(db, PollTargetPost, posts, userId); err != nil {
+		return nil, nil, err
 	}
 
-	return posts, nil
+	return posts, next, nil
 }
 
 // GetReactions gets reaction counts and user reaction for a post
@@ -0,0 +1,230 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrInvalidPostStatus is returned for a status other than draft, scheduled or published
+	ErrInvalidPostStatus = errors.New("invalid post status")
+	// ErrInvalidPublishTime is returned when scheduling a post without a publish time in the future
+	ErrInvalidPublishTime = errors.New("publish time must be in the future")
+	// ErrPostAlreadyPublished is returned when rescheduling a post that has already been published
+	ErrPostAlreadyPublished = errors.New("post has already been published")
+)
+
+// postSchedule works out the status a post is saved with. A post with a publish time is
+// scheduled unless it is explicitly a draft or published now; one without is published
+// straight away. Publish times are kept in UTC so they compare correctly in SQL.
+func postSchedule(status string, publishAt *time.Time) (string, *time.Time, error) {
+	switch status {
+	case "":
+		if publishAt == nil {
+			return PostStatusPublished, nil, nil
+		}
+	case PostStatusDraft, PostStatusPublished:
+		return status, nil, nil
+	case PostStatusScheduled:
+		if publishAt == nil {
+			return "", nil, ErrInvalidPublishTime
+		}
+	default:
+		return "", nil, ErrInvalidPostStatus
+	}
+
+	if !publishAt.After(time.Now()) {
+		return "", nil, ErrInvalidPublishTime
+	}
+	at := publishAt.UTC()
+	return PostStatusScheduled, &at, nil
+}
+
+// GetQueuedPosts retrieves a user's drafts and scheduled posts. Scheduled posts come first
+// in the order they will be published, followed by drafts, most recently edited first.
+func GetQueuedPosts(db *sql.DB, userId int) ([]Post, error) {
+	posts := []Post{}
+
+	rows, err := db.Query(
+		`SELECT p.id, p.user_id, p.content, p.image_url, p.privacy,
+		p.created_at, p.updated_at, p.status, p.publish_at
+		FROM posts p
+		WHERE p.user_id = ? AND p.status != ?
+		ORDER BY p.status = ? ASC, p.publish_at ASC, p.updated_at DESC`,
+		userId, PostStatusPublished, PostStatusDraft,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var post Post
+		var publishAt sql.NullTime
+		err := rows.Scan(
+			&post.ID, &post.UserID, &post.Content, &post.ImageURL, &post.Privacy,
+			&post.CreatedAt, &post.UpdatedAt, &post.Status, &publishAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if publishAt.Valid {
+			post.PublishAt = &publishAt.Time
+		}
+		posts = append(posts, post)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Get attachments
+	if err := loadPostMedia(db, MediaTargetPost, posts); err != nil {
+		return nil, err
+	}
+
+	// Get the posts they share
+	if err := loadPostShares(db, posts, userId); err != nil {
+		return nil, err
+	}
+
+	// Get polls
+	if err := loadPostPolls(db, PollTargetPost, posts, userId); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// SetPostSchedule changes when one of the user's unpublished posts goes out: it can be
+// scheduled for a new time, moved back to drafts or published straight away.
+// It returns the status the post ended up with.
+func SetPostSchedule(db *sql.DB, postId int, userId int, status string, publishAt *time.Time) (string, error) {
+	var postUserId int
+	var currentStatus string
+	err := db.QueryRow("SELECT user_id, status FROM posts WHERE id = ?", postId).Scan(&postUserId, &currentStatus)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", errors.New("post not found")
+		}
+		return "", err
+	}
+
+	// Only the author decides when their post is published
+	if postUserId != userId {
+		return "", ErrForbidden
+	}
+	if currentStatus == PostStatusPublished {
+		return "", ErrPostAlreadyPublished
+	}
+
+	status, publishAt, err = postSchedule(status, publishAt)
+	if err != nil {
+		return "", err
+	}
+
+	if status == PostStatusPublished {
+		published, err := publishPost(db, postId)
+		if err != nil {
+			return "", err
+		}
+		if !published {
+			return "", ErrPostAlreadyPublished
+		}
+		return status, nil
+	}
+
+	// The scheduler may have published the post since it was checked above
+	result, err := db.Exec(
+		`UPDATE posts SET status = ?, publish_at = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status != ?`,
+		status, publishAt, postId, PostStatusPublished,
+	)
+	if err != nil {
+		return "", err
+	}
+	if rows, err := result.RowsAffected(); err != nil {
+		return "", err
+	} else if rows == 0 {
+		return "", ErrPostAlreadyPublished
+	}
+
+	return status, nil
+}
+
+// PublishDuePosts publishes every scheduled post whose publish time has passed and
+// returns them, so the caller can record their activity as of publishing. Each post's
+// creation time becomes the time it was published so it shows up at the top of feeds.
+func PublishDuePosts(db *sql.DB) ([]Post, error) {
+	now := time.Now().UTC()
+	rows, err := db.Query(
+		`SELECT id, user_id, content, COALESCE(image_url, ''), shared_post_id FROM posts
+		WHERE status = ? AND publish_at <= ?
+		ORDER BY publish_at ASC`,
+		PostStatusScheduled, now,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	due := []Post{}
+	for rows.Next() {
+		var post Post
+		var sharedPostId sql.NullInt64
+		if err := rows.Scan(&post.ID, &post.UserID, &post.Content, &post.ImageURL, &sharedPostId); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if sharedPostId.Valid {
+			sharedId := int(sharedPostId.Int64)
+			post.SharedPostID = &sharedId
+		}
+		due = append(due, post)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	published := []Post{}
+	for _, post := range due {
+		// Skip posts the author rescheduled, unscheduled or published in the meantime
+		result, err := db.Exec(
+			`UPDATE posts SET status = ?, publish_at = NULL, created_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+			WHERE id = ? AND status = ? AND publish_at <= ?`,
+			PostStatusPublished, post.ID, PostStatusScheduled, now,
+		)
+		if err != nil {
+			return published, err
+		}
+		if rows, err := result.RowsAffected(); err != nil {
+			return published, err
+		} else if rows > 0 {
+			post.Status = PostStatusPublished
+			published = append(published, post)
+		}
+	}
+
+	return published, nil
+}
+
+// publishPost publishes a draft or scheduled post straight away. As with scheduled posts, its
+// creation time becomes the time it was published so it shows up at the top of feeds.
+// It reports false if the post was already published.
+func publishPost(db *sql.DB, postId int) (bool, error) {
+	result, err := db.Exec(
+		`UPDATE posts SET status = ?, publish_at = NULL, created_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status != ?`,
+		PostStatusPublished, postId, PostStatusPublished,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
@@ -0,0 +1,333 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Report states
+const (
+	ReportStatusOpen      = "open"
+	ReportStatusActioned  = "actioned"
+	ReportStatusDismissed = "dismissed"
+)
+
+// Ways a moderator can resolve a report
+const (
+	// ReportResolutionHide hides the content from everyone but its author
+	ReportResolutionHide = "hide"
+	// ReportResolutionRemove deletes the content
+	ReportResolutionRemove = "remove"
+	// ReportResolutionAcknowledge marks the report actioned without touching the content,
+	// e.g. when the reported user was suspended instead
+	ReportResolutionAcknowledge = "acknowledge"
+	// ReportResolutionDismiss closes the report with no action
+	ReportResolutionDismiss = "dismiss"
+)
+
+// ReportReasons are the reasons a user can give when reporting something
+var ReportReasons = []string{"spam", "harassment", "hate", "violence", "nudity", "misinformation", "other"}
+
+var (
+	// ErrReportNotFound is returned when a report does not exist
+	ErrReportNotFound = errors.New("report not found")
+	// ErrInvalidReportReason is returned when a report's reason isn't one of ReportReasons
+	ErrInvalidReportReason = errors.New("invalid report reason")
+	// ErrDuplicateReport is returned when a user reports something they already have an open report on
+	ErrDuplicateReport = errors.New("you have already reported this")
+	// ErrCannotReportSelf is returned when a user reports their own content or profile
+	ErrCannotReportSelf = errors.New("you cannot report yourself")
+	// ErrReportResolved is returned when resolving a report that is no longer open
+	ErrReportResolved = errors.New("report has already been resolved")
+	// ErrInvalidResolution is returned for an unknown resolution, or one that doesn't apply to the report's target
+	ErrInvalidResolution = errors.New("invalid resolution for this report")
+)
+
+// Report is a user's complaint about a post, comment, group post, message or profile
+type Report struct {
+	ID             int                    `json:"id"`
+	ReporterID     int                    `json:"reporter_id"`
+	TargetType     string                 `json:"target_type"`
+	TargetID       int                    `json:"target_id"`
+	TargetUserID   int                    `json:"target_user_id"`
+	Reason         string                 `json:"reason"`
+	Details        string                 `json:"details,omitempty"`
+	Status         string                 `json:"status"`
+	Resolution     string                 `json:"resolution,omitempty"`
+	ResolutionNote string                 `json:"resolution_note,omitempty"`
+	ResolvedBy     *int                   `json:"resolved_by,omitempty"`
+	ResolvedAt     *time.Time             `json:"resolved_at,omitempty"`
+	CreatedAt      time.Time              `json:"created_at"`
+	Reporter       *User                  `json:"reporter,omitempty"`
+	TargetUser     *User                  `json:"target_user,omitempty"`
+	Content        map[string]interface{} `json:"content,omitempty"`
+}
+
+// IsValidReportReason reports whether a reason is one of ReportReasons
+func IsValidReportReason(reason string) bool {
+	for _, r := range ReportReasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// scanReport scans a report row selected with reportColumns
+func scanReport(row interface{ Scan(...interface{}) error }, report *Report) error {
+	var targetUserId, resolvedBy sql.NullInt64
+	var details, resolution, resolutionNote sql.NullString
+	var resolvedAt sql.NullTime
+	err := row.Scan(
+		&report.ID, &report.ReporterID, &report.TargetType, &report.TargetID, &targetUserId,
+		&report.Reason, &details, &report.Status, &resolution, &resolutionNote,
+		&resolvedBy, &resolvedAt, &report.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	report.TargetUserID = int(targetUserId.Int64)
+	report.Details = details.String
+	report.Resolution = resolution.String
+	report.ResolutionNote = resolutionNote.String
+	if resolvedBy.Valid {
+		id := int(resolvedBy.Int64)
+		report.ResolvedBy = &id
+	}
+	if resolvedAt.Valid {
+		report.ResolvedAt = &resolvedAt.Time
+	}
+	return nil
+}
+
+const reportColumns = `id, reporter_id, target_type, target_id, target_user_id, reason, details, status,
+	resolution, resolution_note, resolved_by, resolved_at, created_at`
+
+// CreateReport files a report. The caller checks that the reporter can see what they are reporting
+// and sets TargetUserID to its author, or to the reported user for profiles.
+func CreateReport(db *sql.DB, report Report) (int, error) {
+	if !IsValidReportReason(report.Reason) {
+		return 0, ErrInvalidReportReason
+	}
+	if report.TargetUserID == report.ReporterID {
+		return 0, ErrCannotReportSelf
+	}
+
+	var exists bool
+	err := db.QueryRow(
+		`SELECT EXISTS(
+			SELECT 1 FROM reports
+			WHERE reporter_id = ? AND target_type = ? AND target_id = ? AND status = ?
+		)`,
+		report.ReporterID, report.TargetType, report.TargetID, ReportStatusOpen,
+	).Scan(&exists)
+	if err != nil {
+		return 0, err
+	}
+	if exists {
+		return 0, ErrDuplicateReport
+	}
+
+	result, err := db.Exec(
+		`INSERT INTO reports (reporter_id, target_type, target_id, target_user_id, reason, details)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		report.ReporterID, report.TargetType, report.TargetID, report.TargetUserID, report.Reason, report.Details,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	reportId, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(reportId), nil
+}
+
+// GetReportById retrieves a report along with its reporter, the reported user and
+// the reported content as it stands now
+func GetReportById(db *sql.DB, reportId int) (*Report, error) {
+	report := &Report{}
+	err := scanReport(db.QueryRow(`SELECT `+reportColumns+` FROM reports WHERE id = ?`, reportId), report)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrReportNotFound
+		}
+		return nil, err
+	}
+
+	if err := attachReportDetails(db, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// GetReports retrieves the moderation queue, oldest first so reports are worked through in order.
+// Supported filters are "status" and "target_type" (both string).
+func GetReports(db *sql.DB, filters map[string]interface{}, page int, limit int) ([]Report, error) {
+	offset := (page - 1) * limit
+	reports := []Report{}
+
+	// Build WHERE clause based on filters
+	whereClause := "WHERE 1 = 1"
+	args := []interface{}{}
+	if status, ok := filters["status"].(string); ok && status != "" {
+		whereClause += " AND status = ?"
+		args = append(args, status)
+	}
+	if targetType, ok := filters["target_type"].(string); ok && targetType != "" {
+		whereClause += " AND target_type = ?"
+		args = append(args, targetType)
+	}
+	args = append(args, limit, offset)
+
+	rows, err := db.Query(
+		`SELECT `+reportColumns+` FROM reports `+whereClause+`
+		ORDER BY created_at ASC, id ASC
+		LIMIT ? OFFSET ?`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var report Report
+		if err := scanReport(rows, &report); err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range reports {
+		if err := attachReportDetails(db, &reports[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return reports, nil
+}
+
+// attachReportDetails loads the users involved in a report and a snapshot of the reported content.
+// Content that has since been removed is left empty.
+func attachReportDetails(db *sql.DB, report *Report) error {
+	var err error
+	report.Reporter, err = GetUserById(db, report.ReporterID)
+	if err != nil {
+		return err
+	}
+	if report.TargetUserID != 0 {
+		report.TargetUser, err = GetUserById(db, report.TargetUserID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if report.TargetType == ModTargetUser {
+		return nil
+	}
+	report.Content, _, err = contentSnapshot(db, report.TargetType, report.TargetID)
+	if err != nil && err != ErrModerationTargetNotFound {
+		return err
+	}
+	return nil
+}
+
+// ResolveReport applies a moderator's decision on a report. Hiding or removing the content,
+// logging it and closing every open report on the same target happen in one transaction.
+// It returns the reports that were closed so their reporters can be told the outcome.
+func ResolveReport(db *sql.DB, moderatorId int, reportId int, resolution string, note string) ([]Report, error) {
+	report, err := GetReportById(db, reportId)
+	if err != nil {
+		return nil, err
+	}
+	if report.Status != ReportStatusOpen {
+		return nil, ErrReportResolved
+	}
+
+	status := ReportStatusActioned
+	if resolution == ReportResolutionDismiss {
+		status = ReportStatusDismissed
+	}
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	switch resolution {
+	case ReportResolutionHide:
+		if report.TargetType == ModTargetUser {
+			return nil, ErrInvalidResolution
+		}
+		if _, err := hideContent(tx, moderatorId, report.TargetType, report.TargetID, note); err != nil {
+			return nil, err
+		}
+	case ReportResolutionRemove:
+		if report.TargetType == ModTargetUser {
+			return nil, ErrInvalidResolution
+		}
+		if _, err := forceDeleteContent(tx, moderatorId, report.TargetType, report.TargetID, note); err != nil {
+			return nil, err
+		}
+	case ReportResolutionAcknowledge, ReportResolutionDismiss:
+	default:
+		return nil, ErrInvalidResolution
+	}
+
+	// Get the open reports on the same target before closing them
+	rows, err := tx.Query(
+		`SELECT `+reportColumns+` FROM reports WHERE target_type = ? AND target_id = ? AND status = ?`,
+		report.TargetType, report.TargetID, ReportStatusOpen,
+	)
+	if err != nil {
+		return nil, err
+	}
+	resolved := []Report{}
+	for rows.Next() {
+		var r Report
+		if err := scanReport(rows, &r); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		resolved = append(resolved, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(
+		`UPDATE reports SET status = ?, resolution = ?, resolution_note = ?, resolved_by = ?, resolved_at = ?
+		WHERE target_type = ? AND target_id = ? AND status = ?`,
+		status, resolution, note, moderatorId, now,
+		report.TargetType, report.TargetID, ReportStatusOpen,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	for i := range resolved {
+		resolved[i].Status = status
+		resolved[i].Resolution = resolution
+		resolved[i].ResolutionNote = note
+		resolved[i].ResolvedBy = &moderatorId
+		resolved[i].ResolvedAt = &now
+	}
+	return resolved, nil
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Kinds of content that keep an edit history
+const (
+	RevisionTargetPost             = "post"
+	RevisionTargetComment          = "comment"
+	RevisionTargetGroupPostComment = "group_post_comment"
+)
+
+// Revision is an earlier version of a post or comment, as it stood before an edit replaced it
+type Revision struct {
+	ID         int       `json:"id"`
+	Content    string    `json:"content"`
+	Privacy    string    `json:"privacy,omitempty"`
+	ImageURL   string    `json:"image_url,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+// revisionTables describes where each kind of content and its earlier versions are stored.
+// Besides content, posts keep their privacy and comments their image in each version.
+var revisionTables = map[string]struct {
+	table      string
+	revisions  string
+	foreignKey string
+	extra      string
+}{
+	RevisionTargetPost:             {"posts", "post_revisions", "post_id", "privacy"},
+	RevisionTargetComment:          {"comments", "comment_revisions", "comment_id", "image_url"},
+	RevisionTargetGroupPostComment: {"group_post_comments", "group_post_comment_revisions", "comment_id", "image_url"},
+}
+
+// recordRevision saves the current version of a post or comment as a revision before an edit
+// replaces it with the given content and extra field. It must run in the edit's transaction.
+// Edits that change nothing aren't recorded.
+func recordRevision(tx *sql.Tx, kind string, id int, content string, extra string) error {
+	tables := revisionTables[kind]
+
+	var currentContent string
+	var currentExtra sql.NullString
+	var writtenAt time.Time
+	err := tx.QueryRow(
+		"SELECT content, "+tables.extra+", updated_at FROM "+tables.table+" WHERE id = ?",
+		id,
+	).Scan(&currentContent, &currentExtra, &writtenAt)
+	if err != nil {
+		return err
+	}
+
+	if currentContent == content && currentExtra.String == extra {
+		return nil
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO "+tables.revisions+" ("+tables.foreignKey+", content, "+tables.extra+", created_at) VALUES (?, ?, ?, ?)",
+		id, currentContent, currentExtra.String, writtenAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"UPDATE "+tables.table+" SET revision_count = COALESCE(revision_count, 0) + 1 WHERE id = ?",
+		id,
+	)
+	return err
+}
+
+// GetRevisions retrieves the earlier versions of a post or comment the user may see, newest first.
+// Callers check that the user may view the content itself. A post's earlier versions were shared
+// with the audience of their own privacy setting, so other users only see the versions whose
+// audience they were in; otherwise widening a post's privacy would reveal what it used to say.
+func GetRevisions(db *sql.DB, kind string, id int, userId int) ([]Revision, error) {
+	tables := revisionTables[kind]
+	revisions := []Revision{}
+
+	rows, err := db.Query(
+		"SELECT id, content, "+tables.extra+", created_at, replaced_at FROM "+tables.revisions+`
+		WHERE `+tables.foreignKey+` = ?
+		ORDER BY replaced_at DESC, id DESC`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var revision Revision
+		var extra sql.NullString
+		err := rows.Scan(&revision.ID, &revision.Content, &extra, &revision.CreatedAt, &revision.ReplacedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if tables.extra == "privacy" {
+			revision.Privacy = extra.String
+		} else {
+			revision.ImageURL = extra.String
+		}
+		revisions = append(revisions, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if kind == RevisionTargetPost {
+		return visiblePostRevisions(db, id, userId, revisions)
+	}
+	return revisions, nil
+}
+
+// visiblePostRevisions keeps the revisions of a post whose privacy setting the user would have passed
+func visiblePostRevisions(db *sql.DB, postId int, userId int, revisions []Revision) ([]Revision, error) {
+	var authorId int
+	err := db.QueryRow("SELECT user_id FROM posts WHERE id = ?", postId).Scan(&authorId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return []Revision{}, nil
+		}
+		return nil, err
+	}
+	if authorId == userId {
+		return revisions, nil
+	}
+
+	visible := []Revision{}
+	for _, revision := range revisions {
+		// Check the version as if it were the post today, published with its old privacy
+		version := &Post{ID: postId, UserID: authorId, Privacy: revision.Privacy, Status: PostStatusPublished}
+		canView, err := CanViewPost(db, version, userId)
+		if err != nil {
+			return nil, err
+		}
+		if canView {
+			visible = append(visible, revision)
+		}
+	}
+	return visible, nil
+}
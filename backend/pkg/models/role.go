@@ -0,0 +1,107 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// Role is a user's site-wide role
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+// Permission is something a role allows beyond what every user can do with their own content
+type Permission string
+
+const (
+	// PermModerateContent allows removing other people's posts, comments and group posts
+	PermModerateContent Permission = "content:moderate"
+	// PermManageGroups allows managing any group as if its creator
+	PermManageGroups Permission = "groups:manage"
+	// PermManageUsers allows suspending and banning users
+	PermManageUsers Permission = "users:manage"
+	// PermManageRoles allows changing users' roles
+	PermManageRoles Permission = "roles:manage"
+)
+
+var (
+	// ErrForbidden is returned when a user isn't allowed to act on a resource
+	ErrForbidden = errors.New("you are not allowed to do this")
+	// ErrInvalidRole is returned when setting a role that doesn't exist
+	ErrInvalidRole = errors.New("invalid role")
+)
+
+// rolePermissions lists what each role may do. Regular users have no extra permissions.
+var rolePermissions = map[Role][]Permission{
+	RoleModerator: {PermModerateContent, PermManageGroups},
+	RoleAdmin:     {PermModerateContent, PermManageGroups, PermManageUsers, PermManageRoles},
+}
+
+// IsValidRole reports whether a role exists
+func IsValidRole(role Role) bool {
+	return role == RoleUser || role == RoleModerator || role == RoleAdmin
+}
+
+// Can reports whether the user's role grants a permission
+func (u *User) Can(permission Permission) bool {
+	if u == nil {
+		return false
+	}
+	for _, p := range rolePermissions[u.Role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// SetUserRole changes a user's role
+func SetUserRole(db *sql.DB, userId int, role Role) error {
+	if !IsValidRole(role) {
+		return ErrInvalidRole
+	}
+
+	result, err := db.Exec(
+		"UPDATE users SET role = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		role, userId,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// PromoteAdmins gives the admin role to the accounts with the given emails.
+// It is how the first administrators are set up, from the ADMIN_EMAILS setting.
+// Only verified addresses count, so nobody can claim the role by registering an
+// admin's email first; an admin who verifies later is promoted on the next start.
+func PromoteAdmins(db *sql.DB, emails []string) error {
+	for _, email := range emails {
+		email = strings.TrimSpace(email)
+		if email == "" {
+			continue
+		}
+		_, err := db.Exec(`UPDATE users SET role = ?
+			WHERE email = ? AND role != ? AND email_verified_at IS NOT NULL`,
+			RoleAdmin, email, RoleAdmin,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
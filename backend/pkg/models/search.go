@@ -0,0 +1,328 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"html"
+	"strings"
+	"unicode"
+)
+
+// Kinds of result a search can return
+const (
+	SearchTypePost      = "posts"
+	SearchTypeComment   = "comments"
+	SearchTypeGroupPost = "group_posts"
+	SearchTypeUser      = "users"
+	SearchTypeGroup     = "groups"
+)
+
+// SearchTypes lists every kind of search result, in the order they are searched
+var SearchTypes = []string{SearchTypePost, SearchTypeComment, SearchTypeGroupPost, SearchTypeUser, SearchTypeGroup}
+
+// MaxSearchTerms is how many words of a query are searched for; the rest are ignored
+const MaxSearchTerms = 10
+
+// ErrEmptySearch is returned when a query has nothing to search for
+var ErrEmptySearch = errors.New("search query is required")
+
+// SearchResult is one hit, with the matching text highlighted and the thing it matched.
+// Only the field for the result's type is set.
+type SearchResult struct {
+	Type      string   `json:"type"`
+	ID        int      `json:"id"`
+	Snippet   string   `json:"snippet"`
+	Post      *Post    `json:"post,omitempty"`
+	Comment   *Comment `json:"comment,omitempty"`
+	GroupPost *Post    `json:"group_post,omitempty"`
+	GroupID   int      `json:"group_id,omitempty"`
+	User      *User    `json:"user,omitempty"`
+	Group     *Group   `json:"group,omitempty"`
+}
+
+// Highlighted terms are marked with control characters by SQLite, which can't appear in the
+// escaped text, and swapped for <mark> tags once the snippet is HTML-escaped
+const (
+	snippetOpen  = "\x02"
+	snippetClose = "\x03"
+)
+
+// searchBind is what one of a search query's placeholders is bound to
+type searchBind int
+
+const (
+	bindMatch searchBind = iota // the FTS match expression
+	bindUser                    // the searching user's ID
+)
+
+// matchThenUser binds the match expression followed by the searching user's ID n times
+func matchThenUser(n int) []searchBind {
+	binds := []searchBind{bindMatch}
+	for i := 0; i < n; i++ {
+		binds = append(binds, bindUser)
+	}
+	return binds
+}
+
+// profileNameColumns limits a match to the name columns of users_fts
+const profileNameColumns = `{first_name last_name nickname}`
+
+// publicProfileCondition holds for users whose profile anyone can view
+const publicProfileCondition = `COALESCE((SELECT is_public FROM user_profiles WHERE user_id = u.id), 1) = 1`
+
+// searchQueries select the hits for each kind of result as (type, id, rank, snippet), filtered
+// by the same rules that decide who can see the content. Lower ranks are better matches.
+var searchQueries = map[string]struct {
+	query string
+	binds []searchBind // what the query's placeholders are bound to, in order
+}{
+	// Posts follow CanViewPost: authors always see their own, others only published,
+	// unhidden posts their privacy setting allows
+	SearchTypePost: {`SELECT 'posts' AS type, p.id AS id, bm25(posts_fts) AS rank,
+		snippet(posts_fts, 0, '` + snippetOpen + `', '` + snippetClose + `', '…', 12) AS snippet
+		FROM posts_fts JOIN posts p ON p.id = posts_fts.rowid
+		WHERE posts_fts MATCH ? AND (p.user_id = ? OR (
+			p.hidden_at IS NULL AND p.status = 'published' AND ` + visiblePostCondition + `
+		))`, matchThenUser(5)},
+	// Comments are visible to whoever can see their post
+	SearchTypeComment: {`SELECT 'comments' AS type, c.id AS id, bm25(comments_fts) AS rank,
+		snippet(comments_fts, 0, '` + snippetOpen + `', '` + snippetClose + `', '…', 12) AS snippet
+		FROM comments_fts JOIN comments c ON c.id = comments_fts.rowid
+		JOIN posts p ON p.id = c.post_id
+		WHERE comments_fts MATCH ? AND c.hidden_at IS NULL AND (p.user_id = ? OR (
+			p.hidden_at IS NULL AND p.status = 'published' AND ` + visiblePostCondition + `
+		))`, matchThenUser(5)},
+	// Group posts are visible to the group's members
+	SearchTypeGroupPost: {`SELECT 'group_posts' AS type, gp.id AS id, bm25(group_posts_fts) AS rank,
+		snippet(group_posts_fts, 0, '` + snippetOpen + `', '` + snippetClose + `', '…', 12) AS snippet
+		FROM group_posts_fts JOIN group_posts gp ON gp.id = group_posts_fts.rowid
+		WHERE group_posts_fts MATCH ? AND gp.hidden_at IS NULL AND EXISTS (
+			SELECT 1 FROM group_members
+			WHERE group_id = gp.group_id AND user_id = ? AND status = 'accepted'
+		)`, matchThenUser(1)},
+	// Anyone can find people by name, except banned users and accounts being deleted.
+	// Names count for more than what people wrote about themselves. Like GetProfile, the
+	// about me of a private profile is only searched for its owner; everyone else can only
+	// match its names, so the snippet never comes from the about me either.
+	SearchTypeUser: {`SELECT 'users' AS type, u.id AS id, bm25(users_fts, 10.0, 10.0, 10.0, 1.0) AS rank,
+		snippet(users_fts, -1, '` + snippetOpen + `', '` + snippetClose + `', '…', 12) AS snippet
+		FROM users_fts JOIN users u ON u.id = users_fts.rowid
+		WHERE users_fts MATCH ? AND u.banned_at IS NULL AND u.deletion_scheduled_at IS NULL
+		AND (` + publicProfileCondition + ` OR u.id = ?)
+		UNION ALL
+		SELECT 'users' AS type, u.id AS id, bm25(users_fts, 10.0, 10.0, 10.0, 1.0) AS rank,
+		snippet(users_fts, -1, '` + snippetOpen + `', '` + snippetClose + `', '…', 12) AS snippet
+		FROM users_fts JOIN users u ON u.id = users_fts.rowid
+		WHERE users_fts MATCH '` + profileNameColumns + ` : (' || ? || ')'
+		AND u.banned_at IS NULL AND u.deletion_scheduled_at IS NULL
+		AND NOT ` + publicProfileCondition + ` AND u.id != ?`, append(matchThenUser(1), matchThenUser(1)...)},
+	// Groups are listed for everyone; their titles count for more than their descriptions
+	SearchTypeGroup: {`SELECT 'groups' AS type, g.id AS id, bm25(groups_fts, 5.0, 1.0) AS rank,
+		snippet(groups_fts, -1, '` + snippetOpen + `', '` + snippetClose + `', '…', 12) AS snippet
+		FROM groups_fts JOIN groups g ON g.id = groups_fts.rowid
+		WHERE groups_fts MATCH ?`, matchThenUser(0)},
+}
+
+// searchMatchExpression turns what a user typed into an FTS5 query: every word must match,
+// and the last one may be the start of a word so results show up while typing.
+// Punctuation is dropped, so FTS5 syntax in the input is never interpreted.
+func searchMatchExpression(query string) string {
+	terms := strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(terms) > MaxSearchTerms {
+		terms = terms[:MaxSearchTerms]
+	}
+	if len(terms) == 0 {
+		return ""
+	}
+
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `"` + term + `"`
+	}
+	return strings.Join(quoted, " ") + "*"
+}
+
+// Search finds the posts, comments, group posts, people and groups matching a query that
+// the user is allowed to see, best matches first. types limits the kinds of result;
+// if empty, everything is searched. It also reports whether there are more results after this page.
+func Search(db *sql.DB, userId int, query string, types []string, page int, limit int) ([]SearchResult, bool, error) {
+	match := searchMatchExpression(query)
+	if match == "" {
+		return nil, false, ErrEmptySearch
+	}
+	if len(types) == 0 {
+		types = SearchTypes
+	}
+
+	// Search each kind of result together so the best matches of any kind come first
+	selects := []string{}
+	args := []interface{}{}
+	for _, searchType := range SearchTypes {
+		if !containsString(types, searchType) {
+			continue
+		}
+		q := searchQueries[searchType]
+		selects = append(selects, q.query)
+		for _, bind := range q.binds {
+			if bind == bindMatch {
+				args = append(args, match)
+			} else {
+				args = append(args, userId)
+			}
+		}
+	}
+	// Fetch one extra hit to tell whether there is another page
+	args = append(args, limit+1, (page-1)*limit)
+
+	rows, err := db.Query(
+		`SELECT type, id, snippet FROM (`+strings.Join(selects, " UNION ALL ")+`)
+		ORDER BY rank ASC, id DESC
+		LIMIT ? OFFSET ?`,
+		args...,
+	)
+	if err != nil {
+		return nil, false, err
+	}
+
+	results := []SearchResult{}
+	for rows.Next() {
+		var result SearchResult
+		var snippet sql.NullString
+		if err := rows.Scan(&result.Type, &result.ID, &snippet); err != nil {
+			rows.Close()
+			return nil, false, err
+		}
+		result.Snippet = highlightSnippet(snippet.String)
+		results = append(results, result)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(results) > limit
+	if hasMore {
+		results = results[:limit]
+	}
+
+	// Load what each hit matched. Anything deleted or hidden since is dropped.
+	hydrated := []SearchResult{}
+	for _, result := range results {
+		ok, err := loadSearchResult(db, userId, &result)
+		if err != nil {
+			return nil, false, err
+		}
+		if ok {
+			hydrated = append(hydrated, result)
+		}
+	}
+
+	return hydrated, hasMore, nil
+}
+
+// highlightSnippet HTML-escapes a snippet and marks its matching terms with <mark> tags
+func highlightSnippet(snippet string) string {
+	snippet = html.EscapeString(snippet)
+	snippet = strings.ReplaceAll(snippet, snippetOpen, "<mark>")
+	return strings.ReplaceAll(snippet, snippetClose, "</mark>")
+}
+
+// loadSearchResult fills in the content a hit matched, checking again that the user can see it.
+// It reports false if the content is gone or no longer visible.
+func loadSearchResult(db *sql.DB, userId int, result *SearchResult) (bool, error) {
+	switch result.Type {
+	case SearchTypePost:
+		post, err := GetPostById(db, result.ID, userId)
+		if err == ErrCannotViewPost {
+			return false, nil
+		}
+		if err != nil || post == nil {
+			return false, err
+		}
+		result.Post = post
+
+	case SearchTypeComment:
+		comment, err := GetCommentById(db, result.ID)
+		if err != nil || comment == nil {
+			return false, err
+		}
+		post, err := GetPostById(db, comment.PostID, userId)
+		if err == ErrCannotViewPost {
+			return false, nil
+		}
+		if err != nil || post == nil {
+			return false, err
+		}
+		result.Comment = comment
+
+	case SearchTypeGroupPost:
+		post := &Post{}
+		var user User
+		err := db.QueryRow(
+			`SELECT gp.id, gp.user_id, gp.content, gp.image_url, gp.created_at, gp.updated_at, gp.group_id,
+			u.id, u.first_name, u.last_name, u.avatar, u.nickname
+			FROM group_posts gp
+			JOIN users u ON gp.user_id = u.id
+			WHERE gp.id = ? AND gp.hidden_at IS NULL`,
+			result.ID,
+		).Scan(
+			&post.ID, &post.UserID, &post.Content, &post.ImageURL, &post.CreatedAt, &post.UpdatedAt, &result.GroupID,
+			&user.ID, &user.FirstName, &user.LastName, &user.Avatar, &user.Nickname,
+		)
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		isMember, err := IsGroupMember(db, result.GroupID, userId)
+		if err != nil || !isMember {
+			return false, err
+		}
+		post.Media, err = GetMedia(db, MediaTargetGroupPost, post.ID)
+		if err != nil {
+			return false, err
+		}
+		post.User = &user
+		result.GroupPost = post
+
+	case SearchTypeUser:
+		user, err := GetUserById(db, result.ID)
+		if err != nil || user == nil {
+			return false, err
+		}
+		// Only what's shown next to a name elsewhere, not the whole profile
+		result.User = &User{
+			ID:        user.ID,
+			FirstName: user.FirstName,
+			LastName:  user.LastName,
+			Nickname:  user.Nickname,
+			Avatar:    user.Avatar,
+			IsPublic:  user.IsPublic,
+		}
+
+	case SearchTypeGroup:
+		group, err := GetGroupById(db, result.ID)
+		if err != nil || group == nil {
+			return false, err
+		}
+		result.Group = group
+
+	default:
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// containsString reports whether a slice holds a string
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -2,36 +2,146 @@ package models
 
 import (
 	"database/sql"
+	"errors"
+	"log"
+	"os"
+	"sync"
 	"time"
+
+	"github.com/google/uuid"
 )
 
+// ErrSessionNotFound is returned when a session does not exist or belongs to another user
+var ErrSessionNotFound = errors.New("session not found")
+
 type Session struct {
-	ID        int       `json:"id"`
-	UserID    int       `json:"user_id"`
-	Token     string    `json:"token"`
-	CreatedAt time.Time `json:"created_at"`
-	ExpiresAt time.Time `json:"expires_at"`
+	ID            int       `json:"id"`
+	UserID        int       `json:"user_id"`
+	Token         string    `json:"-"`
+	UserAgent     string    `json:"user_agent"`
+	IPAddress     string    `json:"ip_address"`
+	CreatedAt     time.Time `json:"created_at"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	LastSeenAt    time.Time `json:"last_seen_at"`
+	TokenIssuedAt time.Time `json:"-"`
+	CSRFToken     string    `json:"-"`
+	Current       bool      `json:"current"`
+}
+
+// SessionPolicy controls how long sessions live and how often their tokens rotate
+type SessionPolicy struct {
+	// IdleTimeout is how long a session survives without any requests
+	IdleTimeout time.Duration
+	// AbsoluteTimeout is the maximum lifetime of a session regardless of activity
+	AbsoluteTimeout time.Duration
+	// RotationInterval is how often the session token is replaced while in use
+	RotationInterval time.Duration
+	// RotationGracePeriod is how long the previous token keeps working after a rotation,
+	// so that requests already in flight with the old cookie are not rejected
+	RotationGracePeriod time.Duration
+}
+
+// lastSeenResolution throttles last-seen writes so that every request does not hit the database
+const lastSeenResolution = time.Minute
+
+var (
+	sessionPolicy     SessionPolicy
+	sessionPolicyOnce sync.Once
+)
+
+// GetSessionPolicy returns the session policy, loading it from the environment on first use
+func GetSessionPolicy() SessionPolicy {
+	sessionPolicyOnce.Do(func() {
+		sessionPolicy = SessionPolicy{
+			IdleTimeout:         durationFromEnv("SESSION_IDLE_TIMEOUT", 7*24*time.Hour),
+			AbsoluteTimeout:     durationFromEnv("SESSION_ABSOLUTE_TIMEOUT", 30*24*time.Hour),
+			RotationInterval:    durationFromEnv("SESSION_ROTATION_INTERVAL", 15*time.Minute),
+			RotationGracePeriod: durationFromEnv("SESSION_ROTATION_GRACE_PERIOD", time.Minute),
+		}
+	})
+	return sessionPolicy
+}
+
+// durationFromEnv reads a duration such as "30m" or "72h" from the environment
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		log.Printf("Invalid duration for %s: %q, using default %s", key, value, fallback)
+		return fallback
+	}
+	return duration
+}
+
+// sessionExpiry returns when a session used at the given time should expire under the policy
+func sessionExpiry(policy SessionPolicy, createdAt time.Time, usedAt time.Time) time.Time {
+	expiresAt := usedAt.Add(policy.IdleTimeout)
+	absoluteExpiry := createdAt.Add(policy.AbsoluteTimeout)
+	if expiresAt.After(absoluteExpiry) {
+		return absoluteExpiry
+	}
+	return expiresAt
 }
 
 // CreateSession creates a new session for a user
-func CreateSession(db *sql.DB, userId int, token string) error {
-	// Set expiration to 7 days from now
-	expiresAt := time.Now().Add(7 * 24 * time.Hour)
+func CreateSession(db *sql.DB, userId int, token string, userAgent string, ipAddress string) error {
+	now := time.Now()
+	expiresAt := sessionExpiry(GetSessionPolicy(), now, now)
 
-	_, err := db.Exec(
-		`INSERT INTO sessions (user_id, token, expires_at) VALUES (?, ?, ?)`,
-		userId, token, expiresAt,
+	// The CSRF token lives as long as the session and does not change when the session token rotates
+	csrfToken, err := generateToken(32)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(
+		`INSERT INTO sessions (user_id, token, user_agent, ip_address, created_at, last_seen_at, token_issued_at, expires_at, csrf_token)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		userId, token, userAgent, ipAddress, now, now, now, expiresAt, csrfToken,
 	)
 	return err
 }
 
-// GetSessionByToken retrieves a session by token
+// scanSession scans a session row selected with sessionColumns
+func scanSession(row interface{ Scan(...interface{}) error }, session *Session) error {
+	var lastSeenAt, tokenIssuedAt sql.NullTime
+	err := row.Scan(
+		&session.ID, &session.UserID, &session.Token, &session.UserAgent, &session.IPAddress,
+		&session.CreatedAt, &session.ExpiresAt, &lastSeenAt, &tokenIssuedAt, &session.CSRFToken,
+	)
+	if err != nil {
+		return err
+	}
+
+	session.LastSeenAt = session.CreatedAt
+	if lastSeenAt.Valid {
+		session.LastSeenAt = lastSeenAt.Time
+	}
+	session.TokenIssuedAt = session.CreatedAt
+	if tokenIssuedAt.Valid {
+		session.TokenIssuedAt = tokenIssuedAt.Time
+	}
+	return nil
+}
+
+const sessionColumns = `id, user_id, token, COALESCE(user_agent, ''), COALESCE(ip_address, ''),
+	created_at, expires_at, last_seen_at, token_issued_at, COALESCE(csrf_token, '')`
+
+// GetSessionByToken retrieves a session by token.
+// A token that was rotated out recently is still accepted during the grace period;
+// the returned session always carries the current token.
 func GetSessionByToken(db *sql.DB, token string) (*Session, error) {
+	policy := GetSessionPolicy()
 	session := &Session{}
-	err := db.QueryRow(
-		`SELECT id, user_id, token, created_at, expires_at FROM sessions WHERE token = ?`,
-		token,
-	).Scan(&session.ID, &session.UserID, &session.Token, &session.CreatedAt, &session.ExpiresAt)
+	err := scanSession(db.QueryRow(
+		`SELECT `+sessionColumns+` FROM sessions
+		WHERE token = ? OR (previous_token = ? AND token_issued_at > ?)`,
+		token, token, time.Now().Add(-policy.RotationGracePeriod),
+	), session)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -39,8 +149,9 @@ func GetSessionByToken(db *sql.DB, token string) (*Session, error) {
 		return nil, err
 	}
 
-	// Check if session is expired
-	if session.ExpiresAt.Before(time.Now()) {
+	// Check if session is expired, either by inactivity or by its absolute lifetime
+	now := time.Now()
+	if session.ExpiresAt.Before(now) || session.CreatedAt.Add(policy.AbsoluteTimeout).Before(now) {
 		// Delete expired session
 		_, _ = db.Exec("DELETE FROM sessions WHERE id = ?", session.ID)
 		return nil, nil
@@ -49,14 +160,128 @@ func GetSessionByToken(db *sql.DB, token string) (*Session, error) {
 	return session, nil
 }
 
+// RefreshSession extends a session that is in use and rotates its token when it is due.
+// It returns true if the token was rotated, in which case session.Token holds the new token.
+func RefreshSession(db *sql.DB, session *Session) (bool, error) {
+	policy := GetSessionPolicy()
+	now := time.Now()
+	expiresAt := sessionExpiry(policy, session.CreatedAt, now)
+
+	// Rotate the token on schedule
+	if now.Sub(session.TokenIssuedAt) >= policy.RotationInterval {
+		newToken := uuid.New().String()
+		result, err := db.Exec(
+			`UPDATE sessions SET previous_token = token, token = ?, token_issued_at = ?, last_seen_at = ?, expires_at = ?
+			WHERE id = ? AND token = ?`,
+			newToken, now, now, expiresAt, session.ID, session.Token,
+		)
+		if err != nil {
+			return false, err
+		}
+
+		// Another request may have rotated the token first
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return false, err
+		}
+		if rowsAffected == 0 {
+			return false, nil
+		}
+
+		session.Token = newToken
+		session.TokenIssuedAt = now
+		session.LastSeenAt = now
+		session.ExpiresAt = expiresAt
+		return true, nil
+	}
+
+	// Otherwise slide the expiry forward
+	if now.Sub(session.LastSeenAt) >= lastSeenResolution {
+		_, err := db.Exec(
+			`UPDATE sessions SET last_seen_at = ?, expires_at = ? WHERE id = ?`,
+			now, expiresAt, session.ID,
+		)
+		if err != nil {
+			return false, err
+		}
+		session.LastSeenAt = now
+		session.ExpiresAt = expiresAt
+	}
+
+	return false, nil
+}
+
+// GetUserSessions retrieves all active sessions for a user
+func GetUserSessions(db *sql.DB, userId int) ([]Session, error) {
+	sessions := []Session{}
+
+	rows, err := db.Query(`
+		SELECT `+sessionColumns+`
+		FROM sessions
+		WHERE user_id = ? AND expires_at > ?
+		ORDER BY created_at DESC
+	`, userId, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var session Session
+		if err := scanSession(rows, &session); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
+
 // DeleteSession deletes a session by ID
 func DeleteSession(db *sql.DB, sessionId int) error {
 	_, err := db.Exec("DELETE FROM sessions WHERE id = ?", sessionId)
 	return err
 }
 
-// DeleteSessionByToken deletes a session by token
+// DeleteUserSession deletes one of a user's sessions by ID
+func DeleteUserSession(db *sql.DB, sessionId int, userId int) error {
+	result, err := db.Exec("DELETE FROM sessions WHERE id = ? AND user_id = ?", sessionId, userId)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+
+	return nil
+}
+
+// DeleteOtherSessions deletes all of a user's sessions except the one with the given token
+func DeleteOtherSessions(db *sql.DB, userId int, keepToken string) (int, error) {
+	result, err := db.Exec("DELETE FROM sessions WHERE user_id = ? AND token != ?", userId, keepToken)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(rowsAffected), nil
+}
+
+// DeleteSessionByToken deletes a session by its current token, or by its previous
+// token while that is still within the rotation grace period
 func DeleteSessionByToken(db *sql.DB, token string) error {
-	_, err := db.Exec("DELETE FROM sessions WHERE token = ?", token)
+	_, err := db.Exec(
+		"DELETE FROM sessions WHERE token = ? OR (previous_token = ? AND token_issued_at > ?)",
+		token, token, time.Now().Add(-GetSessionPolicy().RotationGracePeriod),
+	)
 	return err
 }
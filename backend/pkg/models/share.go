@@ -0,0 +1,249 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+var (
+	ErrCannotSharePost = errors.New("post can't be shared")
+	ErrAlreadyReposted = errors.New("post already reposted")
+)
+
+// Why a shared post is shown as a tombstone
+const (
+	TombstoneDeleted     = "deleted"
+	TombstoneUnavailable = "unavailable"
+)
+
+// PostTombstone stands in for a shared post that was deleted, or that the viewer can't see
+type PostTombstone struct {
+	ID     int    `json:"id"`
+	Reason string `json:"reason"`
+}
+
+// IsRepost reports whether a post only shares another post, with no text or attachments of its own.
+// Shares that add either are quote posts.
+func (p *Post) IsRepost() bool {
+	return p.SharedPostID != nil && strings.TrimSpace(p.Content) == "" && p.ImageURL == "" && len(p.Media) == 0
+}
+
+// resolveSharedPost checks that a user can share a post and returns the ID of the post to share.
+// Only published posts the user can see can be shared, and sharing a repost shares its original.
+// A user can repost a post once but quote it any number of times.
+func resolveSharedPost(db *sql.DB, sharedPostId int, userId int, repost bool) (int, error) {
+	for {
+		original, err := getPostForVisibility(db, sharedPostId)
+		if err != nil {
+			return 0, err
+		}
+		if original == nil || original.Hidden || original.Status != PostStatusPublished {
+			return 0, ErrCannotSharePost
+		}
+		canView, err := CanViewPost(db, original, userId)
+		if err != nil {
+			return 0, err
+		}
+		if !canView {
+			return 0, ErrCannotSharePost
+		}
+
+		// Follow reposts back to the post they share
+		var next sql.NullInt64
+		var content, imageURL string
+		err = db.QueryRow(
+			"SELECT shared_post_id, content, COALESCE(image_url, '') FROM posts WHERE id = ?",
+			sharedPostId,
+		).Scan(&next, &content, &imageURL)
+		if err != nil {
+			return 0, err
+		}
+		if !next.Valid || strings.TrimSpace(content) != "" || imageURL != "" {
+			break
+		}
+		sharedPostId = int(next.Int64)
+	}
+
+	if repost {
+		var reposted bool
+		err := db.QueryRow(
+			`SELECT EXISTS(
+				SELECT 1 FROM posts
+				WHERE user_id = ? AND shared_post_id = ? AND TRIM(content) = '' AND COALESCE(image_url, '') = ''
+			)`,
+			userId, sharedPostId,
+		).Scan(&reposted)
+		if err != nil {
+			return 0, err
+		}
+		if reposted {
+			return 0, ErrAlreadyReposted
+		}
+	}
+
+	return sharedPostId, nil
+}
+
+// loadPostShares fills in the share count of posts and, for shares, the post they share as the
+// viewer sees it: the post itself if they can see it, or a tombstone if it was deleted or they
+// can't. Shared posts are loaded one level deep, so a quote of a quote shows only the quote.
+func loadPostShares(db *sql.DB, posts []Post, viewerId int) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, len(posts))
+	index := map[int]int{}
+	for i := range posts {
+		args[i] = posts[i].ID
+		index[posts[i].ID] = i
+	}
+	rows, err := db.Query(
+		`SELECT id, shared_post_id, share_count FROM posts
+		WHERE id IN (?`+strings.Repeat(", ?", len(posts)-1)+`)`,
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var id, shareCount int
+		var sharedPostId sql.NullInt64
+		if err := rows.Scan(&id, &sharedPostId, &shareCount); err != nil {
+			rows.Close()
+			return err
+		}
+		posts[index[id]].ShareCount = shareCount
+		if sharedPostId.Valid {
+			sharedId := int(sharedPostId.Int64)
+			posts[index[id]].SharedPostID = &sharedId
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	type sharedView struct {
+		post      *Post
+		tombstone *PostTombstone
+	}
+	loaded := map[int]sharedView{}
+	for i := range posts {
+		if posts[i].SharedPostID == nil {
+			continue
+		}
+		sharedId := *posts[i].SharedPostID
+		view, ok := loaded[sharedId]
+		if !ok {
+			post, tombstone, err := getSharedPost(db, sharedId, viewerId)
+			if err != nil {
+				return err
+			}
+			view = sharedView{post: post, tombstone: tombstone}
+			loaded[sharedId] = view
+		}
+		posts[i].SharedPost = view.post
+		posts[i].SharedPostTombstone = view.tombstone
+	}
+
+	return nil
+}
+
+// getSharedPost loads a shared post if the viewer can see it, or a tombstone if not
+func getSharedPost(db *sql.DB, postId int, viewerId int) (*Post, *PostTombstone, error) {
+	post := &Post{}
+	var user User
+	var sharedPostId sql.NullInt64
+	err := db.QueryRow(
+		`SELECT p.id, p.user_id, p.content, p.image_url, p.privacy,
+		COALESCE(p.like_count, 0), COALESCE(p.dislike_count, 0), p.share_count,
+		p.created_at, p.updated_at, p.hidden_at IS NOT NULL, COALESCE(p.revision_count, 0), p.status,
+		p.shared_post_id,
+		u.id, u.first_name, u.last_name, u.avatar, u.nickname
+		FROM posts p
+		JOIN users u ON p.user_id = u.id
+		WHERE p.id = ?`,
+		postId,
+	).Scan(
+		&post.ID, &post.UserID, &post.Content, &post.ImageURL, &post.Privacy,
+		&post.LikeCount, &post.DislikeCount, &post.ShareCount,
+		&post.CreatedAt, &post.UpdatedAt, &post.Hidden, &post.RevisionCount, &post.Status,
+		&sharedPostId,
+		&user.ID, &user.FirstName, &user.LastName, &user.Avatar, &user.Nickname,
+	)
+	if err == sql.ErrNoRows {
+		return nil, &PostTombstone{ID: postId, Reason: TombstoneDeleted}, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	canView, err := CanViewPost(db, post, viewerId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !canView {
+		return nil, &PostTombstone{ID: postId, Reason: TombstoneUnavailable}, nil
+	}
+
+	post.Edited = post.RevisionCount > 0
+	if sharedPostId.Valid {
+		sharedId := int(sharedPostId.Int64)
+		post.SharedPostID = &sharedId
+	}
+	post.User = &user
+	post.Media, err = GetMedia(db, MediaTargetPost, post.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	posts := []Post{*post}
+	if err := loadPostPolls(db, PollTargetPost, posts, viewerId); err != nil {
+		return nil, nil, err
+	}
+	post.Poll = posts[0].Poll
+
+	return post, nil, nil
+}
+
+// RecordPostShare records a published share in its author's activity and tells the author of
+// the shared post, if they can see the share. Posts that don't share anything are ignored.
+func RecordPostShare(db *sql.DB, post *Post) error {
+	if post.SharedPostID == nil {
+		return nil
+	}
+
+	original, err := getPostForVisibility(db, *post.SharedPostID)
+	if err != nil || original == nil {
+		return err
+	}
+
+	if err := CreatePostShareActivity(db, post.UserID, post.ID, original.ID, post.Content, original.UserID); err != nil {
+		return err
+	}
+
+	if original.UserID == post.UserID {
+		return nil
+	}
+	share, err := getPostForVisibility(db, post.ID)
+	if err != nil || share == nil {
+		return err
+	}
+	canView, err := CanViewPost(db, share, original.UserID)
+	if err != nil || !canView {
+		return err
+	}
+
+	sharer, err := GetUserById(db, post.UserID)
+	if err != nil || sharer == nil {
+		return err
+	}
+	action := "quoted"
+	if post.IsRepost() {
+		action = "reposted"
+	}
+	message := sharer.FirstName + " " + sharer.LastName + " " + action + " your post"
+	_, err = CreateNotification(db, original.UserID, "post_shared", message, post.ID)
+	return err
+}
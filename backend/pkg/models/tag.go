@@ -0,0 +1,334 @@
+package models
+
+import (
+	"database/sql"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Kinds of content that hashtags and mentions are extracted from
+const (
+	TagTargetPost             = "post"
+	TagTargetComment          = "comment"
+	TagTargetGroupPost        = "group_post"
+	TagTargetGroupPostComment = "group_post_comment"
+)
+
+// MaxHashtagLength is the longest hashtag that is indexed, in characters
+const MaxHashtagLength = 50
+
+var (
+	// A hashtag is # followed by letters, digits or underscores, not preceded by a word
+	// character, so "C#" and "page#2" aren't hashtags
+	hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&#])#([\p{L}\p{N}_]+)`)
+	// A mention is @ followed by a nickname made of letters, digits and underscores,
+	// not preceded by a word character, so email addresses aren't mentions
+	mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@.])@([\p{L}\p{N}_]+)`)
+)
+
+// ExtractHashtags returns the distinct hashtags in a text, lowercased and without the #.
+// Tags made only of digits, like "#1", and overly long tags are ignored.
+func ExtractHashtags(content string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, match := range hashtagPattern.FindAllStringSubmatch(content, -1) {
+		tag := strings.ToLower(match[1])
+		if seen[tag] || len([]rune(tag)) > MaxHashtagLength || !strings.ContainsFunc(tag, unicode.IsLetter) {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// ExtractMentions returns the distinct nicknames mentioned in a text, without the @
+func ExtractMentions(content string) []string {
+	nicknames := []string{}
+	seen := map[string]bool{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		key := strings.ToLower(match[1])
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		nicknames = append(nicknames, match[1])
+	}
+	return nicknames
+}
+
+// indexContentTags brings the hashtags and mentions recorded for a piece of content in line
+// with its text. It must run in the transaction that creates or edits the content.
+// Mentions the content already had keep their notified state, so editing a post doesn't
+// notify the same people twice.
+func indexContentTags(tx *sql.Tx, kind string, id int, authorId int, content string) error {
+	_, err := tx.Exec("DELETE FROM content_hashtags WHERE content_type = ? AND content_id = ?", kind, id)
+	if err != nil {
+		return err
+	}
+	for _, tag := range ExtractHashtags(content) {
+		_, err := tx.Exec(
+			"INSERT INTO content_hashtags (content_type, content_id, tag) VALUES (?, ?, ?)",
+			kind, id, tag,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	mentioned, err := resolveMentions(tx, ExtractMentions(content), authorId)
+	if err != nil {
+		return err
+	}
+
+	// Drop mentions that were edited out
+	query := "DELETE FROM content_mentions WHERE content_type = ? AND content_id = ?"
+	args := []interface{}{kind, id}
+	if len(mentioned) > 0 {
+		query += " AND user_id NOT IN (?" + strings.Repeat(", ?", len(mentioned)-1) + ")"
+		for _, userId := range mentioned {
+			args = append(args, userId)
+		}
+	}
+	if _, err := tx.Exec(query, args...); err != nil {
+		return err
+	}
+
+	for _, userId := range mentioned {
+		_, err := tx.Exec(
+			`INSERT INTO content_mentions (content_type, content_id, user_id) VALUES (?, ?, ?)
+			ON CONFLICT(content_type, content_id, user_id) DO NOTHING`,
+			kind, id, userId,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resolveMentions looks up the users behind mentioned nicknames, ignoring case.
+// Nicknames aren't unique, so one shared by several users doesn't mention anyone,
+// and authors mentioning themselves are skipped.
+func resolveMentions(tx *sql.Tx, nicknames []string, authorId int) ([]int, error) {
+	userIds := []int{}
+	for _, nickname := range nicknames {
+		rows, err := tx.Query("SELECT id FROM users WHERE nickname = ? COLLATE NOCASE LIMIT 2", nickname)
+		if err != nil {
+			return nil, err
+		}
+		matches := []int{}
+		for rows.Next() {
+			var userId int
+			if err := rows.Scan(&userId); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			matches = append(matches, userId)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		if len(matches) == 1 && matches[0] != authorId {
+			userIds = append(userIds, matches[0])
+		}
+	}
+	return userIds, nil
+}
+
+// NotifyMentions sends a mention notification to each user mentioned in a piece of content
+// who hasn't been notified yet and can now see it. Users who can't see it yet, such as
+// everyone mentioned in a scheduled post, are notified by a later call once they can.
+func NotifyMentions(db *sql.DB, kind string, id int) error {
+	// Work out who wrote the content and where it lives
+	var authorId int
+	var postId, groupId sql.NullInt64
+	var err error
+	switch kind {
+	case TagTargetPost:
+		err = db.QueryRow("SELECT user_id, id FROM posts WHERE id = ?", id).Scan(&authorId, &postId)
+	case TagTargetComment:
+		err = db.QueryRow(
+			"SELECT user_id, post_id FROM comments WHERE id = ? AND hidden_at IS NULL", id,
+		).Scan(&authorId, &postId)
+	case TagTargetGroupPost:
+		err = db.QueryRow(
+			"SELECT user_id, group_id FROM group_posts WHERE id = ? AND hidden_at IS NULL", id,
+		).Scan(&authorId, &groupId)
+	case TagTargetGroupPostComment:
+		err = db.QueryRow(
+			`SELECT c.user_id, gp.group_id FROM group_post_comments c
+			JOIN group_posts gp ON c.group_post_id = gp.id
+			WHERE c.id = ? AND gp.hidden_at IS NULL`, id,
+		).Scan(&authorId, &groupId)
+	}
+	if err != nil {
+		// Content hidden or deleted in the meantime has no one left to notify
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+
+	rows, err := db.Query(
+		"SELECT user_id FROM content_mentions WHERE content_type = ? AND content_id = ? AND notified_at IS NULL",
+		kind, id,
+	)
+	if err != nil {
+		return err
+	}
+	pending := []int{}
+	for rows.Next() {
+		var userId int
+		if err := rows.Scan(&userId); err != nil {
+			rows.Close()
+			return err
+		}
+		pending = append(pending, userId)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	author, err := GetUserById(db, authorId)
+	if err != nil {
+		return err
+	}
+	if author == nil {
+		return nil
+	}
+
+	var post *Post
+	if postId.Valid {
+		post, err = getPostForVisibility(db, int(postId.Int64))
+		if err != nil || post == nil {
+			return err
+		}
+	}
+
+	for _, userId := range pending {
+		var canView bool
+		if post != nil {
+			canView, err = CanViewPost(db, post, userId)
+		} else {
+			canView, err = IsGroupMember(db, int(groupId.Int64), userId)
+		}
+		if err != nil {
+			return err
+		}
+		if !canView {
+			continue
+		}
+
+		message := author.FirstName + " " + author.LastName + " mentioned you in " + mentionContext[kind]
+		if _, err := CreateNotification(db, userId, "mention", message, id); err != nil {
+			return err
+		}
+		_, err = db.Exec(
+			`UPDATE content_mentions SET notified_at = CURRENT_TIMESTAMP
+			WHERE content_type = ? AND content_id = ? AND user_id = ?`,
+			kind, id, userId,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mentionContext describes each kind of content in mention notifications
+var mentionContext = map[string]string{
+	TagTargetPost:             "a post",
+	TagTargetComment:          "a comment",
+	TagTargetGroupPost:        "a group post",
+	TagTargetGroupPostComment: "a group comment",
+}
+
+// getPostForVisibility loads the fields of a post that CanViewPost looks at
+func getPostForVisibility(db *sql.DB, postId int) (*Post, error) {
+	post := &Post{}
+	err := db.QueryRow(
+		"SELECT id, user_id, privacy, hidden_at IS NOT NULL, status FROM posts WHERE id = ?",
+		postId,
+	).Scan(&post.ID, &post.UserID, &post.Privacy, &post.Hidden, &post.Status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return post, nil
+}
+
+// GetHashtagPosts retrieves published posts tagged with a hashtag that the user can see, newest first
+func GetHashtagPosts(db *sql.DB, tag string, userId int, page, limit int) ([]Post, error) {
+	offset := (page - 1) * limit
+	posts := []Post{}
+
+	rows, err := db.Query(
+		`SELECT p.id, p.user_id, p.content, p.image_url, p.privacy,
+		COALESCE(p.like_count, 0) as like_count, COALESCE(p.dislike_count, 0) as dislike_count,
+		p.created_at, p.updated_at, COALESCE(p.revision_count, 0), p.status,
+		u.id as user_id, u.email, u.first_name, u.last_name, u.avatar, u.nickname
+		FROM content_hashtags h
+		JOIN posts p ON h.content_type = 'post' AND h.content_id = p.id
+		JOIN users u ON p.user_id = u.id
+		WHERE h.tag = ? AND p.hidden_at IS NULL AND p.status = 'published'
+		AND `+visiblePostCondition+`
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT ? OFFSET ?`,
+		strings.ToLower(tag), userId, userId, userId, userId, limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var post Post
+		var user User
+		err := rows.Scan(
+			&post.ID, &post.UserID, &post.Content, &post.ImageURL, &post.Privacy,
+			&post.LikeCount, &post.DislikeCount, &post.CreatedAt, &post.UpdatedAt, &post.RevisionCount,
+			&post.Status,
+			&user.ID, &user.Email, &user.FirstName, &user.LastName, &user.Avatar, &user.Nickname,
+		)
+		if err != nil {
+			return nil, err
+		}
+		post.Edited = post.RevisionCount > 0
+
+		post.User = &user
+		posts = append(posts, post)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Get attachments
+	if err := loadPostMedia(db, MediaTargetPost, posts); err != nil {
+		return nil, err
+	}
+
+	// Get the posts they share
+	if err := loadPostShares(db, posts, userId); err != nil {
+		return nil, err
+	}
+
+	// Get polls
+	if err := loadPostPolls(db, PollTargetPost, posts, userId); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
@@ -0,0 +1,319 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/hezronokwach/soshi/pkg/totp"
+)
+
+// TwoFactorIssuer is the name authenticator apps show next to the account
+const TwoFactorIssuer = "Soshi"
+
+// recoveryCodeCount is how many recovery codes are issued at a time
+const recoveryCodeCount = 10
+
+// maxPendingLoginAttempts is how many wrong codes a pending login tolerates before it is discarded
+const maxPendingLoginAttempts = 5
+
+var (
+	// ErrTwoFactorNotSetUp is returned when enabling two-factor authentication before a secret was generated
+	ErrTwoFactorNotSetUp = errors.New("two-factor authentication has not been set up")
+	// ErrInvalidTwoFactorCode is returned when a TOTP or recovery code does not match
+	ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")
+	// ErrInvalidPendingLogin is returned when a pending login token is unknown, expired or exhausted
+	ErrInvalidPendingLogin = errors.New("invalid or expired login challenge")
+)
+
+// TwoFactor holds a user's TOTP enrollment
+type TwoFactor struct {
+	UserID       int
+	Secret       string
+	EnabledAt    *time.Time
+	LastUsedStep int64
+}
+
+// GetTwoFactor retrieves a user's TOTP enrollment, or nil if they never started one
+func GetTwoFactor(db *sql.DB, userId int) (*TwoFactor, error) {
+	twoFactor := &TwoFactor{UserID: userId}
+	var enabledAt sql.NullTime
+	err := db.QueryRow(
+		"SELECT secret, enabled_at, last_used_step FROM user_totp WHERE user_id = ?",
+		userId,
+	).Scan(&twoFactor.Secret, &enabledAt, &twoFactor.LastUsedStep)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if enabledAt.Valid {
+		twoFactor.EnabledAt = &enabledAt.Time
+	}
+	return twoFactor, nil
+}
+
+// IsTwoFactorEnabled checks if a user has confirmed TOTP enrollment
+func IsTwoFactorEnabled(db *sql.DB, userId int) (bool, error) {
+	var enabled bool
+	err := db.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM user_totp WHERE user_id = ? AND enabled_at IS NOT NULL)",
+		userId,
+	).Scan(&enabled)
+	return enabled, err
+}
+
+// SetupTwoFactor generates a new TOTP secret for a user and returns it.
+// The secret is not enforced until it is confirmed with EnableTwoFactor.
+func SetupTwoFactor(db *sql.DB, userId int) (string, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = db.Exec(
+		`INSERT INTO user_totp (user_id, secret) VALUES (?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET secret = excluded.secret, enabled_at = NULL, last_used_step = 0`,
+		userId, secret,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return secret, nil
+}
+
+// EnableTwoFactor confirms a pending TOTP enrollment with a code from the user's app
+// and returns a fresh set of recovery codes
+func EnableTwoFactor(db *sql.DB, userId int, code string) ([]string, error) {
+	twoFactor, err := GetTwoFactor(db, userId)
+	if err != nil {
+		return nil, err
+	}
+	if twoFactor == nil {
+		return nil, ErrTwoFactorNotSetUp
+	}
+
+	step, ok := totp.Validate(twoFactor.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"UPDATE user_totp SET enabled_at = ?, last_used_step = ? WHERE user_id = ?",
+		time.Now(), step, userId,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := replaceRecoveryCodes(tx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// DisableTwoFactor removes a user's TOTP enrollment and recovery codes
+func DisableTwoFactor(db *sql.DB, userId int) error {
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM user_totp WHERE user_id = ?", userId); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM totp_recovery_codes WHERE user_id = ?", userId); err != nil {
+		return err
+	}
+
+	// Commit transaction
+	return tx.Commit()
+}
+
+// RegenerateRecoveryCodes replaces a user's recovery codes and returns the new ones
+func RegenerateRecoveryCodes(db *sql.DB, userId int) ([]string, error) {
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	codes, err := replaceRecoveryCodes(tx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// CountRecoveryCodes returns how many unused recovery codes a user has left
+func CountRecoveryCodes(db *sql.DB, userId int) (int, error) {
+	var count int
+	err := db.QueryRow(
+		"SELECT COUNT(*) FROM totp_recovery_codes WHERE user_id = ? AND used_at IS NULL",
+		userId,
+	).Scan(&count)
+	return count, err
+}
+
+// replaceRecoveryCodes deletes a user's recovery codes and stores new ones, returning them in plain text
+func replaceRecoveryCodes(tx *sql.Tx, userId int) ([]string, error) {
+	if _, err := tx.Exec("DELETE FROM totp_recovery_codes WHERE user_id = ?", userId); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw, err := generateToken(5)
+		if err != nil {
+			return nil, err
+		}
+		code := raw[:5] + "-" + raw[5:]
+
+		_, err = tx.Exec(
+			"INSERT INTO totp_recovery_codes (user_id, code_hash) VALUES (?, ?)",
+			userId, hashToken(code),
+		)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
+
+// VerifyTwoFactorCode checks a TOTP code for a user with two-factor enabled.
+// Each code is accepted only once, so an intercepted code cannot be replayed.
+func VerifyTwoFactorCode(db *sql.DB, userId int, code string) error {
+	twoFactor, err := GetTwoFactor(db, userId)
+	if err != nil {
+		return err
+	}
+	if twoFactor == nil || twoFactor.EnabledAt == nil {
+		return ErrTwoFactorNotSetUp
+	}
+
+	step, ok := totp.Validate(twoFactor.Secret, code, time.Now())
+	if !ok || step <= twoFactor.LastUsedStep {
+		return ErrInvalidTwoFactorCode
+	}
+
+	// Record the step, guarding against a concurrent request using the same code
+	result, err := db.Exec(
+		"UPDATE user_totp SET last_used_step = ? WHERE user_id = ? AND last_used_step < ?",
+		step, userId, step,
+	)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrInvalidTwoFactorCode
+	}
+
+	return nil
+}
+
+// UseRecoveryCode consumes one of a user's recovery codes
+func UseRecoveryCode(db *sql.DB, userId int, code string) error {
+	code = strings.ToLower(strings.TrimSpace(code))
+
+	result, err := db.Exec(
+		`UPDATE totp_recovery_codes SET used_at = ?
+		WHERE user_id = ? AND code_hash = ? AND used_at IS NULL`,
+		time.Now(), userId, hashToken(code),
+	)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrInvalidTwoFactorCode
+	}
+
+	return nil
+}
+
+// CreatePendingLogin records that a user passed the password check and still owes a second factor.
+// It returns the token the client must present along with the code.
+func CreatePendingLogin(db *sql.DB, userId int) (string, error) {
+	token, err := generateToken(32)
+	if err != nil {
+		return "", err
+	}
+	expiresAt := time.Now().Add(durationFromEnv("TWO_FACTOR_LOGIN_TTL", 5*time.Minute))
+
+	// Clear out expired challenges while we're here
+	_, _ = db.Exec("DELETE FROM pending_logins WHERE expires_at <= ?", time.Now())
+
+	_, err = db.Exec(
+		"INSERT INTO pending_logins (user_id, token_hash, expires_at) VALUES (?, ?, ?)",
+		userId, hashToken(token), expiresAt,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// GetPendingLogin returns the user a pending login token belongs to
+func GetPendingLogin(db *sql.DB, token string) (int, error) {
+	var userId int
+	err := db.QueryRow(
+		`SELECT user_id FROM pending_logins
+		WHERE token_hash = ? AND expires_at > ? AND attempts < ?`,
+		hashToken(token), time.Now(), maxPendingLoginAttempts,
+	).Scan(&userId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrInvalidPendingLogin
+		}
+		return 0, err
+	}
+	return userId, nil
+}
+
+// RecordPendingLoginFailure counts a wrong code against a pending login
+func RecordPendingLoginFailure(db *sql.DB, token string) error {
+	_, err := db.Exec("UPDATE pending_logins SET attempts = attempts + 1 WHERE token_hash = ?", hashToken(token))
+	return err
+}
+
+// DeletePendingLogin removes a pending login once it has been completed
+func DeletePendingLogin(db *sql.DB, token string) error {
+	_, err := db.Exec("DELETE FROM pending_logins WHERE token_hash = ?", hashToken(token))
+	return err
+}
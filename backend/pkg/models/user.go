@@ -8,6 +8,9 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// ErrEmailInUse is returned when an email address already belongs to another account
+var ErrEmailInUse = errors.New("user with this email already exists")
+
 type User struct {
 	ID          int       `json:"id"`
 	Email       string    `json:"email"`
@@ -21,6 +24,12 @@ type User struct {
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 	IsPublic    bool      `json:"is_public"`
+	Role        Role      `json:"role"`
+
+	EmailVerifiedAt     *time.Time `json:"email_verified_at,omitempty"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"`
+	SuspendedUntil      *time.Time `json:"suspended_until,omitempty"`
+	BannedAt            *time.Time `json:"banned_at,omitempty"`
 }
 
 // CreateUser creates a new user in the database
@@ -32,7 +41,7 @@ func CreateUser(db *sql.DB, user User) (int, error) {
 		return 0, err
 	}
 	if exists {
-		return 0, errors.New("user with this email already exists")
+		return 0, ErrEmailInUse
 	}
 
 	// Hash password
@@ -84,9 +93,11 @@ func CreateUser(db *sql.DB, user User) (int, error) {
 // GetUserByEmail retrieves a user by email
 func GetUserByEmail(db *sql.DB, email string) (*User, error) {
 	user := &User{}
+	var emailVerifiedAt, deletionScheduledAt, suspendedUntil, bannedAt sql.NullTime
 	err := db.QueryRow(
 		`SELECT u.id, u.email, u.password, u.first_name, u.last_name, u.date_of_birth, 
-		u.avatar, u.nickname, u.about_me, u.created_at, u.updated_at, COALESCE(p.is_public, 1) as is_public
+		u.avatar, u.nickname, u.about_me, u.created_at, u.updated_at, COALESCE(p.is_public, 1) as is_public,
+		u.email_verified_at, u.deletion_scheduled_at, u.role, u.suspended_until, u.banned_at
 		FROM users u
 		LEFT JOIN user_profiles p ON u.id = p.user_id
 		WHERE u.email = ?`,
@@ -94,6 +105,7 @@ func GetUserByEmail(db *sql.DB, email string) (*User, error) {
 	).Scan(
 		&user.ID, &user.Email, &user.Password, &user.FirstName, &user.LastName, &user.DateOfBirth,
 		&user.Avatar, &user.Nickname, &user.AboutMe, &user.CreatedAt, &user.UpdatedAt, &user.IsPublic,
+		&emailVerifiedAt, &deletionScheduledAt, &user.Role, &suspendedUntil, &bannedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -101,15 +113,29 @@ func GetUserByEmail(db *sql.DB, email string) (*User, error) {
 		}
 		return nil, err
 	}
+	if emailVerifiedAt.Valid {
+		user.EmailVerifiedAt = &emailVerifiedAt.Time
+	}
+	if deletionScheduledAt.Valid {
+		user.DeletionScheduledAt = &deletionScheduledAt.Time
+	}
+	if suspendedUntil.Valid {
+		user.SuspendedUntil = &suspendedUntil.Time
+	}
+	if bannedAt.Valid {
+		user.BannedAt = &bannedAt.Time
+	}
 	return user, nil
 }
 
 // GetUserById retrieves a user by ID
 func GetUserById(db *sql.DB, id int) (*User, error) {
 	user := &User{}
+	var emailVerifiedAt, deletionScheduledAt, suspendedUntil, bannedAt sql.NullTime
 	err := db.QueryRow(
 		`SELECT u.id, u.email, u.first_name, u.last_name, u.date_of_birth, 
-		u.avatar, u.nickname, u.about_me, u.created_at, u.updated_at, COALESCE(p.is_public, 1) as is_public
+		u.avatar, u.nickname, u.about_me, u.created_at, u.updated_at, COALESCE(p.is_public, 1) as is_public,
+		u.email_verified_at, u.deletion_scheduled_at, u.role, u.suspended_until, u.banned_at
 		FROM users u
 		LEFT JOIN user_profiles p ON u.id = p.user_id
 		WHERE u.id = ?`,
@@ -117,6 +143,7 @@ func GetUserById(db *sql.DB, id int) (*User, error) {
 	).Scan(
 		&user.ID, &user.Email, &user.FirstName, &user.LastName, &user.DateOfBirth,
 		&user.Avatar, &user.Nickname, &user.AboutMe, &user.CreatedAt, &user.UpdatedAt, &user.IsPublic,
+		&emailVerifiedAt, &deletionScheduledAt, &user.Role, &suspendedUntil, &bannedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -124,6 +151,18 @@ func GetUserById(db *sql.DB, id int) (*User, error) {
 		}
 		return nil, err
 	}
+	if emailVerifiedAt.Valid {
+		user.EmailVerifiedAt = &emailVerifiedAt.Time
+	}
+	if deletionScheduledAt.Valid {
+		user.DeletionScheduledAt = &deletionScheduledAt.Time
+	}
+	if suspendedUntil.Valid {
+		user.SuspendedUntil = &suspendedUntil.Time
+	}
+	if bannedAt.Valid {
+		user.BannedAt = &bannedAt.Time
+	}
 	return user, nil
 }
 
@@ -355,3 +394,54 @@ func GetAllUsers(db *sql.DB, excludeUserID int) ([]map[string]interface{}, error
 
 	return users, nil
 }
+
+// IsEmailInUse checks if an email address belongs to a user other than the given one
+func IsEmailInUse(db *sql.DB, email string, exceptUserId int) (bool, error) {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE email = ? AND id != ?)", email, exceptUserId).Scan(&exists)
+	return exists, err
+}
+
+// ChangePassword sets a new password for a user and signs out every session except the one
+// with the given token. Personal access tokens are revoked, and outstanding reset links and
+// login challenges are invalidated too.
+func ChangePassword(db *sql.DB, userId int, newPassword string, keepToken string) error {
+	// Hash password
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	// Begin transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Update password
+	_, err = tx.Exec(
+		"UPDATE users SET password = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		string(hashedPassword), userId,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Sign out other sessions
+	if _, err := tx.Exec("DELETE FROM sessions WHERE user_id = ? AND token != ?", userId, keepToken); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM api_tokens WHERE user_id = ?", userId); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM pending_logins WHERE user_id = ?", userId); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM password_resets WHERE user_id = ? AND used_at IS NULL", userId); err != nil {
+		return err
+	}
+
+	// Commit transaction
+	return tx.Commit()
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"net/http"
+	"time"
+)
+
+// SessionCookieName is the name of the cookie carrying the session token
+const SessionCookieName = "session_token"
+
+// SetSessionCookie sets the session cookie on a response
+func SetSessionCookie(w http.ResponseWriter, token string, expiresAt time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   false,
+	})
+}
+
+// ClearSessionCookie expires the session cookie on a response
+func ClearSessionCookie(w http.ResponseWriter) {
+	SetSessionCookie(w, "", time.Now().Add(-time.Hour))
+}
+
+// OIDCStateCookieName is the name of the cookie tying an OpenID login to the browser that started it
+const OIDCStateCookieName = "oidc_state"
+
+// oidcCookiePath limits the state cookie to the OpenID login endpoints
+const oidcCookiePath = "/api/auth/oidc"
+
+// SetOIDCStateCookie sets the cookie holding an OpenID login's state on a response
+func SetOIDCStateCookie(w http.ResponseWriter, state string, expiresAt time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     OIDCStateCookieName,
+		Value:    state,
+		Path:     oidcCookiePath,
+		Expires:  expiresAt,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   false,
+	})
+}
+
+// ClearOIDCStateCookie expires the OpenID state cookie on a response
+func ClearOIDCStateCookie(w http.ResponseWriter) {
+	SetOIDCStateCookie(w, "", time.Now().Add(-time.Hour))
+}
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	trustedProxies     []*net.IPNet
+	trustedProxiesOnce sync.Once
+)
+
+// getTrustedProxies returns the reverse proxies whose forwarding headers are believed,
+// loading them on first use from TRUSTED_PROXIES, a comma-separated list of IPs or CIDR ranges
+func getTrustedProxies() []*net.IPNet {
+	trustedProxiesOnce.Do(func() {
+		for _, entry := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+
+			// A bare address is a range containing only itself
+			if !strings.Contains(entry, "/") {
+				if ip := net.ParseIP(entry); ip != nil {
+					if ip.To4() != nil {
+						entry += "/32"
+					} else {
+						entry += "/128"
+					}
+				}
+			}
+
+			_, network, err := net.ParseCIDR(entry)
+			if err != nil {
+				log.Printf("Invalid trusted proxy %q, ignoring it", entry)
+				continue
+			}
+			trustedProxies = append(trustedProxies, network)
+		}
+	})
+	return trustedProxies
+}
+
+// isTrustedProxy checks if an address belongs to a configured reverse proxy
+func isTrustedProxy(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, network := range getTrustedProxies() {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the client's IP address for a request.
+// Forwarding headers are only believed when the request comes from a trusted proxy,
+// since anyone else can set them to whatever they like.
+func ClientIP(r *http.Request) string {
+	remoteIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remoteIP = r.RemoteAddr
+	}
+	if !isTrustedProxy(remoteIP) {
+		return remoteIP
+	}
+
+	// Each proxy appends the address it received the request from, so walk back
+	// from the end and take the first address that isn't one of our proxies
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		addresses := strings.Split(forwarded, ",")
+		for i := len(addresses) - 1; i >= 0; i-- {
+			address := strings.TrimSpace(addresses[i])
+			if address != "" && !isTrustedProxy(address) {
+				return address
+			}
+		}
+	}
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+		return realIP
+	}
+
+	return remoteIP
+}
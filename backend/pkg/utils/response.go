@@ -17,3 +17,11 @@ func RespondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.WriteHeader(code)
 	w.Write(response)
 }
+
+// RespondWithValidationErrors sends a 400 response listing the problem with each invalid field
+func RespondWithValidationErrors(w http.ResponseWriter, fields map[string]string) {
+	RespondWithJSON(w, http.StatusBadRequest, map[string]interface{}{
+		"error":  "Validation failed",
+		"fields": fields,
+	})
+}
@@ -0,0 +1,201 @@
+// Package validation checks user input and collects problems per field, so a client
+// can show every error next to the field it belongs to in one round trip.
+package validation
+
+import (
+	"log"
+	"net/mail"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DateLayout is the format dates of birth are accepted and stored in
+const DateLayout = "2006-01-02"
+
+// Length limits on profile fields, in characters
+const (
+	MaxEmailLength    = 254
+	MaxNameLength     = 50
+	MaxNicknameLength = 30
+	MaxAboutMeLength  = 500
+)
+
+// Validator collects field errors. Only the first error for each field is kept.
+type Validator struct {
+	Errors map[string]string
+}
+
+// New returns an empty Validator
+func New() *Validator {
+	return &Validator{Errors: map[string]string{}}
+}
+
+// Valid reports whether no errors were recorded
+func (v *Validator) Valid() bool {
+	return len(v.Errors) == 0
+}
+
+// AddError records an error for a field unless it already has one
+func (v *Validator) AddError(field string, message string) {
+	if _, exists := v.Errors[field]; !exists {
+		v.Errors[field] = message
+	}
+}
+
+// Check records an error for a field when ok is false
+func (v *Validator) Check(ok bool, field string, message string) {
+	if !ok {
+		v.AddError(field, message)
+	}
+}
+
+// Required checks that a field isn't blank
+func (v *Validator) Required(field string, value string) {
+	v.Check(strings.TrimSpace(value) != "", field, "This field is required")
+}
+
+// MaxLength checks that a field is at most max characters long
+func (v *Validator) MaxLength(field string, value string, max int) {
+	v.Check(utf8.RuneCountInString(value) <= max, field, "Must be at most "+strconv.Itoa(max)+" characters")
+}
+
+// Email checks that a field holds a single bare email address such as name@example.com
+func (v *Validator) Email(field string, value string) {
+	if value == "" {
+		v.Required(field, value)
+		return
+	}
+	v.MaxLength(field, value, MaxEmailLength)
+
+	// ParseAddress also accepts forms like "Name <name@example.com>", which we don't want stored
+	address, err := mail.ParseAddress(value)
+	valid := err == nil && address.Address == value
+	if valid {
+		at := strings.LastIndex(value, "@")
+		domain := value[at+1:]
+		valid = strings.Contains(domain, ".") && !strings.HasPrefix(domain, ".") && !strings.HasSuffix(domain, ".")
+	}
+	v.Check(valid, field, "Must be a valid email address")
+}
+
+// Password checks a new password against the password policy
+func (v *Validator) Password(field string, value string, policy PasswordPolicy) {
+	if value == "" {
+		v.Required(field, value)
+		return
+	}
+	if problem := policy.Check(value); problem != "" {
+		v.AddError(field, problem)
+	}
+}
+
+// DateOfBirth checks that a field is a YYYY-MM-DD date in the past and that the person
+// is at least minAge years old. It returns the parsed date, or the zero time if invalid.
+func (v *Validator) DateOfBirth(field string, value string, minAge int) time.Time {
+	if value == "" {
+		v.Required(field, value)
+		return time.Time{}
+	}
+
+	dob, err := time.Parse(DateLayout, value)
+	if err != nil {
+		v.AddError(field, "Must be a date in the format YYYY-MM-DD")
+		return time.Time{}
+	}
+
+	now := time.Now().UTC()
+	switch {
+	case dob.After(now):
+		v.AddError(field, "Must be in the past")
+	case dob.Before(now.AddDate(-150, 0, 0)):
+		v.AddError(field, "Must be a real date of birth")
+	case dob.After(now.AddDate(-minAge, 0, 0)):
+		v.AddError(field, "You must be at least "+strconv.Itoa(minAge)+" years old")
+	default:
+		return dob
+	}
+	return time.Time{}
+}
+
+// PasswordPolicy describes what makes a password strong enough
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+}
+
+// Check returns a description of the first rule a password breaks, or "" if it is acceptable
+func (p PasswordPolicy) Check(password string) string {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return "Must be at least " + strconv.Itoa(p.MinLength) + " characters"
+	}
+	// bcrypt ignores everything past 72 bytes
+	if len(password) > 72 {
+		return "Must be at most 72 bytes"
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, c := range password {
+		switch {
+		case unicode.IsUpper(c):
+			hasUpper = true
+		case unicode.IsLower(c):
+			hasLower = true
+		case unicode.IsDigit(c):
+			hasDigit = true
+		case unicode.IsPunct(c) || unicode.IsSymbol(c) || unicode.IsSpace(c):
+			hasSymbol = true
+		}
+	}
+
+	switch {
+	case p.RequireUpper && !hasUpper:
+		return "Must contain an uppercase letter"
+	case p.RequireLower && !hasLower:
+		return "Must contain a lowercase letter"
+	case p.RequireDigit && !hasDigit:
+		return "Must contain a digit"
+	case p.RequireSymbol && !hasSymbol:
+		return "Must contain a symbol"
+	}
+	return ""
+}
+
+// PasswordPolicyFromEnv reads the password policy from the environment.
+// PASSWORD_MIN_LENGTH defaults to 8; PASSWORD_REQUIRE_UPPER, PASSWORD_REQUIRE_LOWER,
+// PASSWORD_REQUIRE_DIGIT and PASSWORD_REQUIRE_SYMBOL are off unless set to "true".
+func PasswordPolicyFromEnv() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:     intFromEnv("PASSWORD_MIN_LENGTH", 8),
+		RequireUpper:  os.Getenv("PASSWORD_REQUIRE_UPPER") == "true",
+		RequireLower:  os.Getenv("PASSWORD_REQUIRE_LOWER") == "true",
+		RequireDigit:  os.Getenv("PASSWORD_REQUIRE_DIGIT") == "true",
+		RequireSymbol: os.Getenv("PASSWORD_REQUIRE_SYMBOL") == "true",
+	}
+}
+
+// MinimumAgeFromEnv returns how old users must be to have an account, from MINIMUM_AGE (default 13)
+func MinimumAgeFromEnv() int {
+	return intFromEnv("MINIMUM_AGE", 13)
+}
+
+// intFromEnv reads a positive integer from the environment, falling back on a default
+func intFromEnv(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Printf("Invalid integer for %s: %q, using default %d", key, value, fallback)
+		return fallback
+	}
+	return n
+}
@@ -22,6 +22,19 @@ type Hub struct {
 	// Unregister requests from clients
 	Unregister chan *Client
 
+	// Users whose connections must all be closed
+	disconnect chan int
+
+	// Poll results to push to everyone who can see the poll, worked through by
+	// runPollResults so that permission checks don't hold up the hub
+	pollResults chan *models.Poll
+
+	// Requests for the IDs of the connected users
+	onlineUsers chan chan []int
+
+	// Messages for a list of users
+	userMessages chan userMessage
+
 	// User ID to clients mapping for targeted messaging
 	userClients map[int][]*Client
 
@@ -29,6 +42,15 @@ type Hub struct {
 	db *sql.DB
 }
 
+// userMessage is a message for every connection of the listed users
+type userMessage struct {
+	userIDs []int
+	message []byte
+}
+
+// pollResultsBacklog is how many poll results can wait for their permission checks
+const pollResultsBacklog = 64
+
 // GetOnlineUserIDs returns a slice of user IDs that are currently connected
 func (h *Hub) GetOnlineUserIDs() []int {
 	var userIDs []int
@@ -60,14 +82,84 @@ func (h *Hub) SendMessageToUser(userID int, message []byte) {
 	}
 }
 
+// DisconnectUser closes all of a user's connections, e.g. when they are suspended
+func (h *Hub) DisconnectUser(userID int) {
+	h.disconnect <- userID
+}
+
+// BroadcastPollResults pushes a poll's results to the connected users who can see it.
+// Results come from the server only; clients can't send them through the broadcast channel.
+// It doesn't wait for the results to be delivered, and drops them if too many are queued,
+// since the next vote sends newer results anyway.
+func (h *Hub) BroadcastPollResults(poll *models.Poll) {
+	select {
+	case h.pollResults <- poll:
+	default:
+		log.Printf("Dropping results of poll %d, too many are queued", poll.ID)
+	}
+}
+
+// runPollResults sends queued poll results to the connected users who can see each poll.
+// The permission checks hit the database, so they run here rather than in the hub loop.
+func (h *Hub) runPollResults() {
+	for poll := range h.pollResults {
+		resultsMsg := map[string]interface{}{
+			"type": "poll_results",
+			"poll": poll,
+		}
+		resultsJSON, err := json.Marshal(resultsMsg)
+		if err != nil {
+			log.Printf("Error marshaling poll results: %v", err)
+			continue
+		}
+
+		// Ask the hub who is connected, then check each of them
+		reply := make(chan []int, 1)
+		h.onlineUsers <- reply
+		recipients := []int{}
+		for _, userID := range <-reply {
+			canView, err := models.CanViewPoll(h.db, poll, userID)
+			if err != nil {
+				log.Printf("Error checking poll permissions: %v", err)
+				continue
+			}
+			if canView {
+				recipients = append(recipients, userID)
+			}
+		}
+
+		if len(recipients) > 0 {
+			h.userMessages <- userMessage{userIDs: recipients, message: resultsJSON}
+		}
+	}
+}
+
 // IsUserOnline checks if a user is currently connected
 func (h *Hub) IsUserOnline(userID int) bool {
 	clients, exists := h.userClients[userID]
 	return exists && len(clients) > 0
 }
 
+// isVerifiedSender checks if a user may send messages at all. With REQUIRE_EMAIL_VERIFICATION
+// on, unverified accounts can't, whether they send through the API or straight over the socket.
+func (h *Hub) isVerifiedSender(senderID int) (bool, error) {
+	if !models.EmailVerificationRequired() {
+		return true, nil
+	}
+	sender, err := models.GetUserById(h.db, senderID)
+	if err != nil {
+		return false, err
+	}
+	return sender != nil && sender.EmailVerifiedAt != nil, nil
+}
+
 // canSendMessage checks if a user can send a message to another user
 func (h *Hub) canSendMessage(senderID, recipientID int) (bool, error) {
+	verified, err := h.isVerifiedSender(senderID)
+	if err != nil || !verified {
+		return false, err
+	}
+
 	// Get recipient user
 	recipient, err := models.GetUserById(h.db, recipientID)
 	if err != nil {
@@ -116,20 +208,47 @@ func (h *Hub) removeUserClient(userID int, client *Client) {
 	}
 }
 
+// broadcastOffline tells all connected clients that a user went offline
+func (h *Hub) broadcastOffline(userID int) {
+	offlineMsg := map[string]interface{}{
+		"type":      "user_online_status",
+		"user_id":   userID,
+		"is_online": false,
+	}
+	offlineJSON, _ := json.Marshal(offlineMsg)
+
+	// Send to all remaining clients
+	for otherClient := range h.clients {
+		select {
+		case otherClient.Send <- offlineJSON:
+		default:
+			close(otherClient.Send)
+			delete(h.clients, otherClient)
+			h.removeUserClient(otherClient.UserID, otherClient)
+		}
+	}
+}
+
 // NewHub creates a new hub
 func NewHub(db *sql.DB) *Hub {
 	return &Hub{
-		broadcast:   make(chan []byte),
-		Register:    make(chan *Client),
-		Unregister:  make(chan *Client),
-		clients:     make(map[*Client]bool),
-		userClients: make(map[int][]*Client),
-		db:          db,
+		broadcast:    make(chan []byte),
+		Register:     make(chan *Client),
+		Unregister:   make(chan *Client),
+		disconnect:   make(chan int),
+		pollResults:  make(chan *models.Poll, pollResultsBacklog),
+		onlineUsers:  make(chan chan []int),
+		userMessages: make(chan userMessage),
+		clients:      make(map[*Client]bool),
+		userClients:  make(map[int][]*Client),
+		db:           db,
 	}
 }
 
 // Run starts the hub
 func (h *Hub) Run() {
+	go h.runPollResults()
+
 	for {
 		select {
 		case client := <-h.Register:
@@ -175,26 +294,33 @@ func (h *Hub) Run() {
 
 				// Broadcast offline status if no more clients for this user
 				if !h.IsUserOnline(client.UserID) {
-					offlineMsg := map[string]interface{}{
-						"type":      "user_online_status",
-						"user_id":   client.UserID,
-						"is_online": false,
-					}
-					offlineJSON, _ := json.Marshal(offlineMsg)
-
-					// Send to all remaining clients
-					for otherClient := range h.clients {
-						select {
-						case otherClient.Send <- offlineJSON:
-						default:
-							close(otherClient.Send)
-							delete(h.clients, otherClient)
-							h.removeUserClient(otherClient.UserID, otherClient)
-						}
-					}
+					h.broadcastOffline(client.UserID)
 				}
 			}
 
+		case userID := <-h.disconnect:
+			// Closing the send channel makes the write pump close the connection
+			clients := h.userClients[userID]
+			if len(clients) == 0 {
+				continue
+			}
+			for _, client := range clients {
+				delete(h.clients, client)
+				close(client.Send)
+			}
+			delete(h.userClients, userID)
+			log.Printf("Client disconnected by server: %d", userID)
+
+			h.broadcastOffline(userID)
+
+		case reply := <-h.onlineUsers:
+			reply <- h.GetOnlineUserIDs()
+
+		case msg := <-h.userMessages:
+			for _, userID := range msg.userIDs {
+				h.SendMessageToUser(userID, msg.message)
+			}
+
 		case message := <-h.broadcast:
 			// Parse message to determine recipients
 			var msg map[string]interface{}
@@ -269,6 +395,22 @@ func (h *Hub) Run() {
 					continue
 				}
 
+				senderID, ok := msg["sender_id"].(float64)
+				if !ok {
+					log.Printf("Group message has no sender_id")
+					continue
+				}
+
+				verified, err := h.isVerifiedSender(int(senderID))
+				if err != nil {
+					log.Printf("Error checking message permissions: %v", err)
+					continue
+				}
+				if !verified {
+					log.Printf("User %d cannot send group messages - email not verified", int(senderID))
+					continue
+				}
+
 				// Get group members from database
 				members, err := models.GetGroupMembers(h.db, int(groupID))
 				if err != nil {
@@ -284,6 +426,12 @@ func (h *Hub) Run() {
 					}
 				}
 
+				// Only accepted members can talk in a group
+				if !memberIDs[int(senderID)] {
+					log.Printf("User %d cannot send message to group %d - not a member", int(senderID), int(groupID))
+					continue
+				}
+
 				// Broadcast to all connected clients who are group members
 				for client := range h.clients {
 					if memberIDs[client.UserID] {
@@ -308,6 +456,10 @@ func (h *Hub) Run() {
 				// Send notification to specific user
 				h.SendMessageToUser(int(recipientID), message)
 
+			case "poll_results":
+				// Poll results only come from the server, through BroadcastPollResults
+				log.Printf("Dropping poll results sent by a client")
+
 			default:
 				// Broadcast to all clients
 				for client := range h.clients {
@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -12,7 +13,11 @@ import (
 	"github.com/go-chi/cors"
 	"github.com/hezronokwach/soshi/pkg/db/sqlite"
 	"github.com/hezronokwach/soshi/pkg/handlers"
+	"github.com/hezronokwach/soshi/pkg/jobs"
+	"github.com/hezronokwach/soshi/pkg/mailer"
 	middleware1 "github.com/hezronokwach/soshi/pkg/middleware"
+	"github.com/hezronokwach/soshi/pkg/models"
+	"github.com/hezronokwach/soshi/pkg/oidc"
 	"github.com/hezronokwach/soshi/pkg/websocket"
 	"github.com/joho/godotenv"
 )
@@ -36,6 +41,13 @@ func main() {
 		log.Fatalf("Failed to apply migrations: %v", err)
 	}
 
+	// Grant the admin role to the accounts listed in ADMIN_EMAILS
+	if emails := os.Getenv("ADMIN_EMAILS"); emails != "" {
+		if err := models.PromoteAdmins(db, strings.Split(emails, ",")); err != nil {
+			log.Fatalf("Failed to set up admins: %v", err)
+		}
+	}
+
 	// Initialize router
 	r := chi.NewRouter()
 
@@ -49,7 +61,7 @@ func main() {
 		AllowedOrigins:   []string{"http://localhost:3000", "http://localhost:8080"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "Cookie"},
-		ExposedHeaders:   []string{"Link", "Set-Cookie"},
+		ExposedHeaders:   []string{"Link", "Set-Cookie", "X-CSRF-Token"},
 		AllowCredentials: true,
 		MaxAge:           86400, // 24 hours
 	}))
@@ -58,8 +70,15 @@ func main() {
 	hub := websocket.NewHub(db)
 	go hub.Run()
 
+	// Start background jobs
+	go jobs.RunAccountDeletion(db, time.Hour)
+	go jobs.RunDataExports(db, 30*time.Second)
+	go jobs.RunScheduledPosts(db, 30*time.Second)
+
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(db)
+	mail := mailer.NewFromEnv()
+	oidcClient := oidc.NewClient(oidc.ConfigFromEnv())
+	authHandler := handlers.NewAuthHandler(db, mail, oidcClient)
 	postHandler := handlers.NewPostHandler(db)
 	commentHandler := handlers.NewCommentHandler(db)
 	groupHandler := handlers.NewGroupHandler(db)
@@ -67,9 +86,20 @@ func main() {
 	userHandler := handlers.NewUserHandler(db, hub)
 	messageHandler := handlers.NewMessageHandler(db, hub)
 	activityHandler := handlers.NewActivityHandler(db)
-	uploadHandler := handlers.NewUploadHandler()
+	uploadHandler := handlers.NewUploadHandler(db)
 	wsHandler := handlers.NewWebSocketHandler(hub, db)
+	apiTokenHandler := handlers.NewAPITokenHandler(db)
+	accountHandler := handlers.NewAccountHandler(db)
+	adminHandler := handlers.NewAdminHandler(db, hub)
+	reportHandler := handlers.NewReportHandler(db)
+	pollHandler := handlers.NewPollHandler(db, hub)
+	audienceListHandler := handlers.NewAudienceListHandler(db)
 	authMiddleware := middleware1.Auth(db)
+	requireVerified := middleware1.RequireVerifiedEmail()
+	requireSession := middleware1.RequireSession()
+
+	// CSRF protection for cookie-authenticated requests
+	r.Use(middleware1.CSRF(db))
 
 	// Routes
 	// Auth routes
@@ -78,16 +108,59 @@ func main() {
 		r.Post("/login", authHandler.Login)
 		r.Post("/logout", authHandler.Logout)
 		r.Get("/session", authHandler.GetSession)
+		r.Post("/forgot-password", authHandler.ForgotPassword)
+		r.Post("/reset-password", authHandler.ResetPassword)
+		r.Post("/verify-email", authHandler.VerifyEmail)
+		r.Post("/login/2fa", authHandler.LoginTwoFactor)
+		r.Get("/oidc/login", authHandler.OIDCLogin)
+		r.Get("/oidc/callback", authHandler.OIDCCallback)
+
+		// Session management
+		r.Group(func(r chi.Router) {
+			r.Use(authMiddleware)
+			r.Use(requireSession)
+			r.Get("/sessions", authHandler.GetSessions)
+			r.Delete("/sessions", authHandler.RevokeOtherSessions)
+			r.Delete("/sessions/{sessionID}", authHandler.RevokeSession)
+			r.Post("/resend-verification", authHandler.ResendVerification)
+			r.Put("/password", authHandler.ChangePassword)
+			r.Put("/email", authHandler.ChangeEmail)
+
+			// Two-factor authentication
+			r.Get("/2fa", authHandler.GetTwoFactorStatus)
+			r.Post("/2fa/setup", authHandler.SetupTwoFactor)
+			r.Post("/2fa/enable", authHandler.EnableTwoFactor)
+			r.Post("/2fa/disable", authHandler.DisableTwoFactor)
+			r.Post("/2fa/recovery-codes", authHandler.RegenerateRecoveryCodes)
+
+			// Linked OpenID provider accounts
+			r.Post("/oidc/link", authHandler.LinkOIDCIdentity)
+			r.Get("/identities", authHandler.GetIdentities)
+			r.Delete("/identities/{identityID}", authHandler.UnlinkIdentity)
+
+			// Personal access tokens
+			r.Get("/tokens", apiTokenHandler.GetTokens)
+			r.Post("/tokens", apiTokenHandler.CreateToken)
+			r.Delete("/tokens/{tokenID}", apiTokenHandler.RevokeToken)
+
+			// Account deletion and data export
+			r.Delete("/account", accountHandler.DeleteAccount)
+			r.Post("/account/exports", accountHandler.RequestDataExport)
+			r.Get("/account/exports", accountHandler.GetDataExports)
+			r.Get("/account/exports/{exportID}/download", accountHandler.DownloadDataExport)
+		})
 	})
 
 	// Post routes
 	r.Route("/api/posts", func(r chi.Router) {
 		r.Use(authMiddleware)
+		r.Use(middleware1.RequireScope("posts"))
 		r.Get("/", postHandler.GetPosts)
 		r.Get("/liked", postHandler.GetLikedPosts) // Endpoint for liked posts
 		r.Get("/commented", postHandler.GetCommentedPosts) // Endpoint for commented posts
 		r.Get("/saved", postHandler.GetSavedPosts) // Endpoint for saved posts
-		r.Post("/", postHandler.CreatePost)
+		r.Get("/queued", postHandler.GetQueuedPosts) // The user's drafts and scheduled posts
+		r.With(requireVerified).Post("/", postHandler.CreatePost)
 		r.Put("/", postHandler.UpdatePost)
 		r.Delete("/", postHandler.DeletePost)
 
@@ -96,13 +169,17 @@ func main() {
 			r.Get("/saved", postHandler.CheckPostSaved) // Check if post is saved
 			r.Post("/save", postHandler.SavePost)     // Save a post
 			r.Delete("/save", postHandler.UnsavePost) // Unsave a post
+			r.Post("/report", reportHandler.ReportPost)
+			r.Get("/revisions", postHandler.GetRevisions)
+			r.Put("/schedule", postHandler.SchedulePost)
+			r.Delete("/schedule", postHandler.UnschedulePost)
 		})
 
 		// Post comments
 		r.Route("/{postID}/comments", func(r chi.Router) {
 			r.Use(authMiddleware)
 			r.Get("/", commentHandler.GetPostComments)
-			r.Post("/", commentHandler.CreateComment)
+			r.With(requireVerified).Post("/", commentHandler.CreateComment)
 		})
 
 		// Post reactions
@@ -113,12 +190,40 @@ func main() {
 		})
 	})
 
+	// Poll routes
+	r.Route("/api/polls/{pollID}", func(r chi.Router) {
+		r.Use(authMiddleware)
+		r.Use(middleware1.RequireScope("posts"))
+		r.Get("/", pollHandler.GetPoll)
+		r.Post("/votes", pollHandler.Vote)
+		r.Delete("/votes", pollHandler.WithdrawVote)
+	})
+
+	// Audience list routes
+	r.Route("/api/audience-lists", func(r chi.Router) {
+		r.Use(authMiddleware)
+		r.Use(middleware1.RequireScope("posts"))
+		r.Get("/", audienceListHandler.GetLists)
+		r.Post("/", audienceListHandler.CreateList)
+
+		r.Route("/{listID}", func(r chi.Router) {
+			r.Get("/", audienceListHandler.GetList)
+			r.Put("/", audienceListHandler.RenameList)
+			r.Delete("/", audienceListHandler.DeleteList)
+			r.Post("/members", audienceListHandler.AddMember)
+			r.Delete("/members/{userID}", audienceListHandler.RemoveMember)
+		})
+	})
+
 	// Comment routes
 	r.Route("/api/comments/{commentID}", func(r chi.Router) {
 		r.Use(authMiddleware)
+		r.Use(middleware1.RequireScope("posts"))
 		r.Get("/", commentHandler.GetComment)
 		r.Put("/", commentHandler.UpdateComment)
 		r.Delete("/", commentHandler.DeleteComment)
+		r.Post("/report", reportHandler.ReportComment)
+		r.Get("/revisions", commentHandler.GetRevisions)
 
 		// Comment reactions
 		r.Route("/reactions", func(r chi.Router) {
@@ -131,9 +236,11 @@ func main() {
 	// Group comment routes
 	r.Route("/api/groups/comments/{commentID}", func(r chi.Router) {
 		r.Use(authMiddleware)
+		r.Use(middleware1.RequireScope("groups"))
 		r.Get("/", groupCommentHandler.GetGroupPostComment)
 		r.Put("/", groupCommentHandler.UpdateGroupPostComment)
 		r.Delete("/", groupCommentHandler.DeleteGroupPostComment)
+		r.Get("/revisions", groupCommentHandler.GetGroupPostCommentRevisions)
 
 		r.Route("/reactions", func(r chi.Router) {
 			r.Use(authMiddleware)
@@ -142,9 +249,19 @@ func main() {
 		})
 	})
 
+	// Group chat routes. These sit outside the group routes so that
+	// API tokens only need the messages scope to use them.
+	r.Route("/api/groups/{groupID}/messages", func(r chi.Router) {
+		r.Use(authMiddleware)
+		r.Use(middleware1.RequireScope("messages"))
+		r.Get("/", messageHandler.GetGroupMessages)
+		r.With(requireVerified).Post("/", messageHandler.SendGroupMessage)
+	})
+
 	// Group routes
 	r.Route("/api/groups", func(r chi.Router) {
 		r.Use(authMiddleware)
+		r.Use(middleware1.RequireScope("groups"))
 		r.Get("/", groupHandler.GetGroups)
 		r.Post("/", groupHandler.CreateGroup)
 
@@ -168,7 +285,8 @@ func main() {
 			r.Route("/posts", func(r chi.Router) {
 				r.Use(authMiddleware)
 				r.Get("/", groupHandler.GetPosts)
-				r.Post("/", groupHandler.CreatePost)
+				r.With(requireVerified).Post("/", groupHandler.CreatePost)
+				r.Post("/{postID}/report", reportHandler.ReportGroupPost)
 
 				// Group post reactions
 				r.Route("/{postID}/reactions", func(r chi.Router) {
@@ -181,7 +299,7 @@ func main() {
 				r.Route("/{groupPostID}/comments", func(r chi.Router) {
 					r.Use(authMiddleware)
 					r.Get("/", groupCommentHandler.GetGroupPostComments)
-					r.Post("/", groupCommentHandler.CreateGroupPostComment)
+					r.With(requireVerified).Post("/", groupCommentHandler.CreateGroupPostComment)
 				})
 			})
 
@@ -191,13 +309,6 @@ func main() {
 				r.Get("/", groupHandler.GetEvents)
 				r.Post("/", groupHandler.CreateEvent)
 			})
-
-			// Group chat
-			r.Route("/messages", func(r chi.Router) {
-				r.Use(authMiddleware)
-				r.Get("/", messageHandler.GetGroupMessages)
-				r.Post("/", messageHandler.SendGroupMessage)
-			})
 		})
 
 		// Event responses
@@ -207,6 +318,7 @@ func main() {
 	// User routes
 	r.Route("/api/users", func(r chi.Router) {
 		r.Use(authMiddleware)
+		r.Use(middleware1.RequireScope("users"))
 		r.Get("/followers", userHandler.GetFollowers)
 		r.Get("/following", userHandler.GetFollowing)
 		r.Get("/counts", userHandler.GetFollowCounts)
@@ -227,6 +339,7 @@ func main() {
 		r.Post("/{userID}/follow", userHandler.FollowUser)
 		r.Delete("/{userID}/follow", userHandler.UnfollowUser)
 		r.Delete("/{userID}/follow-request", userHandler.CancelFollowRequest)
+		r.Post("/{userID}/report", reportHandler.ReportUser)
 
 		// Get all users (including private)
 		r.Get("/all", userHandler.GetAllUsers)
@@ -235,9 +348,21 @@ func main() {
 		r.Post("/accept-message-request", userHandler.AcceptMessageRequestHandler)
 	})
 
+	// Search routes. API tokens are checked per kind of result in the handler.
+	searchHandler := handlers.NewSearchHandler(db)
+	r.With(authMiddleware).Get("/api/search", searchHandler.Search)
+
+	// Hashtag routes
+	r.Route("/api/hashtags", func(r chi.Router) {
+		r.Use(authMiddleware)
+		r.Use(middleware1.RequireScope("posts"))
+		r.Get("/{tag}/posts", postHandler.GetHashtagPosts)
+	})
+
 	// Activity routes
 	r.Route("/api/activity", func(r chi.Router) {
 		r.Use(authMiddleware)
+		r.Use(middleware1.RequireScope("activity"))
 		r.Get("/", activityHandler.GetUserActivities)
 		r.Get("/posts", activityHandler.GetUserPosts)
 		r.Get("/settings", activityHandler.GetActivitySettings)
@@ -254,6 +379,7 @@ func main() {
 	notificationHandler := handlers.NewNotificationHandler(db)
 	r.Route("/api/notifications", func(r chi.Router) {
 		r.Use(authMiddleware)
+		r.Use(middleware1.RequireScope("notifications"))
 		r.Get("/", notificationHandler.GetNotifications)
 		r.Put("/read", notificationHandler.MarkNotificationAsRead)
 		r.Put("/read-all", notificationHandler.MarkAllNotificationsAsRead)
@@ -263,16 +389,48 @@ func main() {
 	// Message routes
 	r.Route("/api/messages", func(r chi.Router) {
 		r.Use(authMiddleware)
+		r.Use(middleware1.RequireScope("messages"))
 		r.Get("/conversations", messageHandler.GetConversations)
 		r.Get("/unread-count", messageHandler.GetUnreadMessageCount)
 		r.Get("/{userID}", messageHandler.GetPrivateMessages)
-		r.Post("/{userID}", messageHandler.SendPrivateMessage)
+		r.With(requireVerified).Post("/{userID}", messageHandler.SendPrivateMessage)
 		r.Put("/{userID}/read", messageHandler.MarkMessagesAsRead)
+		r.Post("/{messageID}/report", reportHandler.ReportMessage)
+	})
+
+	// Admin routes
+	r.Route("/api/admin", func(r chi.Router) {
+		r.Use(authMiddleware)
+		r.Use(requireSession)
+		r.With(middleware1.RequirePermission(models.PermManageRoles)).Put("/users/{userID}/role", adminHandler.SetUserRole)
+
+		// Account standing
+		r.Group(func(r chi.Router) {
+			r.Use(middleware1.RequirePermission(models.PermManageUsers))
+			r.Post("/users/{userID}/suspend", adminHandler.SuspendUser)
+			r.Post("/users/{userID}/ban", adminHandler.BanUser)
+			r.Post("/users/{userID}/reinstate", adminHandler.ReinstateUser)
+		})
+
+		// Content moderation
+		r.Group(func(r chi.Router) {
+			r.Use(middleware1.RequirePermission(models.PermModerateContent))
+			r.Get("/users/{userID}/actions", adminHandler.GetUserActions)
+			r.Get("/moderation-log", adminHandler.GetModerationLog)
+			r.Delete("/posts/{postID}", adminHandler.DeletePost)
+			r.Delete("/comments/{commentID}", adminHandler.DeleteComment)
+			r.Delete("/group-posts/{postID}", adminHandler.DeleteGroupPost)
+			r.Delete("/groups/{groupID}", adminHandler.DeleteGroup)
+			r.Get("/reports", adminHandler.GetReports)
+			r.Get("/reports/{reportID}", adminHandler.GetReport)
+			r.Post("/reports/{reportID}/resolve", adminHandler.ResolveReport)
+		})
 	})
 
 	// Upload route
 	r.Route("/api/upload", func(r chi.Router) {
 		r.Use(authMiddleware)
+		r.Use(middleware1.RequireScope("uploads"))
 		r.Post("/", uploadHandler.UploadFile)
 	})
 
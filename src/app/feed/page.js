@@ -9,15 +9,19 @@ import PostCard from "@/components/posts/PostCard";
 export default function FeedPage() {
   const [posts, setPosts] = useState([]);
   const [isLoading, setIsLoading] = useState(true);
-  const [page, setPage] = useState(1);
+  const [nextCursor, setNextCursor] = useState(null);
+  const [hasMore, setHasMore] = useState(false);
   const { user } = useAuth();
 
-  // Fetch posts
-  const fetchPosts = async () => {
+  // Fetch posts. Later pages continue from the previous page's cursor, so posts
+  // created in the meantime don't shift the feed and cause duplicates or gaps.
+  const fetchPosts = async (cursor = null) => {
     try {
       // Using API client - now returns normalized {posts: [...]} structure
-      const data = await postsAPI.getPosts(page, 10);
-      setPosts(prev => page === 1 ? data.posts : [...prev, ...data.posts]);
+      const data = await postsAPI.getPosts(1, 10, cursor);
+      setPosts(prev => cursor ? [...prev, ...data.posts] : data.posts);
+      setNextCursor(data.nextCursor || null);
+      setHasMore(Boolean(data.nextCursor));
       setIsLoading(false);
     } catch (error) {
       console.error("Error fetching posts:", error);
@@ -30,11 +34,10 @@ export default function FeedPage() {
     if (user) {
       fetchPosts();
     }
-  }, [user, page]);
+  }, [user]);
 
   // Handle new post creation
   const handlePostCreated = () => {
-    setPage(1);
     fetchPosts();
   };
 
@@ -75,10 +78,10 @@ export default function FeedPage() {
         )}
 
         {/* Load more button */}
-        {posts.length > 0 && (
+        {posts.length > 0 && hasMore && (
           <div className="text-center pt-4">
             <button
-              onClick={() => setPage(prev => prev + 1)}
+              onClick={() => fetchPosts(nextCursor)}
               className="text-primary hover:text-primary-dark"
             >
               Load more posts
@@ -18,7 +18,7 @@ export default function NotificationsPage() {
     try {
       setLoading(true);
       const data = await notifications.getNotifications();
-      setNotificationsList(data || []);
+      setNotificationsList(data?.notifications || []);
     } catch (err) {
       setError(err.message || 'Failed to load notifications');
     } finally {
@@ -2,6 +2,7 @@
 
 import React, { useState, useEffect } from 'react';
 import { useAuth } from '@/hooks/useAuth';
+import { fetchWithCSRF } from '@/lib/api';
 import { MessageSquare, ThumbsUp, ThumbsDown, Edit, Trash2 } from 'lucide-react';
 import { getImageUrl } from '@/utils/image';
 import CommentForm from './CommentForm';
@@ -66,7 +67,7 @@ export default function CommentItem({ comment, postOwnerId, onUpdate, onDelete }
     });
     
     try {
-      const res = await fetch(`/api/comments/${comment.id}/reactions`, {
+      const res = await fetchWithCSRF(`/api/comments/${comment.id}/reactions`, {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify({
@@ -87,7 +88,7 @@ export default function CommentItem({ comment, postOwnerId, onUpdate, onDelete }
 
   const handleEdit = async (data) => {
     try {
-      const res = await fetch(`/api/comments/${comment.id}`, {
+      const res = await fetchWithCSRF(`/api/comments/${comment.id}`, {
         method: 'PUT',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify({
@@ -111,7 +112,7 @@ export default function CommentItem({ comment, postOwnerId, onUpdate, onDelete }
 
     try {
       setIsDeleting(true);
-      const res = await fetch(
+      const res = await fetchWithCSRF(
         `/api/comments/${comment.id}?userId=${user.id}&postOwnerId=${postOwnerId}`,
         { 
           method: 'DELETE',
@@ -149,7 +150,7 @@ export default function CommentItem({ comment, postOwnerId, onUpdate, onDelete }
 
       console.log('Sending reply data:', requestBody);
       
-      const res = await fetch(`/api/posts/${comment.post_id}/comments`, {
+      const res = await fetchWithCSRF(`/api/posts/${comment.post_id}/comments`, {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify(requestBody)
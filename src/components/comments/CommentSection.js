@@ -2,6 +2,7 @@
 
 import { useState, useEffect } from 'react';
 import { useAuth } from '@/hooks/useAuth';
+import { fetchWithCSRF } from '@/lib/api';
 import { MessageSquare } from 'lucide-react';
 import CommentForm from './CommentForm';
 import CommentList from './CommentList';
@@ -152,7 +153,7 @@ export default function CommentSection({ postId, postOwnerId, onCommentAdded })
       
       console.log('Sending comment data:', requestBody);
       
-      const res = await fetch(`/api/posts/${postId}/comments`, {
+      const res = await fetchWithCSRF(`/api/posts/${postId}/comments`, {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify(requestBody)
@@ -2,6 +2,7 @@
 
 import React, { useState, useEffect } from 'react';
 import { useAuth } from '@/hooks/useAuth';
+import { fetchWithCSRF } from '@/lib/api';
 import { ThumbsUp, ThumbsDown, Edit, Trash2 } from 'lucide-react';
 import { getImageUrl } from '@/utils/image';
 import GroupCommentForm from './GroupCommentForm';
@@ -60,7 +61,7 @@ export default function GroupCommentItem({ comment, groupId, groupPostId, onUpda
     });
     
     try {
-      const res = await fetch(`http://localhost:8080/api/groups/comments/${comment.id}/reactions`, {
+      const res = await fetchWithCSRF(`http://localhost:8080/api/groups/comments/${comment.id}/reactions`, {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
         credentials: 'include',
@@ -81,7 +82,7 @@ export default function GroupCommentItem({ comment, groupId, groupPostId, onUpda
 
   const handleEdit = async (data) => {
     try {
-      const res = await fetch(`http://localhost:8080/api/groups/comments/${comment.id}`, {
+      const res = await fetchWithCSRF(`http://localhost:8080/api/groups/comments/${comment.id}`, {
         method: 'PUT',
         headers: { 'Content-Type': 'application/json' },
         credentials: 'include',
@@ -106,7 +107,7 @@ export default function GroupCommentItem({ comment, groupId, groupPostId, onUpda
 
     try {
       setIsDeleting(true);
-      const res = await fetch(`http://localhost:8080/api/groups/comments/${comment.id}`, {
+      const res = await fetchWithCSRF(`http://localhost:8080/api/groups/comments/${comment.id}`, {
         method: 'DELETE',
         headers: {
           'Content-Type': 'application/json'
@@ -2,6 +2,7 @@
 
 import { useState, useEffect } from 'react';
 import { useAuth } from '@/hooks/useAuth';
+import { fetchWithCSRF } from '@/lib/api';
 import GroupCommentForm from './GroupCommentForm';
 import GroupCommentList from './GroupCommentList';
 
@@ -154,7 +155,7 @@ export default function GroupPostComments({ groupId, groupPostId, onCommentAdded
       
       console.log('Sending group comment data:', requestBody);
       
-      const res = await fetch(`http://localhost:8080/api/groups/${groupId}/posts/${groupPostId}/comments`, {
+      const res = await fetchWithCSRF(`http://localhost:8080/api/groups/${groupId}/posts/${groupPostId}/comments`, {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
         credentials: 'include',
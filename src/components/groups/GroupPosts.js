@@ -4,7 +4,7 @@
 import { useState } from 'react';
 import { Card } from '@/components/ui/card';
 import { Button } from '@/components/ui/button';
-import { groups, upload } from '@/lib/api';
+import { groups, upload, fetchWithCSRF } from '@/lib/api';
 import { ImagePlus, X, ThumbsUp, ThumbsDown, MessageSquare } from 'lucide-react';
 import { getImageUrl } from '@/utils/image';
 import GroupPostComments from './GroupPostComments';
@@ -80,7 +80,7 @@ export default function GroupPosts({ params, group, fetchGroup }) {
 
     const handlePostReaction = async (postId, type) => {
         try {
-            const res = await fetch(`http://localhost:8080/api/groups/${params.id}/posts/${postId}/reactions`, {
+            const res = await fetchWithCSRF(`http://localhost:8080/api/groups/${params.id}/posts/${postId}/reactions`, {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
                 credentials: 'include',
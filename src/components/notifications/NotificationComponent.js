@@ -9,10 +9,12 @@
 
 // src/components/notifications/NotificationComponent.js
 
+import { fetchWithCSRF } from '@/lib/api';
+
 const renderGroupNotification = (notification) => {
   const handleGroupAction = async (action) => {
     if (notification.type === 'group_join_request') {
-      await fetch(`/api/groups/${notification.related_id}/members/${notification.user_id}`, {
+      await fetchWithCSRF(`/api/groups/${notification.related_id}/members/${notification.user_id}`, {
         method: 'PUT',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify({ action })
@@ -21,7 +23,7 @@ const renderGroupNotification = (notification) => {
       // Mark notification as read and refresh
       markAsRead(notification.id);
     } else if (notification.type === 'group_invitation') {
-      await fetch(`/api/groups/${notification.related_id}/members/${user.id}`, {
+      await fetchWithCSRF(`/api/groups/${notification.related_id}/members/${user.id}`, {
         method: 'PUT',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify({ action })
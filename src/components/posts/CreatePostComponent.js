@@ -2,7 +2,7 @@
 
 import { useState, useCallback } from "react";
 import { useAuth } from "@/hooks/useAuth";
-import { upload } from "@/lib/api";
+import { upload, fetchWithCSRF } from "@/lib/api";
 import SelectFollowersModal from "./SelectFollowersModal";
 
 export default function CreatePostComponent({ onPostCreated }) {
@@ -71,7 +71,7 @@ export default function CreatePostComponent({ onPostCreated }) {
       }
 
       // Create post
-      const postRes = await fetch("/api/posts", {
+      const postRes = await fetchWithCSRF("/api/posts", {
         method: "POST",
         headers: {
           "Content-Type": "application/json",
@@ -2,7 +2,7 @@
 
 import { useState, useEffect, useRef } from "react";
 import { useAuth } from "@/hooks/useAuth";
-import { upload } from "@/lib/api";
+import { upload, fetchWithCSRF } from "@/lib/api";
 import { getImageUrl } from "@/utils/image";
 import { Edit, Trash2, ThumbsUp, ThumbsDown, MessageSquare, Share2, Bookmark, BookmarkCheck, Loader2 } from "lucide-react";
 import CommentSection from "@/components/comments/CommentSection";
@@ -144,7 +144,7 @@ export default function PostCard({ post, onDelete, onUpdate }) {
       }
 
       // Update post
-      const res = await fetch(`/api/posts`, {
+      const res = await fetchWithCSRF(`/api/posts`, {
         method: "PUT",
         headers: {
           "Content-Type": "application/json",
@@ -188,7 +188,7 @@ export default function PostCard({ post, onDelete, onUpdate }) {
 
     try {
       setIsDeleting(true);
-      const res = await fetch('/api/posts', {
+      const res = await fetchWithCSRF('/api/posts', {
         method: 'DELETE',
         headers: {
           'Content-Type': 'application/json',
@@ -237,7 +237,7 @@ export default function PostCard({ post, onDelete, onUpdate }) {
     });
     
     try {
-      const res = await fetch(`/api/posts/${post.id}/reactions`, {
+      const res = await fetchWithCSRF(`/api/posts/${post.id}/reactions`, {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify({
@@ -271,7 +271,7 @@ export default function PostCard({ post, onDelete, onUpdate }) {
       const url = `/api/posts/${post.id}/save`;
       const method = prevSaved ? 'DELETE' : 'POST';
       
-      const res = await fetch(url, {
+      const res = await fetchWithCSRF(url, {
         method,
         headers: { 'Content-Type': 'application/json' },
         credentials: 'include',
@@ -1,9 +1,13 @@
 // API client for communicating with the Go backend
 const API_URL = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080"
 
+// CSRF token for the current session, returned by the session and login endpoints
+let csrfToken = null
+
 // Helper function for making API requests
 async function fetchAPI(endpoint, options = {}) {
   const url = `${API_URL}${endpoint}`
+  const method = (options.method || "GET").toUpperCase()
 
   // Don't override Content-Type for FormData (file uploads)
   const isFormData = options.body instanceof FormData
@@ -11,6 +15,9 @@ async function fetchAPI(endpoint, options = {}) {
     ? {}
     : { "Content-Type": "application/json" }
 
+  // State-changing requests must echo the session's CSRF token
+  Object.assign(defaultHeaders, await csrfHeaders(method, endpoint))
+
   const fetchOptions = {
     ...options,
     credentials: "include",   // Always include credentials (cookies)
@@ -24,6 +31,8 @@ async function fetchAPI(endpoint, options = {}) {
   try {
     const response = await fetch(url, fetchOptions)
 
+    rememberCSRFToken(response)
+
     // Debug logging for mark as read requests
     if (endpoint.includes('/read')) {
       console.log('Mark as read response status:', response.status);
@@ -82,6 +91,54 @@ async function fetchAPI(endpoint, options = {}) {
   }
 }
 
+// CSRF header to send with a request, empty for requests that don't change state
+async function csrfHeaders(method, endpoint) {
+  if (method === "GET" || method === "HEAD") {
+    return {}
+  }
+  if (!csrfToken && endpoint !== "/api/auth/login" && endpoint !== "/api/auth/register") {
+    await refreshCSRFToken()
+  }
+  return csrfToken ? { "X-CSRF-Token": csrfToken } : {}
+}
+
+// Remember the CSRF token whenever the server hands one out
+function rememberCSRFToken(response) {
+  const responseCSRFToken = response.headers.get("X-CSRF-Token")
+  if (responseCSRFToken) {
+    csrfToken = responseCSRFToken
+  }
+}
+
+// Drop-in replacement for fetch for components that work with the raw response.
+// It sends cookies and attaches the CSRF token to state-changing requests.
+export async function fetchWithCSRF(url, options = {}) {
+  const method = (options.method || "GET").toUpperCase()
+  const response = await fetch(url, {
+    ...options,
+    credentials: "include",
+    headers: {
+      ...(await csrfHeaders(method, url)),
+      ...options.headers,
+    },
+  })
+  rememberCSRFToken(response)
+  return response
+}
+
+// Fetch the CSRF token for the current session, e.g. after a page reload
+async function refreshCSRFToken() {
+  try {
+    const response = await fetch(`${API_URL}/api/auth/session`, {
+      credentials: "include",
+      mode: "cors",
+    })
+    csrfToken = response.headers.get("X-CSRF-Token")
+  } catch (error) {
+    csrfToken = null
+  }
+}
+
 // Auth API
 export const auth = {
   register: (userData) =>
@@ -126,8 +183,11 @@ export const auth = {
 
 // Posts API
 export const posts = {
-  getPosts: async (page = 1, limit = 10) => {
-    const data = await fetchAPI(`/api/posts?page=${page}&limit=${limit}`);
+  getPosts: async (page = 1, limit = 10, cursor = null) => {
+    // A cursor from the previous page's nextCursor takes precedence over the page number
+    const params = new URLSearchParams({ page: page.toString(), limit: limit.toString() });
+    if (cursor) params.set("cursor", cursor);
+    const data = await fetchAPI(`/api/posts?${params}`);
 
     // Normalize response: if backend returns array directly, wrap it in an object
     if (Array.isArray(data)) {